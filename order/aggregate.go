@@ -0,0 +1,149 @@
+package order
+
+import "context"
+
+// AggregateRoot holds the identity and event bookkeeping shared by all
+// aggregates. It is meant to be embedded by the aggregate types, which only
+// need to implement how each of their events changes their state.
+type AggregateRoot struct {
+	ID string
+
+	version     int
+	clock       Clock
+	metadata    Metadata
+	uncommitted []PersistedEvent
+}
+
+// Root returns the aggregate root itself, making any type that embeds it
+// partially satisfy EventSourced.
+func (a *AggregateRoot) Root() *AggregateRoot {
+	return a
+}
+
+// AggregateID returns the identifier of the aggregate.
+func (a *AggregateRoot) AggregateID() string {
+	return a.ID
+}
+
+// Version returns the number of events applied to the aggregate.
+func (a *AggregateRoot) Version() int {
+	return a.version
+}
+
+// UncommittedEvents returns a copy of the events that have been applied to
+// the aggregate since it was last saved.
+func (a *AggregateRoot) UncommittedEvents() []Event {
+	events := make([]Event, len(a.uncommitted))
+	for i, e := range a.uncommitted {
+		events[i] = e.Event
+	}
+	return events
+}
+
+// MarkCommitted clears the uncommitted events once they have been saved.
+func (a *AggregateRoot) MarkCommitted() {
+	a.uncommitted = nil
+}
+
+// record updates the bookkeeping once an event has been applied to the
+// aggregate, keeping new events until they are committed.
+func (a *AggregateRoot) record(e Event, isNew bool) {
+	a.ID = e.ID()
+	a.version++
+
+	if isNew {
+		version, _ := DefaultRegistry.SchemaVersion(e)
+		occurredAt := now(a.clock)
+
+		a.uncommitted = append(a.uncommitted, PersistedEvent{
+			Event:         e,
+			EventID:       newEventID(occurredAt),
+			AggregateID:   a.ID,
+			OccurredAt:    occurredAt,
+			Metadata:      a.metadata,
+			SchemaVersion: version,
+		})
+	}
+}
+
+// Aggregate is implemented by event-sourced aggregates of any type. Types
+// embedding AggregateRoot only need to implement Apply.
+type Aggregate interface {
+	AggregateID() string
+	Version() int
+	UncommittedEvents() []Event
+	MarkCommitted()
+
+	// Apply updates the state of the aggregate from a previously saved event.
+	Apply(e Event) error
+}
+
+// EventSourced is implemented by aggregates that can be stored using an
+// AggregateRepository, typically pointers to types embedding AggregateRoot.
+type EventSourced interface {
+	Aggregate
+	Root() *AggregateRoot
+}
+
+// AggregateRepository loads and saves aggregates of a given type.
+type AggregateRepository[T any] interface {
+	Save(ctx context.Context, aggregate T) error
+	Load(ctx context.Context, id string) (T, error)
+}
+
+type aggregateRepository[T EventSourced] struct {
+	Store         EventStore
+	AggregateType string
+	Factory       func() T
+}
+
+// Save saves the uncommitted events of the aggregate.
+func (r *aggregateRepository[T]) Save(ctx context.Context, aggregate T) error {
+	root := aggregate.Root()
+
+	if len(root.uncommitted) == 0 {
+		return nil
+	}
+
+	expectedVersion := root.version - len(root.uncommitted)
+
+	saveCtx, skipped := withSkippedEvents(ctx)
+	if err := r.Store.Save(saveCtx, r.AggregateType, root.ID, expectedVersion, root.uncommitted); err != nil {
+		return err
+	}
+
+	recordCommitted(ctx, skipped.appended(committed(r.AggregateType, root.ID, expectedVersion, root.uncommitted)))
+
+	root.MarkCommitted()
+
+	return nil
+}
+
+// Load rebuilds a new aggregate from its saved events.
+func (r *aggregateRepository[T]) Load(ctx context.Context, id string) (T, error) {
+	aggregate := r.Factory()
+
+	events, err := r.Store.Load(ctx, r.AggregateType, id)
+	if err != nil {
+		return aggregate, err
+	}
+
+	for _, e := range events {
+		if err := aggregate.Apply(e.Event); err != nil {
+			return aggregate, err
+		}
+	}
+
+	return aggregate, nil
+}
+
+// NewAggregateRepository returns a new repository for aggregates of type T,
+// stored under the given aggregate type, using the factory to create the empty
+// aggregates that events are applied to.
+func NewAggregateRepository[T EventSourced](store EventStore, aggregateType string, factory func() T) AggregateRepository[T] {
+	return &aggregateRepository[T]{
+		Store:         store,
+		AggregateType: aggregateType,
+		Factory:       factory,
+	}
+}
@@ -0,0 +1,198 @@
+package order_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestAggregateRepository(t *testing.T) {
+	repo := order.NewAggregateRepository(order.NewEventStore(), order.AggregateTypeOrder, func() *order.Order {
+		return &order.Order{}
+	})
+
+	o := order.NewOrder("ABC123")
+	if err := o.Place(testLines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := o.Activate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := repo.Save(context.Background(), &o); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n := len(o.UncommittedEvents()); n != 0 {
+		t.Errorf("expected: %v, got: %v", 0, n)
+	}
+
+	loaded, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if loaded.ID != "ABC123" {
+		t.Errorf("expected: %v, got: %v", "ABC123", loaded.ID)
+	}
+	if loaded.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, loaded.Status)
+	}
+	if loaded.Version() != 2 {
+		t.Errorf("expected: %v, got: %v", 2, loaded.Version())
+	}
+	wantTotal, wantCurrency := o.Total()
+	if total, currency := loaded.Total(); total != wantTotal || currency != wantCurrency {
+		t.Errorf("expected: %v %v, got: %v %v", wantTotal, wantCurrency, total, currency)
+	}
+
+	if err := loaded.Ship(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := repo.Save(context.Background(), loaded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestOrderAggregate(t *testing.T) {
+	o := order.NewOrder("ABC123")
+	if err := o.Place(testLines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var a order.Aggregate = &o
+
+	if a.AggregateID() != "ABC123" {
+		t.Errorf("expected: %v, got: %v", "ABC123", a.AggregateID())
+	}
+	if a.Version() != 1 {
+		t.Errorf("expected: %v, got: %v", 1, a.Version())
+	}
+	if n := len(a.UncommittedEvents()); n != 1 {
+		t.Errorf("expected: %v, got: %v", 1, n)
+	}
+
+	a.MarkCommitted()
+
+	if n := len(a.UncommittedEvents()); n != 0 {
+		t.Errorf("expected: %v, got: %v", 0, n)
+	}
+
+	if err := a.Apply(order.Activated{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if a.Version() != 2 {
+		t.Errorf("expected: %v, got: %v", 2, a.Version())
+	}
+	if o.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+	}
+	if n := len(a.UncommittedEvents()); n != 0 {
+		t.Errorf("expected: %v, got: %v", 0, n)
+	}
+}
+
+// registered is the event of a customer aggregate, sharing IDs with orders.
+type registered struct {
+	CustomerID string
+	Name       string
+}
+
+func (e registered) ID() string {
+	return e.CustomerID
+}
+
+func init() {
+	order.DefaultRegistry.RegisterFor("customer", "Registered", func() order.Event { return registered{} })
+}
+
+// customer is an aggregate stored alongside orders.
+type customer struct {
+	order.AggregateRoot
+	Name string
+}
+
+func (c *customer) Apply(e order.Event) error {
+	c.ID = e.ID()
+	c.Name = e.(registered).Name
+	return nil
+}
+
+func TestAggregateTypesAreIsolated(t *testing.T) {
+	if name, err := order.DefaultRegistry.Name(registered{}); err != nil || name != "customer.Registered" {
+		t.Errorf("expected: %v, got: %v (%v)", "customer.Registered", name, err)
+	}
+
+	fileStore, err := order.NewFileEventStore(filepath.Join(t.TempDir(), "events.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sqliteStore, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	stores := map[string]order.EventStore{
+		"memory": order.NewEventStore(),
+		"file":   fileStore,
+		"sqlite": sqliteStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			orders := order.NewRepository(store)
+			if err := order.NewCommandHandler(orders).Handle(ctx, order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// The customer is saved at version 0, which only succeeds if
+			// the events of the order aren't counted.
+			events := []order.PersistedEvent{{Event: registered{CustomerID: "ABC123", Name: "Alice"}}}
+			if err := store.Save(ctx, "customer", "ABC123", 0, events); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			customers := order.NewAggregateRepository(store, "customer", func() *customer { return &customer{} })
+
+			c, err := customers.Load(ctx, "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.Name != "Alice" {
+				t.Errorf("expected: %v, got: %v", "Alice", c.Name)
+			}
+
+			o, err := orders.Load(ctx, "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if o.Status != order.StatusPlaced || o.Version() != 1 {
+				t.Errorf("expected: %v at version %v, got: %v at version %v", order.StatusPlaced, 1, o.Status, o.Version())
+			}
+
+			loaded, err := store.Load(ctx, "customer", "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(loaded) != 1 || loaded[0].AggregateType != "customer" {
+				t.Errorf("expected a single customer event, got: %+v", loaded)
+			}
+
+			all, err := store.LoadAll(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(all) != 2 || all[0].AggregateType != order.AggregateTypeOrder || all[1].AggregateType != "customer" {
+				t.Errorf("expected an order and a customer event, got: %+v", all)
+			}
+		})
+	}
+}
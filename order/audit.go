@@ -0,0 +1,213 @@
+package order
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// AuditFormat selects how the audit exporter renders events.
+type AuditFormat int
+
+// Audit formats.
+const (
+	// AuditText renders one line per event:
+	//
+	//	2017-03-01T12:00:00Z order ABC123: placed with 1 line(s)
+	AuditText AuditFormat = iota
+
+	// AuditCSV renders one CSV record per event, with the columns
+	// timestamp, aggregate type, aggregate ID and description.
+	AuditCSV
+)
+
+// auditPageSize is the number of events read at a time by the audit exporter.
+const auditPageSize = 256
+
+// AuditFilter selects the events included in an audit trail. Zero fields
+// don't filter.
+type AuditFilter struct {
+	AggregateID string
+
+	// From and To delimit the time the events occurred, From inclusive and
+	// To exclusive.
+	From time.Time
+	To   time.Time
+}
+
+// match reports whether the event passes the filter.
+func (f AuditFilter) match(e PersistedEvent) bool {
+	switch {
+	case f.AggregateID != "" && e.AggregateID != f.AggregateID:
+		return false
+	case !f.From.IsZero() && e.OccurredAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.OccurredAt.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// defaultDescriptions describe the events provided by this package.
+var defaultDescriptions = map[reflect.Type]func(Event) string{
+	reflect.TypeOf(Placed{}): func(e Event) string {
+		return fmt.Sprintf("placed with %d line(s)", len(e.(Placed).Lines))
+	},
+	reflect.TypeOf(Activated{}): func(Event) string {
+		return "activated"
+	},
+	reflect.TypeOf(LineAdded{}): func(e Event) string {
+		l := e.(LineAdded).Line
+		return fmt.Sprintf("added %d x %s", l.Quantity, l.ProductID)
+	},
+	reflect.TypeOf(LineRemoved{}): func(e Event) string {
+		return fmt.Sprintf("removed %s", e.(LineRemoved).ProductID)
+	},
+	reflect.TypeOf(LineQuantityChanged{}): func(e Event) string {
+		evt := e.(LineQuantityChanged)
+		return fmt.Sprintf("changed %s to %d", evt.ProductID, evt.Quantity)
+	},
+	reflect.TypeOf(DiscountApplied{}): func(e Event) string {
+		evt := e.(DiscountApplied)
+		return fmt.Sprintf("discount %s of %d applied", evt.Code, evt.AmountCents)
+	},
+	reflect.TypeOf(Cancelled{}): func(Event) string {
+		return "cancelled"
+	},
+	reflect.TypeOf(Shipped{}): func(Event) string {
+		return "shipped"
+	},
+	reflect.TypeOf(Delivered{}): func(Event) string {
+		return "delivered"
+	},
+	reflect.TypeOf(Held{}): func(Event) string {
+		return "held"
+	},
+	reflect.TypeOf(Reactivated{}): func(Event) string {
+		return "reactivated"
+	},
+	reflect.TypeOf(Expired{}): func(Event) string {
+		return "expired"
+	},
+}
+
+// AuditExporter renders the events of an event store as a human-readable
+// audit trail.
+type AuditExporter struct {
+	store        EventStore
+	format       AuditFormat
+	descriptions map[reflect.Type]func(Event) string
+}
+
+// AuditOption configures an audit exporter.
+type AuditOption func(*AuditExporter)
+
+// WithAuditFormat sets the format of the audit trail. The default is
+// AuditText.
+func WithAuditFormat(f AuditFormat) AuditOption {
+	return func(x *AuditExporter) {
+		x.format = f
+	}
+}
+
+// WithDescription sets the function describing events of the same type as
+// the given event, replacing the default description, if any.
+func WithDescription(e Event, describe func(Event) string) AuditOption {
+	return func(x *AuditExporter) {
+		x.descriptions[reflect.TypeOf(e)] = describe
+	}
+}
+
+// NewAuditExporter returns a new exporter of the events in the store.
+func NewAuditExporter(store EventStore, opts ...AuditOption) *AuditExporter {
+	x := &AuditExporter{
+		store:        store,
+		descriptions: make(map[reflect.Type]func(Event) string, len(defaultDescriptions)),
+	}
+
+	for t, describe := range defaultDescriptions {
+		x.descriptions[t] = describe
+	}
+
+	for _, opt := range opts {
+		opt(x)
+	}
+
+	return x
+}
+
+// Export writes the events passing the filter to w in the order they were
+// saved, reading the global stream of the store one page at a time.
+func (x *AuditExporter) Export(ctx context.Context, w io.Writer, filter AuditFilter) error {
+	var cw *csv.Writer
+	if x.format == AuditCSV {
+		cw = csv.NewWriter(w)
+	}
+
+	var position int64
+	for {
+		events, err := x.store.LoadAllFrom(ctx, position, auditPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if !filter.match(e) {
+				continue
+			}
+
+			if err := x.write(w, cw, e); err != nil {
+				return err
+			}
+		}
+
+		if len(events) < auditPageSize {
+			break
+		}
+
+		position = events[len(events)-1].GlobalPosition
+	}
+
+	if cw != nil {
+		cw.Flush()
+		return cw.Error()
+	}
+
+	return nil
+}
+
+// write renders the event to w, or to cw if given.
+func (x *AuditExporter) write(w io.Writer, cw *csv.Writer, e PersistedEvent) error {
+	timestamp := e.OccurredAt.UTC().Format(time.RFC3339)
+
+	aggregateType := e.AggregateType
+	if aggregateType == "" {
+		aggregateType = AggregateTypeOrder
+	}
+
+	description := x.describe(e.Event)
+
+	if cw != nil {
+		return cw.Write([]string{timestamp, aggregateType, e.AggregateID, description})
+	}
+
+	_, err := fmt.Fprintf(w, "%s %s %s: %s\n", timestamp, aggregateType, e.AggregateID, description)
+	return err
+}
+
+// describe returns the description of the event, falling back to the name of
+// its type.
+func (x *AuditExporter) describe(e Event) string {
+	if describe, ok := x.descriptions[reflect.TypeOf(e)]; ok {
+		return describe(e)
+	}
+
+	if name, err := DefaultRegistry.Name(e); err == nil {
+		return name
+	}
+
+	return fmt.Sprintf("%T", e)
+}
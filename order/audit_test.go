@@ -0,0 +1,76 @@
+package order_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// auditedStore returns a store holding a placed-then-cancelled order and an
+// activated order, with each event occurring a minute after the previous one.
+func auditedStore(t *testing.T, start time.Time) order.EventStore {
+	t.Helper()
+
+	store := order.NewEventStore()
+	handler := order.NewCommandHandler(order.NewRepository(store), order.WithClock(&tickingClock{now: start}))
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.Place{OrderID: "XYZ789", Lines: testLines},
+		order.Cancel{OrderID: "ABC123"},
+		order.Activate{OrderID: "XYZ789"},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	return store
+}
+
+func TestAuditExporter(t *testing.T) {
+	start := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+	store := auditedStore(t, start)
+
+	var buf bytes.Buffer
+	if err := order.NewAuditExporter(store).Export(context.Background(), &buf, order.AuditFilter{AggregateID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "2017-03-01T12:01:00Z order ABC123: placed with 1 line(s)\n" +
+		"2017-03-01T12:03:00Z order ABC123: cancelled\n"
+
+	if buf.String() != want {
+		t.Errorf("expected: %q, got: %q", want, buf.String())
+	}
+}
+
+func TestAuditExporterCSV(t *testing.T) {
+	start := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+	store := auditedStore(t, start)
+
+	x := order.NewAuditExporter(store,
+		order.WithAuditFormat(order.AuditCSV),
+		order.WithDescription(order.Cancelled{}, func(order.Event) string { return "cancelled, by request" }),
+	)
+
+	filter := order.AuditFilter{
+		From: start.Add(2 * time.Minute),
+		To:   start.Add(4 * time.Minute),
+	}
+
+	var buf bytes.Buffer
+	if err := x.Export(context.Background(), &buf, filter); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "2017-03-01T12:02:00Z,order,XYZ789,placed with 1 line(s)\n" +
+		"2017-03-01T12:03:00Z,order,ABC123,\"cancelled, by request\"\n"
+
+	if buf.String() != want {
+		t.Errorf("expected: %q, got: %q", want, buf.String())
+	}
+}
@@ -0,0 +1,71 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrUnauthorized is returned when the authorizer denies a command.
+var ErrUnauthorized = errors.New("unauthorized")
+
+type userKey struct{}
+
+// WithUserID returns a copy of the context carrying the ID of the user issuing
+// commands, for authorizers to decide on.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// UserIDFromContext returns the ID of the user carried by the context, if any.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userKey{}).(string)
+	return userID, ok && userID != ""
+}
+
+// Authorizer decides whether the command may be handled on behalf of the user
+// carried by the context, see WithUserID.
+type Authorizer interface {
+	Authorize(ctx context.Context, cmd interface{}) error
+}
+
+// AuthorizerFunc is an adapter allowing the use of ordinary functions as
+// authorizers.
+type AuthorizerFunc func(ctx context.Context, cmd interface{}) error
+
+// Authorize calls f(ctx, cmd).
+func (f AuthorizerFunc) Authorize(ctx context.Context, cmd interface{}) error {
+	return f(ctx, cmd)
+}
+
+// AllowAll is an authorizer allowing every command.
+var AllowAll Authorizer = AuthorizerFunc(func(context.Context, interface{}) error {
+	return nil
+})
+
+// AuthMiddleware asks the authorizer whether each command may be handled
+// before passing it on to the next handler. Commands wrapped in a
+// CommandEnvelope are authorized by the wrapped command, with the tenant and
+// the user of the envelope carried by the context. A denied command
+// returns ErrUnauthorized, wrapping the error of the authorizer unless it
+// already is ErrUnauthorized.
+func AuthMiddleware(a Authorizer) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+			cmd := c
+			if env, ok := c.(CommandEnvelope); ok {
+				ctx = envelopeContext(ctx, env)
+				cmd = env.Command
+			}
+
+			if err := a.Authorize(ctx, cmd); err != nil {
+				if errors.Is(err, ErrUnauthorized) {
+					return err
+				}
+				return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+			}
+
+			return next.Handle(ctx, c)
+		})
+	}
+}
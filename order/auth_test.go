@@ -0,0 +1,92 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	store := order.NewEventStore()
+
+	policy := order.AuthorizerFunc(func(ctx context.Context, cmd interface{}) error {
+		if _, ok := order.UserIDFromContext(ctx); !ok {
+			return order.ErrUnauthorized
+		}
+		if _, ok := cmd.(order.Cancel); ok {
+			return errors.New("cancelling is not allowed")
+		}
+		return nil
+	})
+
+	handler := order.Chain(order.NewCommandHandler(order.NewRepository(store)), order.AuthMiddleware(policy))
+
+	ctx := order.WithUserID(context.Background(), "alice")
+
+	if err := handler.Handle(ctx, order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := handler.Handle(ctx, order.Cancel{OrderID: "ABC123"}); !errors.Is(err, order.ErrUnauthorized) {
+		t.Errorf("expected: %v, got: %v", order.ErrUnauthorized, err)
+	}
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "XYZ789", Lines: testLines}); !errors.Is(err, order.ErrUnauthorized) {
+		t.Errorf("expected: %v, got: %v", order.ErrUnauthorized, err)
+	}
+
+	events, err := store.LoadAll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(events))
+	}
+
+	if _, ok := events[0].Event.(order.Placed); !ok {
+		t.Errorf("expected: %T, got: %T", order.Placed{}, events[0].Event)
+	}
+}
+
+func TestAuthMiddlewareEnvelope(t *testing.T) {
+	var users []string
+	policy := order.AuthorizerFunc(func(ctx context.Context, cmd interface{}) error {
+		user, ok := order.UserIDFromContext(ctx)
+		if !ok {
+			return order.ErrUnauthorized
+		}
+		users = append(users, user)
+		return nil
+	})
+
+	handler := order.Chain(
+		order.NewCommandHandler(order.NewRepository(order.NewEventStore())),
+		order.ValidationMiddleware(),
+		order.AuthMiddleware(policy),
+		order.DedupMiddleware(order.NewDedupStore(), false),
+	)
+
+	// The user is only carried by the envelope, not by the context.
+	env := order.CommandEnvelope{
+		CommandID: "cmd-1",
+		UserID:    "alice",
+		Command:   order.Place{OrderID: "ABC123", Lines: testLines},
+	}
+
+	if err := handler.Handle(context.Background(), env); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(users) != 1 || users[0] != "alice" {
+		t.Errorf("expected: %v, got: %v", []string{"alice"}, users)
+	}
+}
+
+func TestAllowAll(t *testing.T) {
+	if err := order.AllowAll.Authorize(context.Background(), order.Cancel{OrderID: "ABC123"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPublisherClosed is returned when publishing events to a closed
+// publisher.
+var ErrPublisherClosed = errors.New("publisher is closed")
+
+// Publisher publishes committed events to other systems, such as a message
+// broker.
+type Publisher interface {
+	Publish(ctx context.Context, events []PersistedEvent) error
+}
+
+// BatchOption configures a batching publisher.
+type BatchOption func(*BatchingPublisher)
+
+// WithBatchSize sets the number of buffered events that triggers a flush. The
+// default is 100.
+func WithBatchSize(n int) BatchOption {
+	return func(p *BatchingPublisher) {
+		p.size = n
+	}
+}
+
+// WithFlushDelay sets the maximum time an event is buffered before it is
+// flushed. The default is one second.
+func WithFlushDelay(d time.Duration) BatchOption {
+	return func(p *BatchingPublisher) {
+		p.delay = d
+	}
+}
+
+// WithBatchClock sets the clock used to decide when buffered events are due
+// to be flushed.
+func WithBatchClock(c Clock) BatchOption {
+	return func(p *BatchingPublisher) {
+		p.clock = c
+	}
+}
+
+// BatchingPublisher buffers events and publishes them in batches, flushing
+// once the batch size has been reached or the oldest buffered event has
+// waited for the flush delay, whichever comes first. Events that fail to be
+// flushed stay buffered until the next flush. The publisher is safe for
+// concurrent use by multiple goroutines.
+type BatchingPublisher struct {
+	publisher Publisher
+	size      int
+	delay     time.Duration
+	clock     Clock
+
+	mu     sync.Mutex
+	buffer []PersistedEvent
+	oldest time.Time
+	closed bool
+}
+
+// NewBatchingPublisher returns a new publisher publishing batches of events
+// to p.
+func NewBatchingPublisher(p Publisher, opts ...BatchOption) *BatchingPublisher {
+	b := &BatchingPublisher{
+		publisher: p,
+		size:      100,
+		delay:     time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// Publish buffers the events, flushing the buffer if it is full or due.
+func (b *BatchingPublisher) Publish(ctx context.Context, events []PersistedEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrPublisherClosed
+	}
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	if len(b.buffer) == 0 {
+		b.oldest = now(b.clock)
+	}
+	b.buffer = append(b.buffer, events...)
+
+	if len(b.buffer) >= b.size || b.due() {
+		return b.flush(ctx)
+	}
+
+	return nil
+}
+
+// Flush publishes the buffered events.
+func (b *BatchingPublisher) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.flush(ctx)
+}
+
+// Tick flushes the buffered events if the oldest of them has waited for the
+// flush delay.
+func (b *BatchingPublisher) Tick(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.due() {
+		return nil
+	}
+
+	return b.flush(ctx)
+}
+
+// Run ticks every tenth of the flush delay until the context is cancelled.
+func (b *BatchingPublisher) Run(ctx context.Context) error {
+	interval := b.delay / 10
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if err := b.Tick(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+	}
+}
+
+// Close flushes the remaining events and stops the publisher from accepting
+// new events.
+func (b *BatchingPublisher) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+
+	return b.flush(context.Background())
+}
+
+// due reports whether the oldest buffered event has waited for the flush
+// delay. The caller must hold the lock.
+func (b *BatchingPublisher) due() bool {
+	return len(b.buffer) > 0 && !now(b.clock).Before(b.oldest.Add(b.delay))
+}
+
+// flush publishes the buffered events. The caller must hold the lock.
+func (b *BatchingPublisher) flush(ctx context.Context) error {
+	if len(b.buffer) == 0 {
+		return nil
+	}
+
+	if err := b.publisher.Publish(ctx, b.buffer); err != nil {
+		return err
+	}
+
+	b.buffer = nil
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// batchRecorder records the batches of events published to it.
+type batchRecorder struct {
+	batches [][]order.PersistedEvent
+}
+
+func (r *batchRecorder) Publish(ctx context.Context, events []order.PersistedEvent) error {
+	r.batches = append(r.batches, events)
+	return nil
+}
+
+func placedEvent(id string) []order.PersistedEvent {
+	return []order.PersistedEvent{{Event: order.Placed{OrderID: order.OrderID(id), Lines: testLines}, AggregateID: id}}
+}
+
+func TestBatchingPublisherFlushesFullBatch(t *testing.T) {
+	var rec batchRecorder
+	p := order.NewBatchingPublisher(&rec, order.WithBatchSize(2))
+
+	for _, id := range []string{"ABC123", "XYZ789", "DEF456"} {
+		if err := p.Publish(context.Background(), placedEvent(id)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(rec.batches) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(rec.batches))
+	}
+	if len(rec.batches[0]) != 2 {
+		t.Errorf("expected: %v, got: %v", 2, len(rec.batches[0]))
+	}
+}
+
+func TestBatchingPublisherFlushesAfterDelay(t *testing.T) {
+	clock := &manualClock{now: time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)}
+
+	var rec batchRecorder
+	p := order.NewBatchingPublisher(&rec, order.WithFlushDelay(time.Minute), order.WithBatchClock(clock))
+
+	if err := p.Publish(context.Background(), placedEvent("ABC123")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clock.Advance(30 * time.Second)
+
+	if err := p.Tick(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rec.batches) != 0 {
+		t.Fatalf("expected: %v, got: %v", 0, len(rec.batches))
+	}
+
+	clock.Advance(30 * time.Second)
+
+	if err := p.Tick(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rec.batches) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(rec.batches))
+	}
+
+	// A new batch is timed from its first event.
+	if err := p.Publish(context.Background(), placedEvent("XYZ789")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.Tick(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rec.batches) != 1 {
+		t.Errorf("expected: %v, got: %v", 1, len(rec.batches))
+	}
+}
+
+func TestBatchingPublisherFlushesOnClose(t *testing.T) {
+	var rec batchRecorder
+	p := order.NewBatchingPublisher(&rec)
+
+	if err := p.Publish(context.Background(), placedEvent("ABC123")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rec.batches) != 1 || len(rec.batches[0]) != 1 {
+		t.Errorf("expected a single batch of one event, got: %v", rec.batches)
+	}
+
+	if err := p.Publish(context.Background(), placedEvent("XYZ789")); !errors.Is(err, order.ErrPublisherClosed) {
+		t.Errorf("expected: %v, got: %v", order.ErrPublisherClosed, err)
+	}
+}
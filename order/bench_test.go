@@ -0,0 +1,129 @@
+package order_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// benchmarkSizes are the number of events per order in the benchmarks.
+var benchmarkSizes = []int{10, 1000, 100000}
+
+// history returns the events of an order placed with one line and amended
+// with n-1 more.
+func history(id string, n int) []order.PersistedEvent {
+	events := make([]order.PersistedEvent, n)
+	events[0] = order.PersistedEvent{Event: order.Placed{OrderID: order.OrderID(id), Lines: testLines}}
+	for i := 1; i < n; i++ {
+		events[i] = order.PersistedEvent{Event: order.LineAdded{OrderID: order.OrderID(id), Line: testLines[0]}}
+	}
+	return events
+}
+
+// amendedOrder returns an unsaved order with n uncommitted events.
+func amendedOrder(b *testing.B, id string, n int) order.Order {
+	b.Helper()
+
+	o := order.NewOrder(order.OrderID(id))
+	if err := o.Place(testLines); err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	for i := 1; i < n; i++ {
+		if err := o.AddLine(testLines[0]); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+	return o
+}
+
+func BenchmarkSave(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprintf("events=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				repo := order.NewRepository(order.NewEventStore())
+				o := amendedOrder(b, "ABC123", n)
+				b.StartTimer()
+
+				if err := repo.Save(context.Background(), &o); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprintf("events=%d", n), func(b *testing.B) {
+			store := order.NewEventStore()
+			if err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, history("ABC123", n)); err != nil {
+				b.Fatalf("unexpected error: %v", err)
+			}
+
+			repo := order.NewRepository(store)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.Load(context.Background(), "ABC123"); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLoadFromHistory measures rebuilding an order from events that have
+// already been loaded.
+func BenchmarkLoadFromHistory(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprintf("events=%d", n), func(b *testing.B) {
+			events := history("ABC123", n)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				var o order.Order
+				for _, e := range events {
+					if err := o.Apply(e.Event); err != nil {
+						b.Fatalf("unexpected error: %v", err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLoadMany(b *testing.B) {
+	for _, n := range benchmarkSizes[:2] {
+		b.Run(fmt.Sprintf("events=%d", n), func(b *testing.B) {
+			store := order.NewEventStore()
+
+			ids := make([]string, 100)
+			for i := range ids {
+				ids[i] = fmt.Sprintf("order-%d", i)
+				if err := store.Save(context.Background(), order.AggregateTypeOrder, ids[i], 0, history(ids[i], n)); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			repo := order.NewRepository(store)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.LoadMany(context.Background(), ids); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
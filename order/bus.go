@@ -0,0 +1,85 @@
+package order
+
+import (
+	"context"
+	"sync"
+)
+
+// EventBus publishes committed events to its subscribers.
+type EventBus interface {
+	// Publish delivers the events to the subscribers. It returns ErrClosed,
+	// without delivering any of them, once the bus has been closed.
+	Publish(events []PersistedEvent) error
+
+	Subscribe(handler func(PersistedEvent))
+
+	// SubscribeFiltered registers a handler to receive only the published
+	// events matching the filter.
+	SubscribeFiltered(filter EventFilter, handler func(PersistedEvent))
+
+	// Close stops the bus from publishing events, and waits for the events
+	// being published to be handled, or for the context to be done.
+	Close(ctx context.Context) error
+}
+
+type busSubscriber struct {
+	filter  EventFilter
+	handler func(PersistedEvent)
+}
+
+type eventBus struct {
+	mu          sync.RWMutex
+	subscribers []busSubscriber
+	life        lifecycle
+}
+
+// Publish delivers every event, in order, to each of the subscribers whose
+// filter matches it before returning. Once the bus has been closed, the
+// events are dropped and ErrClosed is returned.
+func (b *eventBus) Publish(events []PersistedEvent) error {
+	if err := b.life.enter(); err != nil {
+		return err
+	}
+	defer b.life.exit()
+
+	b.mu.RLock()
+	subscribers := make([]busSubscriber, len(b.subscribers))
+	copy(subscribers, b.subscribers)
+	b.mu.RUnlock()
+
+	for _, e := range events {
+		for _, s := range subscribers {
+			if s.filter == nil || s.filter(e) {
+				s.handler(e)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Subscribe registers a handler to receive all events published after it has
+// subscribed.
+func (b *eventBus) Subscribe(handler func(PersistedEvent)) {
+	b.SubscribeFiltered(nil, handler)
+}
+
+// SubscribeFiltered registers a handler to receive the events matching the
+// filter published after it has subscribed. A nil filter matches all events.
+func (b *eventBus) SubscribeFiltered(filter EventFilter, handler func(PersistedEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers = append(b.subscribers, busSubscriber{filter: filter, handler: handler})
+}
+
+// Close stops the bus from publishing events, and waits for the events being
+// published to be handled, or for the context to be done.
+func (b *eventBus) Close(ctx context.Context) error {
+	return b.life.close(ctx)
+}
+
+// NewEventBus returns a new instance of the default synchronous event bus.
+func NewEventBus() EventBus {
+	return &eventBus{}
+}
@@ -0,0 +1,144 @@
+package order_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestEventBusPublishesCommittedEvents(t *testing.T) {
+	bus := order.NewEventBus()
+
+	var received []order.PersistedEvent
+	bus.Subscribe(func(e order.PersistedEvent) {
+		received = append(received, e)
+	})
+
+	repo := order.NewRepository(order.NewEventStore(), order.WithEventBus(bus))
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(received))
+	}
+
+	if _, ok := received[0].Event.(order.Placed); !ok {
+		t.Errorf("expected: %T, got: %T", order.Placed{}, received[0].Event)
+	}
+	if _, ok := received[1].Event.(order.Activated); !ok {
+		t.Errorf("expected: %T, got: %T", order.Activated{}, received[1].Event)
+	}
+
+	for i, e := range received {
+		if e.Sequence != i+1 {
+			t.Errorf("expected: %v, got: %v", i+1, e.Sequence)
+		}
+	}
+}
+
+func TestEventBusNotPublishedOnFailedSave(t *testing.T) {
+	bus := order.NewEventBus()
+
+	var received int
+	bus.Subscribe(func(e order.PersistedEvent) {
+		received++
+	})
+
+	store := order.NewEventStore()
+	repo := order.NewRepository(store, order.WithEventBus(bus))
+
+	o := order.NewOrder("ABC123")
+	if err := o.Place(testLines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, []order.PersistedEvent{{Event: order.Placed{OrderID: "ABC123"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := repo.Save(context.Background(), &o); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if received != 0 {
+		t.Errorf("expected: %v, got: %v", 0, received)
+	}
+}
+
+func TestEventBusSubscribeFiltered(t *testing.T) {
+	bus := order.NewEventBus()
+
+	var activated, abc []order.PersistedEvent
+	bus.SubscribeFiltered(order.ByType(order.Activated{}), func(e order.PersistedEvent) {
+		activated = append(activated, e)
+	})
+	bus.SubscribeFiltered(order.ByAggregate("ABC123"), func(e order.PersistedEvent) {
+		abc = append(abc, e)
+	})
+
+	handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore(), order.WithEventBus(bus)))
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.Place{OrderID: "XYZ789", Lines: testLines},
+		order.Activate{OrderID: "ABC123"},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(activated) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(activated))
+	}
+	if _, ok := activated[0].Event.(order.Activated); !ok {
+		t.Errorf("expected: %T, got: %T", order.Activated{}, activated[0].Event)
+	}
+
+	if len(abc) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(abc))
+	}
+	for _, e := range abc {
+		if e.AggregateID != "ABC123" {
+			t.Errorf("expected: %v, got: %v", "ABC123", e.AggregateID)
+		}
+	}
+}
+
+func TestEventBusNotPublishedForSkippedEvents(t *testing.T) {
+	bus := order.NewEventBus()
+
+	var received int
+	bus.Subscribe(func(e order.PersistedEvent) {
+		received++
+	})
+
+	repo := order.NewRepository(order.NewEventStore(), order.WithEventBus(bus))
+
+	o := order.NewOrder("ABC123")
+	if err := o.Place(testLines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A copy of the order still holding the saved events as uncommitted,
+	// as if saving it was retried.
+	retried := o
+
+	if err := repo.Save(context.Background(), &o); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.Save(context.Background(), &retried); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if received != 1 {
+		t.Errorf("expected: %v, got: %v", 1, received)
+	}
+}
@@ -0,0 +1,49 @@
+package order
+
+import "sync"
+
+// CheckpointStore keeps track of how far projections have processed the
+// global stream of events, so that they can resume from where they left off.
+type CheckpointStore interface {
+	// Save records the global position of the last event processed by the
+	// projection.
+	Save(projectionName string, position int64) error
+
+	// Load returns the global position of the last event processed by the
+	// projection, or zero if it hasn't saved a checkpoint.
+	Load(projectionName string) (int64, error)
+}
+
+type checkpointStore struct {
+	mu        sync.RWMutex
+	positions map[string]int64
+}
+
+// Save records the global position of the last event processed by the
+// projection.
+func (s *checkpointStore) Save(projectionName string, position int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.positions[projectionName] = position
+
+	return nil
+}
+
+// Load returns the global position of the last event processed by the
+// projection, or zero if it hasn't saved a checkpoint.
+func (s *checkpointStore) Load(projectionName string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.positions[projectionName], nil
+}
+
+// NewCheckpointStore returns a new instance of the default in-memory
+// checkpoint store. The store is safe for concurrent use by multiple
+// goroutines.
+func NewCheckpointStore() CheckpointStore {
+	return &checkpointStore{
+		positions: make(map[string]int64),
+	}
+}
@@ -0,0 +1,24 @@
+package order
+
+import "time"
+
+// Clock provides the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default clock, backed by time.Now.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// now returns the current time of the clock, falling back to the system clock
+// if none was given.
+func now(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
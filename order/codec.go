@@ -0,0 +1,111 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// ErrUnknownEventType is returned when decoding an event whose type name has
+// not been registered.
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// RegisterEvent makes an event type available for encoding and decoding under
+// the given name. The proto is only used to determine the type of the event.
+// RegisterEvent panics if the name is already registered.
+func RegisterEvent(name string, proto Event) {
+	t := reflect.TypeOf(proto)
+
+	factory := func() Event {
+		return reflect.Zero(t).Interface().(Event)
+	}
+
+	if err := DefaultRegistry.Register(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// wrappedEvent is implemented by the events that decorating event stores save
+// in place of the original event, e.g. holding its encrypted payload. They are
+// stored under the type name of the original event.
+type wrappedEvent interface {
+	Event
+	typeName() string
+}
+
+// MarshalEvent returns the JSON encoding of the event along with the name it
+// was registered under.
+func MarshalEvent(e Event) ([]byte, string, error) {
+	if w, ok := e.(wrappedEvent); ok {
+		data, err := json.Marshal(w)
+		return data, w.typeName(), err
+	}
+
+	name, err := DefaultRegistry.Name(e)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, name, nil
+}
+
+// UnmarshalEvent decodes the JSON encoded data, in the current schema version
+// of the registered type, into a new event of the type.
+func UnmarshalEvent(typeName string, data []byte) (Event, error) {
+	version, err := DefaultRegistry.version(typeName)
+	if err != nil {
+		return nil, err
+	}
+	return DefaultRegistry.Unmarshal(typeName, version, data)
+}
+
+// UnmarshalEventVersion decodes the JSON encoded data, stored with the given
+// schema version, into a new event of the registered type. The data is upcast
+// to the current version using the upcasters of the default registry.
+func UnmarshalEventVersion(typeName string, version int, data []byte) (Event, error) {
+	return DefaultRegistry.Unmarshal(typeName, version, data)
+}
+
+// schemaVersion returns the schema version recorded for the event, which is
+// the current version of its type unless stamped when the event was created.
+func schemaVersion(e PersistedEvent) int {
+	if e.SchemaVersion != 0 {
+		return e.SchemaVersion
+	}
+	version, _ := DefaultRegistry.SchemaVersion(e.Event)
+	return version
+}
+
+// decodeEvent decodes an event read from storage, returning it along with the
+// schema version it was upcast to. Events stored before schema versions were
+// recorded have version 1. Encrypted and compressed payloads are returned as
+// they are, to be decoded by the decorating event store that wrote them.
+func decodeEvent(typeName string, version int, data []byte) (Event, int, error) {
+	if version == 0 {
+		version = 1
+	}
+
+	if sealed, ok := parseSealed(typeName, data); ok {
+		return sealed, version, nil
+	}
+	if compressed, ok := parseCompressed(typeName, data); ok {
+		return compressed, version, nil
+	}
+
+	e, err := UnmarshalEventVersion(typeName, version, data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	current, err := DefaultRegistry.version(typeName)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return e, current, nil
+}
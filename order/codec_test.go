@@ -0,0 +1,144 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestMarshalEventRoundTrip(t *testing.T) {
+	tests := []struct {
+		event order.Event
+		name  string
+	}{
+		{event: order.Placed{OrderID: "ABC123", Lines: testLines}, name: "order.Placed"},
+		{event: order.Activated{OrderID: "ABC123"}, name: "order.Activated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, name, err := order.MarshalEvent(tt.event)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if name != tt.name {
+				t.Errorf("expected: %v, got: %v", tt.name, name)
+			}
+
+			got, err := order.UnmarshalEvent(name, data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.event) {
+				t.Errorf("expected: %#v, got: %#v", tt.event, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshalUnknownEvent(t *testing.T) {
+	_, err := order.UnmarshalEvent("order.Unknown", []byte(`{}`))
+	if !errors.Is(err, order.ErrUnknownEventType) {
+		t.Errorf("expected: %v, got: %v", order.ErrUnknownEventType, err)
+	}
+}
+
+func TestSchemaVersionStored(t *testing.T) {
+	fileStore, err := order.NewFileEventStore(filepath.Join(t.TempDir(), "events.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sqliteStore, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	stores := map[string]order.EventStore{
+		"memory": order.NewEventStore(),
+		"file":   fileStore,
+		"sqlite": sqliteStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			handler := order.NewCommandHandler(order.NewRepository(store))
+
+			for _, cmd := range []interface{}{
+				order.Place{OrderID: "ABC123", Lines: testLines},
+				order.Activate{OrderID: "ABC123"},
+			} {
+				if err := handler.Handle(context.Background(), cmd); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			events, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, e := range events {
+				if e.SchemaVersion != 1 {
+					t.Errorf("%T: expected: %v, got: %v", e.Event, 1, e.SchemaVersion)
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshalStoredSchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	line := `{"aggregate_id":"ABC123","sequence":1,"global_sequence":1,"type":"order.Activated","payload":{"OrderID":"ABC123"},"schema_version":2}` + "\n"
+	if err := os.WriteFile(path, []byte(line), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store, err := order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123"); !errors.Is(err, order.ErrUnsupportedSchemaVersion) {
+		t.Errorf("expected: %v, got: %v", order.ErrUnsupportedSchemaVersion, err)
+	}
+}
+
+func TestRegistrySchemaVersion(t *testing.T) {
+	r := order.NewRegistry()
+
+	if err := r.RegisterVersion("test.Dispatched", 2, func() order.Event { return dispatched{} }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	version, err := r.SchemaVersion(dispatched{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != 2 {
+		t.Errorf("expected: %v, got: %v", 2, version)
+	}
+
+	for _, v := range []int{1, 2} {
+		e, err := r.Unmarshal("test.Dispatched", v, []byte(`{"OrderID":"ABC123"}`))
+		if err != nil {
+			t.Fatalf("unexpected error for version %d: %v", v, err)
+		}
+		if e != (dispatched{OrderID: "ABC123"}) {
+			t.Errorf("expected: %v, got: %v", dispatched{OrderID: "ABC123"}, e)
+		}
+	}
+
+	if _, err := r.Unmarshal("test.Dispatched", 3, []byte(`{}`)); !errors.Is(err, order.ErrUnsupportedSchemaVersion) {
+		t.Errorf("expected: %v, got: %v", order.ErrUnsupportedSchemaVersion, err)
+	}
+}
@@ -0,0 +1,101 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrHandlerAlreadyRegistered is returned when registering a second handler
+// for the same command type.
+var ErrHandlerAlreadyRegistered = errors.New("command handler already registered")
+
+// CommandBus routes commands to handlers registered for their type. Unlike the
+// default command handler, it allows consumers to handle their own commands
+// without modifying this package.
+type CommandBus struct {
+	mu       sync.RWMutex
+	handlers map[reflect.Type]func(context.Context, interface{}) error
+}
+
+// NewCommandBus returns a new command bus without any registered handlers.
+func NewCommandBus() *CommandBus {
+	return &CommandBus{
+		handlers: make(map[reflect.Type]func(context.Context, interface{}) error),
+	}
+}
+
+// Register makes fn handle all commands of the same type as the sample.
+func (b *CommandBus) Register(sample interface{}, fn func(ctx context.Context, cmd interface{}) error) error {
+	t := reflect.TypeOf(sample)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.handlers[t]; ok {
+		return fmt.Errorf("%w: %v", ErrHandlerAlreadyRegistered, t)
+	}
+
+	b.handlers[t] = fn
+
+	return nil
+}
+
+// Dispatch routes the command to the handler registered for its type, and
+// returns the events committed by repositories while handling it. Commands
+// in a CommandEnvelope are routed by the type of the wrapped command, and the
+// handler is given the envelope along with a context carrying its tenant and
+// user.
+func (b *CommandBus) Dispatch(ctx context.Context, cmd interface{}) ([]PersistedEvent, error) {
+	t := reflect.TypeOf(cmd)
+	if env, ok := cmd.(CommandEnvelope); ok {
+		t = reflect.TypeOf(env.Command)
+		ctx = envelopeContext(ctx, env)
+	}
+
+	b.mu.RLock()
+	fn, ok := b.handlers[t]
+	b.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownCommand, t)
+	}
+
+	c := &committedEvents{}
+	if err := fn(context.WithValue(ctx, committedKey{}, c), cmd); err != nil {
+		return nil, err
+	}
+
+	return c.events, nil
+}
+
+// Handle dispatches the command, allowing the bus to be used wherever a
+// CommandHandler is expected.
+func (b *CommandBus) Handle(ctx context.Context, cmd interface{}) error {
+	_, err := b.Dispatch(ctx, cmd)
+	return err
+}
+
+type committedKey struct{}
+
+// committedEvents collects the events committed while dispatching a command.
+type committedEvents struct {
+	mu     sync.Mutex
+	events []PersistedEvent
+}
+
+// recordCommitted adds the events to those committed by the command being
+// dispatched, if any. Repositories call it once the events have been saved.
+func recordCommitted(ctx context.Context, events []PersistedEvent) {
+	c, ok := ctx.Value(committedKey{}).(*committedEvents)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.events = append(c.events, events...)
+}
@@ -0,0 +1,115 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+type refund struct {
+	OrderID string
+}
+
+func TestCommandBusDispatch(t *testing.T) {
+	bus := order.NewCommandBus()
+
+	var refunded []string
+	if err := bus.Register(refund{}, func(ctx context.Context, cmd interface{}) error {
+		refunded = append(refunded, cmd.(refund).OrderID)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := bus.Dispatch(context.Background(), refund{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(refunded) != 1 || refunded[0] != "ABC123" {
+		t.Errorf("expected: %v, got: %v", []string{"ABC123"}, refunded)
+	}
+}
+
+func TestCommandBusOrderCommands(t *testing.T) {
+	repo := order.NewRepository(order.NewEventStore())
+	handler := order.NewCommandHandler(repo)
+
+	bus := order.NewCommandBus()
+	for _, sample := range []interface{}{order.Place{}, order.Activate{}} {
+		if err := bus.Register(sample, handler.Handle); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if _, err := bus.Dispatch(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := bus.Dispatch(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+	}
+}
+
+func TestCommandBusDuplicateRegistration(t *testing.T) {
+	bus := order.NewCommandBus()
+
+	noop := func(ctx context.Context, cmd interface{}) error { return nil }
+
+	if err := bus.Register(refund{}, noop); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := bus.Register(refund{}, noop); !errors.Is(err, order.ErrHandlerAlreadyRegistered) {
+		t.Errorf("expected: %v, got: %v", order.ErrHandlerAlreadyRegistered, err)
+	}
+}
+
+func TestCommandBusUnknownCommand(t *testing.T) {
+	bus := order.NewCommandBus()
+
+	_, err := bus.Dispatch(context.Background(), refund{OrderID: "ABC123"})
+	if !errors.Is(err, order.ErrUnknownCommand) {
+		t.Errorf("expected: %v, got: %v", order.ErrUnknownCommand, err)
+	}
+}
+
+func TestCommandBusDispatchReturnsEvents(t *testing.T) {
+	handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore()))
+
+	bus := order.NewCommandBus()
+	if err := bus.Register(order.Place{}, handler.Handle); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := bus.Dispatch(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(events))
+	}
+
+	placed, ok := events[0].Event.(order.Placed)
+	if !ok {
+		t.Fatalf("expected: %T, got: %T", order.Placed{}, events[0].Event)
+	}
+
+	if placed.OrderID != "ABC123" || events[0].AggregateID != "ABC123" {
+		t.Errorf("expected: %v, got: %v", "ABC123", placed.OrderID)
+	}
+
+	if events[0].Sequence != 1 {
+		t.Errorf("expected: %v, got: %v", 1, events[0].Sequence)
+	}
+}
@@ -0,0 +1,325 @@
+package order
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// defaultCompressionThreshold is the payload size, in bytes, above which
+// payloads are compressed unless configured otherwise.
+const defaultCompressionThreshold = 1024
+
+// compressedEvent holds the gzip-compressed payload of an event in place of
+// the event itself. It is encoded as a JSON object with a single "$gzip"
+// field.
+type compressedEvent struct {
+	name        string
+	aggregateID string
+
+	Payload []byte `json:"$gzip"`
+}
+
+// ID returns the identifier of the aggregate the event belongs to.
+func (e compressedEvent) ID() string {
+	return e.aggregateID
+}
+
+func (e compressedEvent) typeName() string {
+	return e.name
+}
+
+// parseCompressed returns the compressed event encoded by the payload of an
+// event stored under the given type name, if any.
+func parseCompressed(name string, data []byte) (compressedEvent, bool) {
+	if !bytes.Contains(data, []byte(`"$gzip"`)) {
+		return compressedEvent{}, false
+	}
+
+	var e compressedEvent
+	if err := json.Unmarshal(data, &e); err != nil || e.Payload == nil {
+		return compressedEvent{}, false
+	}
+	e.name = name
+
+	return e, true
+}
+
+// CompressionOption configures a compressing event store.
+type CompressionOption func(*compressingEventStore)
+
+// WithCompressionThreshold sets the payload size, in bytes, above which
+// payloads are compressed. The default is 1024 bytes.
+func WithCompressionThreshold(n int) CompressionOption {
+	return func(s *compressingEventStore) {
+		s.threshold = n
+	}
+}
+
+// WithCompressionLevel sets the gzip compression level, e.g.
+// gzip.BestCompression. The default is gzip.DefaultCompression.
+func WithCompressionLevel(level int) CompressionOption {
+	return func(s *compressingEventStore) {
+		s.level = level
+	}
+}
+
+type compressingEventStore struct {
+	store     EventStore
+	threshold int
+	level     int
+}
+
+// compress replaces the event with its compressed payload if the payload is
+// larger than the threshold.
+func (s *compressingEventStore) compress(e PersistedEvent, id string) (PersistedEvent, error) {
+	payload, name, err := MarshalEvent(e.Event)
+	if err != nil {
+		return e, err
+	}
+
+	if len(payload) <= s.threshold {
+		return e, nil
+	}
+
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, s.level)
+	if err != nil {
+		return e, err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return e, err
+	}
+	if err := w.Close(); err != nil {
+		return e, err
+	}
+
+	e.SchemaVersion = schemaVersion(e)
+	e.Event = compressedEvent{name: name, aggregateID: id, Payload: buf.Bytes()}
+
+	return e, nil
+}
+
+// decompress replaces the compressed payload of the event with the decoded
+// event. Events stored uncompressed are returned as they are.
+func (s *compressingEventStore) decompress(e PersistedEvent) (PersistedEvent, error) {
+	compressed, ok := e.Event.(compressedEvent)
+	if !ok {
+		return e, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(compressed.Payload))
+	if err != nil {
+		return e, err
+	}
+	defer r.Close()
+
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return e, err
+	}
+
+	e.Event, e.SchemaVersion, err = decodeEvent(compressed.name, e.SchemaVersion, payload)
+	if err != nil {
+		return e, err
+	}
+
+	return e, nil
+}
+
+// decompressAll decompresses the events in place.
+func (s *compressingEventStore) decompressAll(events []PersistedEvent) ([]PersistedEvent, error) {
+	for i, e := range events {
+		decompressed, err := s.decompress(e)
+		if err != nil {
+			return nil, err
+		}
+		events[i] = decompressed
+	}
+	return events, nil
+}
+
+// Save compresses the large payloads of the events before saving them.
+func (s *compressingEventStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []PersistedEvent) error {
+	compressed := make([]PersistedEvent, len(events))
+	for i, e := range events {
+		var err error
+		if compressed[i], err = s.compress(e, id); err != nil {
+			return err
+		}
+	}
+
+	return s.store.Save(ctx, aggregateType, id, expectedVersion, compressed)
+}
+
+// SaveAll compresses the large payloads of the events of every aggregate
+// before saving them in a single transaction, if the underlying store supports
+// it.
+func (s *compressingEventStore) SaveAll(ctx context.Context, changes []AggregateChanges) error {
+	store, ok := s.store.(TransactionalStore)
+	if !ok {
+		return ErrTransactionsNotSupported
+	}
+
+	compressed := make([]AggregateChanges, len(changes))
+	for i, c := range changes {
+		events := make([]PersistedEvent, len(c.Events))
+		for j, e := range c.Events {
+			var err error
+			if events[j], err = s.compress(e, c.AggregateID); err != nil {
+				return err
+			}
+		}
+
+		c.Events = events
+		compressed[i] = c
+	}
+
+	return store.SaveAll(ctx, compressed)
+}
+
+// Subscribe subscribes the handler to the decompressed events of the underlying
+// store, if it supports subscriptions.
+func (s *compressingEventStore) Subscribe(fromPosition int64, handler func(PersistedEvent) error, opts ...SubscriptionOption) (*Subscription, error) {
+	return subscribeDecoded(s.store, fromPosition, s.decompress, handler, opts)
+}
+
+// Redact redacts the fields in the events of the aggregate in the underlying
+// store, if it supports redaction. The underlying store can't see the fields
+// of compressed payloads, so rather than leaving them in place, nothing is
+// redacted and ErrRedactionNotSupported is returned if any event of the
+// aggregate is stored compressed.
+func (s *compressingEventStore) Redact(ctx context.Context, aggregateType, id string, fields []string) error {
+	store, ok := s.store.(RedactableEventStore)
+	if !ok {
+		return ErrRedactionNotSupported
+	}
+
+	events, err := store.Load(ctx, aggregateType, id)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		if _, ok := e.Event.(compressedEvent); ok {
+			return fmt.Errorf("%w: event %d of %s is stored compressed", ErrRedactionNotSupported, e.Sequence, id)
+		}
+	}
+
+	return store.Redact(ctx, aggregateType, id, fields)
+}
+
+// Redactions returns the redactions made to the underlying store.
+func (s *compressingEventStore) Redactions(ctx context.Context) ([]Redaction, error) {
+	store, ok := s.store.(RedactableEventStore)
+	if !ok {
+		return nil, ErrRedactionNotSupported
+	}
+
+	return store.Redactions(ctx)
+}
+
+// Load returns the decompressed events of the aggregate in sequence order.
+func (s *compressingEventStore) Load(ctx context.Context, aggregateType, id string) ([]PersistedEvent, error) {
+	events, err := s.store.Load(ctx, aggregateType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decompressAll(events)
+}
+
+// LoadFrom returns the decompressed events of the aggregate with a sequence
+// number greater than afterSequence, in sequence order.
+func (s *compressingEventStore) LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) ([]PersistedEvent, error) {
+	events, err := s.store.LoadFrom(ctx, aggregateType, id, afterSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decompressAll(events)
+}
+
+// LoadPage returns a page of the decompressed events of the aggregate.
+func (s *compressingEventStore) LoadPage(ctx context.Context, aggregateType, id string, afterSequence, limit int) ([]PersistedEvent, int, error) {
+	events, next, err := s.store.LoadPage(ctx, aggregateType, id, afterSequence, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events, err = s.decompressAll(events)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return events, next, nil
+}
+
+// LoadMany returns the decompressed events of each of the aggregates, keyed
+// by aggregate ID.
+func (s *compressingEventStore) LoadMany(ctx context.Context, aggregateType string, ids []string) (map[string][]PersistedEvent, error) {
+	histories, err := s.store.LoadMany(ctx, aggregateType, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, events := range histories {
+		if histories[id], err = s.decompressAll(events); err != nil {
+			return nil, err
+		}
+	}
+
+	return histories, nil
+}
+
+// LoadAll returns the decompressed events of all aggregates in the order they
+// were saved.
+func (s *compressingEventStore) LoadAll(ctx context.Context) ([]PersistedEvent, error) {
+	events, err := s.store.LoadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decompressAll(events)
+}
+
+// LoadAllFrom returns up to limit decompressed events with a global position
+// greater than the given position, in the order they were saved.
+func (s *compressingEventStore) LoadAllFrom(ctx context.Context, position int64, limit int) ([]PersistedEvent, error) {
+	events, err := s.store.LoadAllFrom(ctx, position, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decompressAll(events)
+}
+
+// Stream sends the decompressed events of the aggregate in sequence order on
+// the returned channel.
+func (s *compressingEventStore) Stream(ctx context.Context, aggregateType, id string) (<-chan PersistedEvent, <-chan error) {
+	return stream(ctx, s, aggregateType, id)
+}
+
+// NewCompressingEventStore returns an event store gzip-compressing the
+// payloads of events larger than a threshold before saving them to the given
+// store, and decompressing them when loaded. Compressed payloads are tagged,
+// so that stores holding both compressed and uncompressed payloads can be
+// read.
+//
+// To combine compression with encryption, wrap the encrypting store in the
+// compressing store, so that payloads are compressed before they are
+// encrypted. Encrypted payloads don't compress.
+func NewCompressingEventStore(store EventStore, opts ...CompressionOption) EventStore {
+	s := &compressingEventStore{
+		store:     store,
+		threshold: defaultCompressionThreshold,
+		level:     gzip.DefaultCompression,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
@@ -0,0 +1,110 @@
+package order_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestCompressingEventStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	fileStore, err := order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store := order.NewCompressingEventStore(fileStore,
+		order.WithCompressionThreshold(512),
+		order.WithCompressionLevel(gzip.BestCompression),
+	)
+
+	var lines []order.Line
+	for i := 0; i < 100; i++ {
+		lines = append(lines, order.Line{ProductID: fmt.Sprintf("PRODUCT-%03d", i), Quantity: 1, UnitPrice: 100})
+	}
+
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: lines},
+		order.Activate{OrderID: "ABC123"},
+	} {
+		if err := handler.Handle(ctx, cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bytes.Contains(data, []byte("PRODUCT-042")) {
+		t.Errorf("expected large payload to be stored compressed")
+	}
+	if n := bytes.Count(data, []byte(`"$gzip"`)); n != 1 {
+		t.Errorf("expected: %v compressed payload, got: %v", 1, n)
+	}
+
+	want, _, err := order.MarshalEvent(order.Placed{OrderID: "ABC123", Lines: lines})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Reopen the store to decode the events from disk.
+	fileStore, err = order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := order.NewCompressingEventStore(fileStore).Load(ctx, order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(events))
+	}
+
+	got, _, err := order.MarshalEvent(events[0].Event)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected: %s, got: %s", want, got)
+	}
+
+	if _, ok := events[1].Event.(order.Activated); !ok {
+		t.Errorf("expected: %T, got: %T", order.Activated{}, events[1].Event)
+	}
+}
+
+func TestCompressingEncryptedEvents(t *testing.T) {
+	ctx := context.Background()
+
+	store := order.NewCompressingEventStore(
+		order.NewEncryptingEventStore(order.NewEventStore(), order.NewStaticKeyProvider("k1", testKeys)),
+		order.WithCompressionThreshold(0),
+	)
+
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	if err := handler.Handle(ctx, order.Place{OrderID: "ABC123", CustomerID: "C42", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := store.Load(ctx, order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if placed, ok := events[0].Event.(order.Placed); !ok || placed.CustomerID != "C42" {
+		t.Errorf("expected: %v, got: %v", "C42", events[0].Event)
+	}
+}
@@ -0,0 +1,62 @@
+// Package cqrstest provides helpers for testing code built on the order
+// package, in particular read models that are updated asynchronously.
+package cqrstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// ErrTimeout is returned when a condition isn't met before the timeout.
+var ErrTimeout = errors.New("condition not met before timeout")
+
+// DefaultTimeout is the time WaitForOrderStatus waits for the projection to
+// catch up.
+var DefaultTimeout = time.Second
+
+// pollInterval is the time to wait between evaluations of a condition.
+const pollInterval = 5 * time.Millisecond
+
+// WaitFor evaluates cond until it returns true, the timeout expires or the
+// context is cancelled.
+func WaitFor(ctx context.Context, cond func() bool, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if cond() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w after %v", ErrTimeout, timeout)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForOrderStatus waits up to DefaultTimeout for the summary of the order
+// in the projection to have the wanted status.
+func WaitForOrderStatus(proj *order.SummaryProjection, id string, want order.Status) error {
+	var got order.Status
+	err := WaitFor(context.Background(), func() bool {
+		s, ok := proj.Get(id)
+		got = s.Status
+		return ok && s.Status == want
+	}, DefaultTimeout)
+	if err != nil {
+		return fmt.Errorf("order %s: expected status %v, got %v: %w", id, want, got, err)
+	}
+	return nil
+}
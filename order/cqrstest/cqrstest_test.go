@@ -0,0 +1,59 @@
+package cqrstest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+	"github.com/marcusolsson/cqrs-example/order/cqrstest"
+)
+
+func TestWaitForOrderStatus(t *testing.T) {
+	store := order.NewEventStore().(order.SubscribableStore)
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	proj := order.NewSummaryProjection()
+
+	// Apply the events to the projection in the background, as an
+	// asynchronous subscriber would.
+	events := make(chan order.PersistedEvent, 16)
+	go func() {
+		for e := range events {
+			time.Sleep(10 * time.Millisecond)
+			proj.Apply(e)
+		}
+	}()
+
+	sub, err := store.Subscribe(0, func(e order.PersistedEvent) error {
+		events <- e
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer close(events)
+	defer sub.Close()
+
+	lines := []order.Line{{ProductID: "P1", Quantity: 1, UnitPrice: 100}}
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: lines},
+		order.Activate{OrderID: "ABC123"},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if err := cqrstest.WaitForOrderStatus(proj, "ABC123", order.StatusActivated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWaitForTimeout(t *testing.T) {
+	err := cqrstest.WaitFor(context.Background(), func() bool { return false }, 20*time.Millisecond)
+	if !errors.Is(err, cqrstest.ErrTimeout) {
+		t.Errorf("expected: %v, got: %v", cqrstest.ErrTimeout, err)
+	}
+}
@@ -0,0 +1,144 @@
+package cqrstest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// ErrInjectedFault is the error returned by a FaultyEventStore for failing
+// calls, unless configured otherwise.
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultOption configures a faulty event store.
+type FaultOption func(*FaultyEventStore)
+
+// FailNthSave makes the nth call to Save fail, counting from 1. It may be
+// given several times to fail several calls.
+func FailNthSave(n int) FaultOption {
+	return func(s *FaultyEventStore) {
+		s.failSaves[n] = true
+	}
+}
+
+// FailNthLoad makes the nth call to Load or LoadFrom fail, counting from 1. It
+// may be given several times to fail several calls.
+func FailNthLoad(n int) FaultOption {
+	return func(s *FaultyEventStore) {
+		s.failLoads[n] = true
+	}
+}
+
+// FailWithProbability makes each call to Save, Load or LoadFrom fail with the
+// probability p. The faults are drawn from a source with the given seed, so
+// that a test fails the same calls on every run.
+func FailWithProbability(p float64, seed int64) FaultOption {
+	return func(s *FaultyEventStore) {
+		s.probability = p
+		s.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithFaultError sets the error returned by failing calls. The default is
+// ErrInjectedFault.
+func WithFaultError(err error) FaultOption {
+	return func(s *FaultyEventStore) {
+		s.err = err
+	}
+}
+
+// FaultyEventStore wraps an event store and fails some of the calls to Save,
+// Load and LoadFrom without passing them on, to test how failures are
+// handled. Other calls are passed on as they are. It is safe for concurrent
+// use by multiple goroutines.
+type FaultyEventStore struct {
+	order.EventStore
+
+	mu          sync.Mutex
+	err         error
+	failSaves   map[int]bool
+	failLoads   map[int]bool
+	probability float64
+	rand        *rand.Rand
+	saves       int
+	loads       int
+	faults      int
+}
+
+// NewFaultyEventStore returns a new faulty event store wrapping the given
+// store. Without options, no calls fail.
+func NewFaultyEventStore(store order.EventStore, opts ...FaultOption) *FaultyEventStore {
+	s := &FaultyEventStore{
+		EventStore: store,
+		err:        ErrInjectedFault,
+		failSaves:  make(map[int]bool),
+		failLoads:  make(map[int]bool),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// fault counts a call and returns the error to fail it with, if any.
+func (s *FaultyEventStore) fault(save bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fail bool
+	if save {
+		s.saves++
+		fail = s.failSaves[s.saves]
+	} else {
+		s.loads++
+		fail = s.failLoads[s.loads]
+	}
+
+	if !fail && s.rand != nil {
+		fail = s.rand.Float64() < s.probability
+	}
+
+	if !fail {
+		return nil
+	}
+
+	s.faults++
+
+	return s.err
+}
+
+// Save saves the events to the wrapped store, unless the call is to fail.
+func (s *FaultyEventStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []order.PersistedEvent) error {
+	if err := s.fault(true); err != nil {
+		return err
+	}
+	return s.EventStore.Save(ctx, aggregateType, id, expectedVersion, events)
+}
+
+// Load loads the events from the wrapped store, unless the call is to fail.
+func (s *FaultyEventStore) Load(ctx context.Context, aggregateType, id string) ([]order.PersistedEvent, error) {
+	if err := s.fault(false); err != nil {
+		return nil, err
+	}
+	return s.EventStore.Load(ctx, aggregateType, id)
+}
+
+// LoadFrom loads the events from the wrapped store, unless the call is to
+// fail.
+func (s *FaultyEventStore) LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) ([]order.PersistedEvent, error) {
+	if err := s.fault(false); err != nil {
+		return nil, err
+	}
+	return s.EventStore.LoadFrom(ctx, aggregateType, id, afterSequence)
+}
+
+// Faults returns the number of calls that have failed.
+func (s *FaultyEventStore) Faults() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.faults
+}
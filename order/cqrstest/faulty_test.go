@@ -0,0 +1,76 @@
+package cqrstest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+	"github.com/marcusolsson/cqrs-example/order/cqrstest"
+)
+
+func TestFaultyEventStoreWithRetry(t *testing.T) {
+	inner := order.NewEventStore()
+	lines := []order.Line{{ProductID: "P1", Quantity: 1, UnitPrice: 100}}
+
+	if err := order.NewCommandHandler(order.NewRepository(inner)).Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: lines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store := cqrstest.NewFaultyEventStore(inner,
+		cqrstest.FailNthSave(1),
+		cqrstest.WithFaultError(fmt.Errorf("%w: injected", order.ErrConcurrencyConflict)),
+	)
+
+	handler := order.Chain(
+		order.NewCommandHandler(order.NewRepository(store)),
+		order.RetryMiddleware(3, func(int) time.Duration { return 0 }),
+	)
+
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if store.Faults() != 1 {
+		t.Errorf("expected: %v, got: %v", 1, store.Faults())
+	}
+
+	events, err := inner.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 2 {
+		t.Errorf("expected: %v, got: %v", 2, len(events))
+	}
+}
+
+func TestFaultyEventStoreLoad(t *testing.T) {
+	store := cqrstest.NewFaultyEventStore(order.NewEventStore(), cqrstest.FailNthLoad(2))
+
+	for i, want := range []error{order.ErrOrderNotFound, cqrstest.ErrInjectedFault, order.ErrOrderNotFound} {
+		if _, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123"); !errors.Is(err, want) {
+			t.Errorf("load %d: expected: %v, got: %v", i+1, want, err)
+		}
+	}
+}
+
+func TestFaultyEventStoreProbability(t *testing.T) {
+	var faults []int
+	for run := 0; run < 2; run++ {
+		store := cqrstest.NewFaultyEventStore(order.NewEventStore(), cqrstest.FailWithProbability(0.5, 42))
+
+		for i := 0; i < 20; i++ {
+			store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+		}
+		faults = append(faults, store.Faults())
+	}
+
+	if faults[0] == 0 || faults[0] == 20 {
+		t.Errorf("expected some loads to fail, got: %v", faults[0])
+	}
+	if faults[0] != faults[1] {
+		t.Errorf("expected the same faults for the same seed, got: %v", faults)
+	}
+}
@@ -0,0 +1,151 @@
+package cqrstest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// Operation is a call recorded by a RecordingEventStore.
+type Operation struct {
+	// Method is Save, Load or LoadFrom.
+	Method string
+
+	AggregateType string
+	AggregateID   string
+
+	// ExpectedVersion is set for Save, and AfterSequence for LoadFrom.
+	ExpectedVersion int
+	AfterSequence   int
+
+	// Events are the events saved or loaded.
+	Events []order.PersistedEvent
+	Err    error
+}
+
+// RecordingEventStore wraps an event store and records the calls to Save,
+// Load and LoadFrom along with their results. Other calls are passed on
+// without being recorded. It is safe for concurrent use by multiple
+// goroutines.
+type RecordingEventStore struct {
+	order.EventStore
+
+	mu  sync.Mutex
+	ops []Operation
+}
+
+// NewRecordingEventStore returns a new recording event store wrapping the
+// given store.
+func NewRecordingEventStore(store order.EventStore) *RecordingEventStore {
+	return &RecordingEventStore{EventStore: store}
+}
+
+func (s *RecordingEventStore) record(op Operation) {
+	events := make([]order.PersistedEvent, len(op.Events))
+	copy(events, op.Events)
+	op.Events = events
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ops = append(s.ops, op)
+}
+
+// Save saves the events to the wrapped store and records the call.
+func (s *RecordingEventStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []order.PersistedEvent) error {
+	err := s.EventStore.Save(ctx, aggregateType, id, expectedVersion, events)
+
+	s.record(Operation{
+		Method:          "Save",
+		AggregateType:   aggregateType,
+		AggregateID:     id,
+		ExpectedVersion: expectedVersion,
+		Events:          events,
+		Err:             err,
+	})
+
+	return err
+}
+
+// Load loads the events from the wrapped store and records the call.
+func (s *RecordingEventStore) Load(ctx context.Context, aggregateType, id string) ([]order.PersistedEvent, error) {
+	events, err := s.EventStore.Load(ctx, aggregateType, id)
+
+	s.record(Operation{
+		Method:        "Load",
+		AggregateType: aggregateType,
+		AggregateID:   id,
+		Events:        events,
+		Err:           err,
+	})
+
+	return events, err
+}
+
+// LoadFrom loads the events from the wrapped store and records the call.
+func (s *RecordingEventStore) LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) ([]order.PersistedEvent, error) {
+	events, err := s.EventStore.LoadFrom(ctx, aggregateType, id, afterSequence)
+
+	s.record(Operation{
+		Method:        "LoadFrom",
+		AggregateType: aggregateType,
+		AggregateID:   id,
+		AfterSequence: afterSequence,
+		Events:        events,
+		Err:           err,
+	})
+
+	return events, err
+}
+
+// Operations returns the recorded calls in the order they were made.
+func (s *RecordingEventStore) Operations() []Operation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ops := make([]Operation, len(s.ops))
+	copy(ops, s.ops)
+
+	return ops
+}
+
+// Saves returns the recorded calls to Save in the order they were made.
+func (s *RecordingEventStore) Saves() []Operation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var saves []Operation
+	for _, op := range s.ops {
+		if op.Method == "Save" {
+			saves = append(saves, op)
+		}
+	}
+
+	return saves
+}
+
+// SaveCount returns the number of recorded calls to Save.
+func (s *RecordingEventStore) SaveCount() int {
+	return len(s.Saves())
+}
+
+// LastSaved returns the events passed to the latest successful call to Save,
+// if any.
+func (s *RecordingEventStore) LastSaved() []order.PersistedEvent {
+	saves := s.Saves()
+	for i := len(saves) - 1; i >= 0; i-- {
+		if saves[i].Err == nil {
+			return saves[i].Events
+		}
+	}
+	return nil
+}
+
+// Reset forgets the recorded calls.
+func (s *RecordingEventStore) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ops = nil
+}
@@ -0,0 +1,47 @@
+package cqrstest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+	"github.com/marcusolsson/cqrs-example/order/cqrstest"
+)
+
+func TestRecordingEventStore(t *testing.T) {
+	store := cqrstest.NewRecordingEventStore(order.NewEventStore())
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	lines := []order.Line{{ProductID: "P1", Quantity: 1, UnitPrice: 100}}
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: lines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if store.SaveCount() != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, store.SaveCount())
+	}
+
+	save := store.Saves()[0]
+	if save.AggregateID != "ABC123" || save.ExpectedVersion != 0 {
+		t.Errorf("expected save of ABC123 at version 0, got: %s at version %d", save.AggregateID, save.ExpectedVersion)
+	}
+
+	saved := store.LastSaved()
+	if len(saved) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(saved))
+	}
+	if _, ok := saved[0].Event.(order.Placed); !ok {
+		t.Errorf("expected: %T, got: %T", order.Placed{}, saved[0].Event)
+	}
+
+	store.Reset()
+
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ops := store.Operations()
+	if len(ops) != 2 || ops[0].Method != "Load" || ops[1].Method != "Save" {
+		t.Errorf("expected a load followed by a save, got: %v", ops)
+	}
+}
@@ -0,0 +1,98 @@
+package order
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrDeadLetterNotFound is returned when replaying a dead letter that isn't
+// parked in the store.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is an event that a subscription handler repeatedly failed to
+// handle, along with the last error returned by the handler.
+type DeadLetter struct {
+	ID       string
+	Event    PersistedEvent
+	Err      error
+	ParkedAt time.Time
+}
+
+// DeadLetterStore parks the events a subscription gave up on, so that they can
+// be inspected and replayed once the cause of the failure has been fixed.
+type DeadLetterStore interface {
+	// Park stores the event along with the error that caused it to be
+	// given up on.
+	Park(e PersistedEvent, err error)
+
+	// List returns the parked events in the order they were parked.
+	List() []DeadLetter
+
+	// Replay hands the parked event to the handler again, and removes it
+	// from the store if the handler succeeds.
+	Replay(id string) error
+}
+
+type deadLetterStore struct {
+	mu      sync.Mutex
+	handler func(PersistedEvent) error
+	letters []DeadLetter
+	nextID  int
+}
+
+// Park stores the event along with the error that caused it to be given up on.
+func (s *deadLetterStore) Park(e PersistedEvent, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	s.letters = append(s.letters, DeadLetter{
+		ID:       strconv.Itoa(s.nextID),
+		Event:    e,
+		Err:      err,
+		ParkedAt: time.Now(),
+	})
+}
+
+// List returns the parked events in the order they were parked.
+func (s *deadLetterStore) List() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]DeadLetter, len(s.letters))
+	copy(result, s.letters)
+	return result
+}
+
+// Replay hands the parked event to the handler again. The event is removed
+// from the store if the handler succeeds, and otherwise kept with the new
+// error, which is returned.
+func (s *deadLetterStore) Replay(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, l := range s.letters {
+		if l.ID != id {
+			continue
+		}
+
+		if err := s.handler(l.Event); err != nil {
+			s.letters[i].Err = err
+			return err
+		}
+
+		s.letters = append(s.letters[:i], s.letters[i+1:]...)
+		return nil
+	}
+
+	return ErrDeadLetterNotFound
+}
+
+// NewDeadLetterStore returns an in-memory dead-letter store that replays
+// parked events to the handler. It is safe for concurrent use by multiple
+// goroutines.
+func NewDeadLetterStore(handler func(PersistedEvent) error) DeadLetterStore {
+	return &deadLetterStore{handler: handler}
+}
@@ -0,0 +1,118 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrDuplicateCommand is returned when a command with the same command ID has
+// already been handled.
+var ErrDuplicateCommand = errors.New("command has already been handled")
+
+// CommandEnvelope wraps a command with the metadata needed to handle it. The
+// tenant, the user and the metadata of the envelope are recorded in the
+// metadata of the events caused by the command.
+type CommandEnvelope struct {
+	CommandID     string
+	CorrelationID string
+	TenantID      string
+	UserID        string
+	Metadata      map[string]string
+	Command       interface{}
+}
+
+// DedupStore keeps track of the commands that have been handled.
+type DedupStore interface {
+	Seen(id string) bool
+	Mark(id string)
+
+	// TryMark marks the command ID unless it has already been marked, and
+	// reports whether it did, as a single atomic step. Of several concurrent
+	// calls for the same ID, only one succeeds.
+	TryMark(id string) bool
+
+	// Release removes the mark of the command ID, e.g. once handling the
+	// command marked with TryMark has failed, so that it can be retried.
+	Release(id string)
+}
+
+type dedupStore struct {
+	mu   sync.RWMutex
+	seen map[string]struct{}
+}
+
+func (s *dedupStore) Seen(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.seen[id]
+	return ok
+}
+
+func (s *dedupStore) Mark(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seen[id] = struct{}{}
+}
+
+func (s *dedupStore) TryMark(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seen[id]; ok {
+		return false
+	}
+	s.seen[id] = struct{}{}
+
+	return true
+}
+
+func (s *dedupStore) Release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.seen, id)
+}
+
+// NewDedupStore returns a new instance of the default in-memory dedup store.
+// The store is safe for concurrent use by multiple goroutines.
+func NewDedupStore() DedupStore {
+	return &dedupStore{
+		seen: make(map[string]struct{}),
+	}
+}
+
+// DedupMiddleware skips commands in an envelope whose command ID has already
+// been handled successfully, or is being handled concurrently. Skipped
+// commands return ErrDuplicateCommand, unless silent is set in which case they
+// succeed without being handled. The command ID is marked before the command
+// is handled and released if handling fails, so that the command can be
+// retried. Commands without a command ID are always handled.
+func DedupMiddleware(store DedupStore, silent bool) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+			env, ok := c.(CommandEnvelope)
+			if !ok || env.CommandID == "" {
+				return next.Handle(ctx, c)
+			}
+
+			ctx = envelopeContext(ctx, env)
+
+			if !store.TryMark(env.CommandID) {
+				if silent {
+					return nil
+				}
+				return ErrDuplicateCommand
+			}
+
+			if err := next.Handle(ctx, env); err != nil {
+				store.Release(env.CommandID)
+				return err
+			}
+
+			return nil
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestDedupMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		silent  bool
+		wantErr error
+	}{
+		{name: "error", silent: false, wantErr: order.ErrDuplicateCommand},
+		{name: "silent", silent: true, wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := order.NewEventStore()
+			handler := order.Chain(
+				order.NewCommandHandler(order.NewRepository(store)),
+				order.DedupMiddleware(order.NewDedupStore(), tt.silent),
+			)
+
+			if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			cmd := order.CommandEnvelope{
+				CommandID: "CMD1",
+				Command:   order.AddLine{OrderID: "ABC123", Line: order.Line{ProductID: "P2", Quantity: 1}},
+			}
+
+			if err := handler.Handle(context.Background(), cmd); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if err := handler.Handle(context.Background(), cmd); !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected: %v, got: %v", tt.wantErr, err)
+			}
+
+			events, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(events) != 2 {
+				t.Errorf("expected: %v, got: %v", 2, len(events))
+			}
+		})
+	}
+}
+
+func TestDedupMiddlewareRetriesFailedCommand(t *testing.T) {
+	dedup := order.NewDedupStore()
+	handler := order.Chain(
+		order.NewCommandHandler(order.NewRepository(order.NewEventStore())),
+		order.DedupMiddleware(dedup, false),
+	)
+
+	cmd := order.CommandEnvelope{
+		CommandID: "CMD1",
+		Command:   order.Activate{OrderID: "ABC123"},
+	}
+
+	if err := handler.Handle(context.Background(), cmd); !errors.Is(err, order.ErrOrderNotFound) {
+		t.Fatalf("expected: %v, got: %v", order.ErrOrderNotFound, err)
+	}
+
+	if dedup.Seen("CMD1") {
+		t.Errorf("expected failed command to not be marked as seen")
+	}
+}
+
+func TestDedupMiddlewareConcurrentDelivery(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+		calls   int
+	)
+	handler := order.Chain(
+		order.CommandHandlerFunc(func(ctx context.Context, cmd interface{}) error {
+			calls++
+			close(started)
+			<-release
+			return nil
+		}),
+		order.DedupMiddleware(order.NewDedupStore(), false),
+	)
+
+	cmd := order.CommandEnvelope{
+		CommandID: "CMD1",
+		Command:   order.Activate{OrderID: "ABC123"},
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- handler.Handle(context.Background(), cmd) }()
+
+	<-started
+
+	// The second delivery arrives while the first is still being handled.
+	if err := handler.Handle(context.Background(), cmd); !errors.Is(err, order.ErrDuplicateCommand) {
+		t.Errorf("expected: %v, got: %v", order.ErrDuplicateCommand, err)
+	}
+
+	close(release)
+
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected: %v, got: %v", 1, calls)
+	}
+}
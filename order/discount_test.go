@@ -0,0 +1,81 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestApplyDiscount(t *testing.T) {
+	store := order.NewEventStore()
+	repo := order.NewRepository(store)
+	handler := order.NewCommandHandler(repo)
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: []order.Line{{ProductID: "P1", Quantity: 2, UnitPrice: 500}}},
+		order.ApplyDiscount{OrderID: "ABC123", Code: "SPRING", AmountCents: 300},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error for %T: %v", cmd, err)
+		}
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, _ := o.Total(); got != 700 {
+		t.Errorf("expected: %v, got: %v", 700, got)
+	}
+
+	err = handler.Handle(context.Background(), order.ApplyDiscount{OrderID: "ABC123", Code: "SPRING", AmountCents: 300})
+	if !errors.Is(err, order.ErrDiscountAlreadyApplied) {
+		t.Errorf("expected: %v, got: %v", order.ErrDiscountAlreadyApplied, err)
+	}
+
+	events, err := store.LoadAll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p := order.NewSummaryProjection()
+	p.Rebuild(events)
+
+	if s, _ := p.Get("ABC123"); s.TotalCents != 700 {
+		t.Errorf("expected: %v, got: %v", 700, s.TotalCents)
+	}
+}
+
+func TestApplyDiscountClampsTotal(t *testing.T) {
+	o := order.NewOrder("ABC123")
+	if err := o.Place([]order.Line{{ProductID: "P1", Quantity: 1, UnitPrice: 500}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, code := range []string{"SPRING", "LOYALTY"} {
+		if err := o.ApplyDiscount(code, 300); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got, _ := o.Total(); got != 0 {
+		t.Errorf("expected: %v, got: %v", 0, got)
+	}
+}
+
+func TestApplyDiscountAfterActivation(t *testing.T) {
+	o := order.NewOrder("ABC123")
+	if err := o.Place(testLines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := o.Activate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := o.ApplyDiscount("SPRING", 10); !errors.Is(err, order.ErrOrderNotAmendable) {
+		t.Errorf("expected: %v, got: %v", order.ErrOrderNotAmendable, err)
+	}
+}
@@ -0,0 +1,384 @@
+package order
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrKeyNotFound is returned by a key provider for an unknown key ID.
+var ErrKeyNotFound = errors.New("encryption key was not found")
+
+// ErrDecryptionFailed is returned when a stored payload can't be decrypted,
+// e.g. because it was encrypted with a different key or has been tampered
+// with.
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// KeyProvider provides the keys used to encrypt event payloads. Keys are
+// identified by an ID that is stored with each payload, so that keys can be
+// rotated while payloads encrypted with older keys can still be decrypted.
+type KeyProvider interface {
+	// CurrentKey returns the ID and value of the key to encrypt new payloads
+	// with.
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key with the given ID.
+	Key(id string) ([]byte, error)
+}
+
+type staticKeyProvider struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// CurrentKey returns the current key.
+func (p *staticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := p.Key(p.currentID)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.currentID, key, nil
+}
+
+// Key returns the key with the given ID.
+func (p *staticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	return key, nil
+}
+
+// NewStaticKeyProvider returns a key provider for a fixed set of AES keys,
+// keyed by ID, encrypting new payloads with the key given by currentID. Keys
+// must be 16, 24 or 32 bytes long.
+func NewStaticKeyProvider(currentID string, keys map[string][]byte) KeyProvider {
+	p := &staticKeyProvider{
+		currentID: currentID,
+		keys:      make(map[string][]byte, len(keys)),
+	}
+	for id, key := range keys {
+		p.keys[id] = key
+	}
+	return p
+}
+
+// sealedEvent holds the encrypted payload of an event in place of the event
+// itself. It is encoded as a JSON object with a single "$sealed" field.
+type sealedEvent struct {
+	name        string
+	aggregateID string
+
+	Sealed sealedPayload `json:"$sealed"`
+}
+
+type sealedPayload struct {
+	KeyID      string `json:"key_id"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// ID returns the identifier of the aggregate the event belongs to.
+func (e sealedEvent) ID() string {
+	return e.aggregateID
+}
+
+func (e sealedEvent) typeName() string {
+	return e.name
+}
+
+// parseSealed returns the sealed event encoded by the payload of an event
+// stored under the given type name, if any.
+func parseSealed(name string, data []byte) (sealedEvent, bool) {
+	if !bytes.Contains(data, []byte(`"$sealed"`)) {
+		return sealedEvent{}, false
+	}
+
+	var e sealedEvent
+	if err := json.Unmarshal(data, &e); err != nil || e.Sealed.Ciphertext == nil {
+		return sealedEvent{}, false
+	}
+	e.name = name
+
+	return e, true
+}
+
+// sealedData returns the additional data authenticated along with the
+// payload, binding it to the event type and the aggregate.
+func sealedData(name, id string) []byte {
+	return []byte(name + "\x00" + id)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type encryptingEventStore struct {
+	store EventStore
+	keys  KeyProvider
+}
+
+// seal replaces the event with its encrypted payload.
+func (s *encryptingEventStore) seal(e PersistedEvent, id string) (PersistedEvent, error) {
+	payload, name, err := MarshalEvent(e.Event)
+	if err != nil {
+		return e, err
+	}
+
+	keyID, key, err := s.keys.CurrentKey()
+	if err != nil {
+		return e, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return e, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return e, err
+	}
+
+	e.SchemaVersion = schemaVersion(e)
+	e.Event = sealedEvent{
+		name:        name,
+		aggregateID: id,
+		Sealed: sealedPayload{
+			KeyID:      keyID,
+			Nonce:      nonce,
+			Ciphertext: gcm.Seal(nil, nonce, payload, sealedData(name, id)),
+		},
+	}
+
+	return e, nil
+}
+
+// open replaces the encrypted payload of the event with the decrypted event.
+// Events stored without encryption are returned as they are.
+func (s *encryptingEventStore) open(e PersistedEvent) (PersistedEvent, error) {
+	sealed, ok := e.Event.(sealedEvent)
+	if !ok {
+		return e, nil
+	}
+
+	key, err := s.keys.Key(sealed.Sealed.KeyID)
+	if err != nil {
+		return e, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return e, err
+	}
+
+	if len(sealed.Sealed.Nonce) != gcm.NonceSize() {
+		return e, fmt.Errorf("%w: invalid nonce", ErrDecryptionFailed)
+	}
+
+	payload, err := gcm.Open(nil, sealed.Sealed.Nonce, sealed.Sealed.Ciphertext, sealedData(sealed.name, e.AggregateID))
+	if err != nil {
+		return e, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+
+	e.Event, e.SchemaVersion, err = decodeEvent(sealed.name, e.SchemaVersion, payload)
+	if err != nil {
+		return e, err
+	}
+
+	return e, nil
+}
+
+// openAll decrypts the events in place.
+func (s *encryptingEventStore) openAll(events []PersistedEvent) ([]PersistedEvent, error) {
+	for i, e := range events {
+		opened, err := s.open(e)
+		if err != nil {
+			return nil, err
+		}
+		events[i] = opened
+	}
+	return events, nil
+}
+
+// Save encrypts the payloads of the events before saving them.
+func (s *encryptingEventStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []PersistedEvent) error {
+	sealed := make([]PersistedEvent, len(events))
+	for i, e := range events {
+		var err error
+		if sealed[i], err = s.seal(e, id); err != nil {
+			return err
+		}
+	}
+
+	return s.store.Save(ctx, aggregateType, id, expectedVersion, sealed)
+}
+
+// SaveAll encrypts the payloads of the events of every aggregate before saving
+// them in a single transaction, if the underlying store supports it.
+func (s *encryptingEventStore) SaveAll(ctx context.Context, changes []AggregateChanges) error {
+	store, ok := s.store.(TransactionalStore)
+	if !ok {
+		return ErrTransactionsNotSupported
+	}
+
+	sealed := make([]AggregateChanges, len(changes))
+	for i, c := range changes {
+		events := make([]PersistedEvent, len(c.Events))
+		for j, e := range c.Events {
+			var err error
+			if events[j], err = s.seal(e, c.AggregateID); err != nil {
+				return err
+			}
+		}
+
+		c.Events = events
+		sealed[i] = c
+	}
+
+	return store.SaveAll(ctx, sealed)
+}
+
+// Subscribe subscribes the handler to the decrypted events of the underlying
+// store, if it supports subscriptions.
+func (s *encryptingEventStore) Subscribe(fromPosition int64, handler func(PersistedEvent) error, opts ...SubscriptionOption) (*Subscription, error) {
+	return subscribeDecoded(s.store, fromPosition, s.open, handler, opts)
+}
+
+// Redact redacts the fields in the events of the aggregate in the underlying
+// store, if it supports redaction. The underlying store can't see the fields
+// of encrypted payloads, so rather than leaving them in place, nothing is
+// redacted and ErrRedactionNotSupported is returned if any event of the
+// aggregate is stored encrypted.
+func (s *encryptingEventStore) Redact(ctx context.Context, aggregateType, id string, fields []string) error {
+	store, ok := s.store.(RedactableEventStore)
+	if !ok {
+		return ErrRedactionNotSupported
+	}
+
+	events, err := store.Load(ctx, aggregateType, id)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		if _, ok := e.Event.(sealedEvent); ok {
+			return fmt.Errorf("%w: event %d of %s is stored encrypted", ErrRedactionNotSupported, e.Sequence, id)
+		}
+	}
+
+	return store.Redact(ctx, aggregateType, id, fields)
+}
+
+// Redactions returns the redactions made to the underlying store.
+func (s *encryptingEventStore) Redactions(ctx context.Context) ([]Redaction, error) {
+	store, ok := s.store.(RedactableEventStore)
+	if !ok {
+		return nil, ErrRedactionNotSupported
+	}
+
+	return store.Redactions(ctx)
+}
+
+// Load returns the decrypted events of the aggregate in sequence order.
+func (s *encryptingEventStore) Load(ctx context.Context, aggregateType, id string) ([]PersistedEvent, error) {
+	events, err := s.store.Load(ctx, aggregateType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.openAll(events)
+}
+
+// LoadFrom returns the decrypted events of the aggregate with a sequence
+// number greater than afterSequence, in sequence order.
+func (s *encryptingEventStore) LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) ([]PersistedEvent, error) {
+	events, err := s.store.LoadFrom(ctx, aggregateType, id, afterSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.openAll(events)
+}
+
+// LoadPage returns a page of the decrypted events of the aggregate.
+func (s *encryptingEventStore) LoadPage(ctx context.Context, aggregateType, id string, afterSequence, limit int) ([]PersistedEvent, int, error) {
+	events, next, err := s.store.LoadPage(ctx, aggregateType, id, afterSequence, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events, err = s.openAll(events)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return events, next, nil
+}
+
+// LoadMany returns the decrypted events of each of the aggregates, keyed by
+// aggregate ID.
+func (s *encryptingEventStore) LoadMany(ctx context.Context, aggregateType string, ids []string) (map[string][]PersistedEvent, error) {
+	histories, err := s.store.LoadMany(ctx, aggregateType, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, events := range histories {
+		if histories[id], err = s.openAll(events); err != nil {
+			return nil, err
+		}
+	}
+
+	return histories, nil
+}
+
+// LoadAll returns the decrypted events of all aggregates in the order they
+// were saved.
+func (s *encryptingEventStore) LoadAll(ctx context.Context) ([]PersistedEvent, error) {
+	events, err := s.store.LoadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.openAll(events)
+}
+
+// LoadAllFrom returns up to limit decrypted events with a global position
+// greater than the given position, in the order they were saved.
+func (s *encryptingEventStore) LoadAllFrom(ctx context.Context, position int64, limit int) ([]PersistedEvent, error) {
+	events, err := s.store.LoadAllFrom(ctx, position, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.openAll(events)
+}
+
+// Stream sends the decrypted events of the aggregate in sequence order on the
+// returned channel.
+func (s *encryptingEventStore) Stream(ctx context.Context, aggregateType, id string) (<-chan PersistedEvent, <-chan error) {
+	return stream(ctx, s, aggregateType, id)
+}
+
+// NewEncryptingEventStore returns an event store encrypting the payloads of
+// events with AES-GCM before saving them to the given store, and decrypting
+// them when loaded. The event type, sequence and other metadata are stored in
+// cleartext, so the underlying store can still index them.
+//
+// Each payload records the ID of the key it was encrypted with, so after
+// rotating the current key of the provider, the older keys must remain
+// available for as long as events encrypted with them are stored. Events
+// saved before encryption was enabled are loaded as they are.
+func NewEncryptingEventStore(store EventStore, keys KeyProvider) EventStore {
+	return &encryptingEventStore{store: store, keys: keys}
+}
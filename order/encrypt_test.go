@@ -0,0 +1,154 @@
+package order_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+var testKeys = map[string][]byte{
+	"k1": bytes.Repeat([]byte{1}, 32),
+	"k2": bytes.Repeat([]byte{2}, 32),
+}
+
+func TestEncryptingEventStore(t *testing.T) {
+	ctx := context.Background()
+	inner := order.NewEventStore()
+
+	store := order.NewEncryptingEventStore(inner, order.NewStaticKeyProvider("k1", testKeys))
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	if err := handler.Handle(ctx, order.Place{OrderID: "ABC123", CustomerID: "C42", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Rotate the key. Events encrypted with the old key must still load.
+	store = order.NewEncryptingEventStore(inner, order.NewStaticKeyProvider("k2", testKeys))
+	repo := order.NewRepository(store)
+
+	if err := order.NewCommandHandler(repo).Handle(ctx, order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(ctx, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.CustomerID != "C42" {
+		t.Errorf("expected: %v, got: %v", "C42", o.CustomerID)
+	}
+	if o.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+	}
+
+	events, err := inner.Load(ctx, order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, e := range events {
+		if _, ok := e.Event.(order.Placed); ok {
+			t.Errorf("expected event to be stored encrypted, got: %v", e.Event)
+		}
+	}
+
+	wrongKey := order.NewEncryptingEventStore(inner, order.NewStaticKeyProvider("k1", map[string][]byte{
+		"k1": testKeys["k2"],
+		"k2": testKeys["k1"],
+	}))
+	if _, err := wrongKey.Load(ctx, order.AggregateTypeOrder, "ABC123"); !errors.Is(err, order.ErrDecryptionFailed) {
+		t.Errorf("expected: %v, got: %v", order.ErrDecryptionFailed, err)
+	}
+}
+
+func TestEncryptingEventStoreOnDisk(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	fileStore, err := order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store := order.NewEncryptingEventStore(fileStore, order.NewStaticKeyProvider("k1", testKeys))
+	if err := order.NewCommandHandler(order.NewRepository(store)).Handle(ctx, order.Place{OrderID: "ABC123", CustomerID: "C42", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bytes.Contains(data, []byte("C42")) {
+		t.Errorf("expected payload to be encrypted, got: %s", data)
+	}
+	if !bytes.Contains(data, []byte(`"k1"`)) {
+		t.Errorf("expected payload to be tagged with the key ID, got: %s", data)
+	}
+
+	name, err := order.DefaultRegistry.Name(order.Placed{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Contains(data, []byte(name)) {
+		t.Errorf("expected event type %s in cleartext, got: %s", name, data)
+	}
+
+	// Reopen the store to decode the events from disk.
+	fileStore, err = order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := order.NewEncryptingEventStore(fileStore, order.NewStaticKeyProvider("k1", testKeys)).Load(ctx, order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	placed, ok := events[0].Event.(order.Placed)
+	if !ok || placed.CustomerID != "C42" {
+		t.Errorf("expected: %v, got: %v", "C42", events[0].Event)
+	}
+}
+
+func TestEncryptingEventStoreSubscribeAndRedact(t *testing.T) {
+	ctx := context.Background()
+	store := order.NewEncryptingEventStore(order.NewEventStore(), order.NewStaticKeyProvider("k1", testKeys))
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	if err := handler.Handle(ctx, order.Place{OrderID: "ABC123", CustomerID: "C42", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var placed []order.Placed
+	sub, err := store.(order.SubscribableStore).Subscribe(0, func(e order.PersistedEvent) error {
+		placed = append(placed, e.Event.(order.Placed))
+		return nil
+	}, order.WithFilter(order.ByType(order.Placed{})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sub.Close()
+
+	if err := handler.Handle(ctx, order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(placed) != 1 || placed[0].CustomerID != "C42" {
+		t.Errorf("expected the decrypted placed event, got: %v", placed)
+	}
+	if sub.Position() != 2 {
+		t.Errorf("expected: %v, got: %v", 2, sub.Position())
+	}
+
+	redactable := store.(order.RedactableEventStore)
+	if err := redactable.Redact(ctx, order.AggregateTypeOrder, "ABC123", []string{"CustomerID"}); !errors.Is(err, order.ErrRedactionNotSupported) {
+		t.Errorf("expected: %v, got: %v", order.ErrRedactionNotSupported, err)
+	}
+}
@@ -0,0 +1,84 @@
+package order
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrDuplicateEventID is returned when a save is rejected because one of its
+// events has the same ID as another event of the save, or as an event saved
+// concurrently.
+var ErrDuplicateEventID = errors.New("duplicate event ID")
+
+// newEventID returns a new event ID in the form of a version 7 UUID. The
+// UUID starts with the time the event occurred in milliseconds, so that
+// event IDs sort by time, followed by random bits.
+func newEventID(t time.Time) string {
+	var b [16]byte
+
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(t.UnixMilli()))
+	copy(b[0:6], ms[2:8])
+
+	rand.Read(b[6:])
+
+	b[6] = b[6]&0x0f | 0x70 // version 7
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return formatUUID(b)
+}
+
+type skippedKey struct{}
+
+// skippedEvents collects the IDs of the events that event stores left out of
+// a save because they had already been saved.
+type skippedEvents struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+// withSkippedEvents returns a context in which event stores record the events
+// they skip, so that the caller of a save can tell which events it appended.
+func withSkippedEvents(ctx context.Context) (context.Context, *skippedEvents) {
+	s := &skippedEvents{ids: make(map[string]bool)}
+	return context.WithValue(ctx, skippedKey{}, s), s
+}
+
+// recordSkipped records the events as skipped by the save, if its caller
+// asked for them. Event stores call it for the events they leave out because
+// their IDs have already been saved.
+func recordSkipped(ctx context.Context, events []PersistedEvent) {
+	s, ok := ctx.Value(skippedKey{}).(*skippedEvents)
+	if !ok || len(events) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range events {
+		s.ids[e.EventID] = true
+	}
+}
+
+// appended returns the events that weren't skipped by the save.
+func (s *skippedEvents) appended(events []PersistedEvent) []PersistedEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.ids) == 0 {
+		return events
+	}
+
+	result := make([]PersistedEvent, 0, len(events))
+	for _, e := range events {
+		if e.EventID == "" || !s.ids[e.EventID] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
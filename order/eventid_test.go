@@ -0,0 +1,88 @@
+package order_test
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+var uuidV7 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestEventIDs(t *testing.T) {
+	store := order.NewEventStore()
+	clock := &tickingClock{now: time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)}
+
+	handler := order.NewCommandHandler(order.NewRepository(store), order.WithClock(clock))
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.Activate{OrderID: "ABC123"},
+		order.Ship{OrderID: "ABC123"},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	events, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, e := range events {
+		if !uuidV7.MatchString(e.EventID) {
+			t.Fatalf("expected a version 7 UUID, got: %q", e.EventID)
+		}
+
+		// Event IDs sort by the time the events occurred.
+		if i > 0 && e.EventID <= events[i-1].EventID {
+			t.Errorf("expected %q to sort after %q", e.EventID, events[i-1].EventID)
+		}
+	}
+}
+
+func TestEventStoreSkipsSavedEvents(t *testing.T) {
+	sqliteStore, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	stores := map[string]order.EventStore{
+		"memory": order.NewEventStore(),
+		"sqlite": sqliteStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			events := []order.PersistedEvent{{
+				Event:   order.Placed{OrderID: "ABC123", Lines: testLines},
+				EventID: "0157b0c4-a400-7000-8000-000000000001",
+			}}
+
+			if err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, events); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// Retrying the save succeeds without storing the event again.
+			if err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, events); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			loaded, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(loaded) != 1 {
+				t.Fatalf("expected: %v, got: %v", 1, len(loaded))
+			}
+			if loaded[0].EventID != events[0].EventID {
+				t.Errorf("expected: %v, got: %v", events[0].EventID, loaded[0].EventID)
+			}
+		})
+	}
+}
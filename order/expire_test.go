@@ -0,0 +1,57 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestExpire(t *testing.T) {
+	placedAt := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := order.NewRepository(order.NewEventStore())
+	handler := order.NewCommandHandler(repo, order.WithClock(stubClock{now: placedAt}))
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.Expire{OrderID: "ABC123"},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error for %T: %v", cmd, err)
+		}
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusExpired {
+		t.Errorf("expected: %v, got: %v", order.StatusExpired, o.Status)
+	}
+	if !o.PlacedAt().Equal(placedAt) {
+		t.Errorf("expected: %v, got: %v", placedAt, o.PlacedAt())
+	}
+
+	err = handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"})
+	if !errors.Is(err, order.ErrInvalidTransition) {
+		t.Errorf("expected: %v, got: %v", order.ErrInvalidTransition, err)
+	}
+}
+
+func TestExpireActivatedOrder(t *testing.T) {
+	o := order.NewOrder("ABC123")
+	if err := o.Place(testLines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := o.Activate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := o.Expire(); !errors.Is(err, order.ErrInvalidTransition) {
+		t.Errorf("expected: %v, got: %v", order.ErrInvalidTransition, err)
+	}
+}
@@ -0,0 +1,493 @@
+package order
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fileRecord is the JSON encoding of a persisted event in the log file.
+type fileRecord struct {
+	AggregateType  string          `json:"aggregate_type,omitempty"`
+	AggregateID    string          `json:"aggregate_id"`
+	EventID        string          `json:"event_id,omitempty"`
+	Sequence       int             `json:"sequence"`
+	GlobalPosition int64           `json:"global_sequence"`
+	Type           string          `json:"type"`
+	Payload        json.RawMessage `json:"payload"`
+	OccurredAt     time.Time       `json:"occurred_at"`
+	Metadata       Metadata        `json:"metadata"`
+	SchemaVersion  int             `json:"schema_version"`
+}
+
+// aggregateType returns the aggregate type of the record. Records written
+// before aggregate types were stored belong to orders.
+func (r fileRecord) aggregateType() string {
+	if r.AggregateType == "" {
+		return AggregateTypeOrder
+	}
+	return r.AggregateType
+}
+
+type fileEventStore struct {
+	mu       sync.RWMutex
+	path     string
+	versions map[aggregateKey]int
+	position int64
+	eventIDs map[string]bool
+	logger   *slog.Logger
+}
+
+// FileStoreOption configures the file event store.
+type FileStoreOption func(*fileEventStore)
+
+// WithFileStoreLogger sets the logger used to warn about corrupt lines in the
+// file. By default the warnings go to the default slog logger.
+func WithFileStoreLogger(l *slog.Logger) FileStoreOption {
+	return func(s *fileEventStore) {
+		s.logger = l
+	}
+}
+
+// NewFileEventStore returns an event store appending one JSON encoded event
+// per line to the file at path, creating it if needed. Existing events are
+// read back when the store is opened. A partially written last line, e.g.
+// after a crash, is removed with a warning, while other corrupt lines are
+// skipped with a warning.
+func NewFileEventStore(path string, opts ...FileStoreOption) (EventStore, error) {
+	s := &fileEventStore{
+		path:     path,
+		versions: make(map[aggregateKey]int),
+		eventIDs: make(map[string]bool),
+		logger:   slog.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	valid, err := s.recover(f)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if valid < fi.Size() {
+		s.logger.Warn("truncating partially written last line",
+			slog.String("path", path),
+			slog.Int64("bytes", fi.Size()-valid),
+		)
+
+		if err := f.Truncate(valid); err != nil {
+			return nil, err
+		}
+		if err := f.Sync(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// recover reads the versions of all stored orders, along with the IDs of the
+// stored events, and returns the offset following the last complete line.
+// Corrupt lines that are followed by other lines are skipped.
+func (s *fileEventStore) recover(r io.Reader) (int64, error) {
+	var valid int64
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return valid, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		offset := valid
+		valid += int64(len(line))
+
+		var rec fileRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			s.logger.Warn("skipping corrupt line",
+				slog.String("path", s.path),
+				slog.Int64("offset", offset),
+				slog.Any("error", err),
+			)
+			continue
+		}
+
+		s.versions[aggregateKey{rec.aggregateType(), rec.AggregateID}] = rec.Sequence
+		s.position = rec.GlobalPosition
+
+		if rec.EventID != "" {
+			s.eventIDs[rec.EventID] = true
+		}
+	}
+}
+
+// Save appends the events to the file and flushes it to disk, provided that
+// the number of events already stored for the aggregate matches the expected
+// version. Events with IDs that have already been saved are left out.
+func (s *fileEventStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []PersistedEvent) (err error) {
+	ctx, span := startSpan(ctx, "EventStore.Save", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Saving only events that have already been saved, e.g. when retrying a
+	// save, succeeds without changes.
+	if len(events) > 0 {
+		unsaved, skipped := s.unsaved(events)
+		recordSkipped(ctx, skipped)
+
+		if events = unsaved; len(events) == 0 {
+			return nil
+		}
+	}
+
+	key := aggregateKey{aggregateType, id}
+
+	if version := s.versions[key]; version != expectedVersion {
+		return &ConcurrencyError{AggregateID: id, Expected: expectedVersion, Actual: version}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	version, position := expectedVersion, s.position
+	for _, e := range events {
+		payload, name, err := MarshalEvent(e.Event)
+		if err != nil {
+			return err
+		}
+
+		version++
+		position++
+
+		if err := enc.Encode(fileRecord{
+			AggregateType:  aggregateType,
+			AggregateID:    id,
+			EventID:        e.EventID,
+			Sequence:       version,
+			GlobalPosition: position,
+			Type:           name,
+			Payload:        payload,
+			OccurredAt:     e.OccurredAt,
+			Metadata:       e.Metadata,
+			SchemaVersion:  schemaVersion(e),
+		}); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	// A failed write may leave part of a line behind, which the next save
+	// would be appended to, so the file is truncated back to its size.
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Truncate(fi.Size())
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Truncate(fi.Size())
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	s.versions[key] = version
+	s.position = position
+
+	for _, e := range events {
+		if e.EventID != "" {
+			s.eventIDs[e.EventID] = true
+		}
+	}
+
+	return nil
+}
+
+// unsaved splits the events into those that haven't been saved and those
+// that have, judging by their event IDs. The caller must hold the lock.
+func (s *fileEventStore) unsaved(events []PersistedEvent) (unsaved, saved []PersistedEvent) {
+	for _, e := range events {
+		if e.EventID == "" || !s.eventIDs[e.EventID] {
+			unsaved = append(unsaved, e)
+		} else {
+			saved = append(saved, e)
+		}
+	}
+	return unsaved, saved
+}
+
+// Load reads the events for the aggregate from the file in sequence order.
+func (s *fileEventStore) Load(ctx context.Context, aggregateType, id string) ([]PersistedEvent, error) {
+	result, err := s.LoadFrom(ctx, aggregateType, id, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, ErrOrderNotFound
+	}
+
+	return result, nil
+}
+
+// LoadFrom reads the events for the aggregate with a sequence number greater
+// than afterSequence from the file, in sequence order.
+func (s *fileEventStore) LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.Load", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+		attribute.Int("sequence.after", afterSequence),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []PersistedEvent{}
+
+	err = s.read(func(e PersistedEvent) bool {
+		return e.AggregateType == aggregateType && e.AggregateID == id && e.Sequence > afterSequence
+	}, func(e PersistedEvent) {
+		result = append(result, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// LoadPage returns up to limit events for the aggregate with a sequence number
+// greater than afterSequence, in sequence order, along with the cursor to pass
+// as afterSequence for the next page. The cursor is zero on the last page.
+func (s *fileEventStore) LoadPage(ctx context.Context, aggregateType, id string, afterSequence, limit int) (_ []PersistedEvent, _ int, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadPage", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+		attribute.Int("sequence.after", afterSequence),
+		attribute.Int("page.limit", limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []PersistedEvent{}
+
+	err = s.read(func(e PersistedEvent) bool {
+		return e.AggregateType == aggregateType && e.AggregateID == id && e.Sequence > afterSequence && (limit <= 0 || len(result) <= limit)
+	}, func(e PersistedEvent) {
+		result = append(result, e)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events, next := page(result, limit)
+
+	return events, next, nil
+}
+
+// LoadMany reads the events for each of the aggregates from the file in a
+// single pass, keyed by aggregate ID. Aggregates without events are absent
+// from the result.
+func (s *fileEventStore) LoadMany(ctx context.Context, aggregateType string, ids []string) (_ map[string][]PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadMany", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.Int("aggregate.count", len(ids)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string][]PersistedEvent)
+
+	err = s.read(func(e PersistedEvent) bool {
+		return e.AggregateType == aggregateType && wanted[e.AggregateID]
+	}, func(e PersistedEvent) {
+		result[e.AggregateID] = append(result[e.AggregateID], e)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// LoadAll returns the events of all orders in the order they were saved.
+func (s *fileEventStore) LoadAll(ctx context.Context) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadAll")
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []PersistedEvent{}
+
+	err = s.read(func(PersistedEvent) bool {
+		return true
+	}, func(e PersistedEvent) {
+		result = append(result, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// LoadAllFrom returns up to limit events of all orders with a global position
+// greater than the given position, in the order they were saved. A limit of
+// zero or less returns all of them.
+func (s *fileEventStore) LoadAllFrom(ctx context.Context, position int64, limit int) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadAllFrom", trace.WithAttributes(
+		attribute.Int64("position.after", position),
+		attribute.Int("page.limit", limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []PersistedEvent{}
+
+	err = s.read(func(e PersistedEvent) bool {
+		return e.GlobalPosition > position && (limit <= 0 || len(result) < limit)
+	}, func(e PersistedEvent) {
+		result = append(result, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Stream sends the events for the aggregate in sequence order on the returned
+// channel, which is closed once all of them have been sent, the context is
+// cancelled or an error occurred. The error, if any, is sent on the error
+// channel.
+func (s *fileEventStore) Stream(ctx context.Context, aggregateType, id string) (<-chan PersistedEvent, <-chan error) {
+	return stream(ctx, s, aggregateType, id)
+}
+
+// read scans the file and passes every event accepted by match to fn, in the
+// order they were saved. Only the payloads of matching events are decoded.
+// Corrupt lines are skipped, having been reported when the store was opened.
+// The caller must hold the read lock.
+func (s *fileEventStore) read(match func(PersistedEvent) bool, fn func(PersistedEvent)) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec fileRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			continue
+		}
+
+		pe := PersistedEvent{
+			EventID:        rec.EventID,
+			Sequence:       rec.Sequence,
+			GlobalPosition: rec.GlobalPosition,
+			AggregateType:  rec.aggregateType(),
+			AggregateID:    rec.AggregateID,
+			OccurredAt:     rec.OccurredAt,
+			Metadata:       rec.Metadata,
+		}
+		if !match(pe) {
+			continue
+		}
+
+		pe.Event, pe.SchemaVersion, err = decodeEvent(rec.Type, rec.SchemaVersion, rec.Payload)
+		if err != nil {
+			return err
+		}
+
+		fn(pe)
+	}
+}
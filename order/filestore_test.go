@@ -0,0 +1,261 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestFileEventStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	store, err := order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "XYZ789", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store, err = order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	repo := order.NewRepository(store)
+	handler = order.NewCommandHandler(repo)
+
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+	}
+
+	events, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(events))
+	}
+
+	if events[1].Sequence != 2 || events[1].GlobalPosition != 3 {
+		t.Errorf("unexpected sequence numbers: %+v", events[1])
+	}
+
+	if err := store.Save(context.Background(), order.AggregateTypeOrder, "XYZ789", 0, nil); !errors.Is(err, order.ErrConcurrencyConflict) {
+		t.Errorf("expected: %v, got: %v", order.ErrConcurrencyConflict, err)
+	}
+}
+
+func TestFileEventStoreTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	store, err := order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := f.WriteString(`{"aggregate_id":"ABC123","sequence":2,"ty`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+
+	store, err = order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	repo := order.NewRepository(store)
+	handler = order.NewCommandHandler(repo)
+
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+	}
+}
+
+func TestFileEventStoreCorruptLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	store, err := order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := f.WriteString("{\"aggregate_id\":\"ABC123\",\"seq\n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "XYZ789", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The events after the corrupt line survive reopening the store.
+	capture := newCapturingHandler()
+
+	store, err = order.NewFileEventStore(path, order.WithFileStoreLogger(slog.New(capture)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, id := range []string{"ABC123", "XYZ789"} {
+		if _, err := store.Load(context.Background(), order.AggregateTypeOrder, id); err != nil {
+			t.Errorf("unexpected error for %v: %v", id, err)
+		}
+	}
+
+	// The corrupt line is logged once when opening the store, rather than on
+	// every load.
+	var warnings int
+	for _, rec := range *capture.records {
+		if rec.level == slog.LevelWarn && rec.attrs["path"] == path {
+			warnings++
+		}
+	}
+
+	if warnings != 1 {
+		t.Errorf("expected: %v, got: %v", 1, warnings)
+	}
+}
+
+func TestFileEventStoreLargeEvent(t *testing.T) {
+	store, err := order.NewFileEventStore(filepath.Join(t.TempDir(), "events.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := []order.Line{{ProductID: strings.Repeat("P", 17*1024*1024), Quantity: 1, UnitPrice: 100}}
+
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: lines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Errorf("expected: %v, got: %v", 1, len(events))
+	}
+}
+
+func TestFileEventStoreLoadMany(t *testing.T) {
+	store, err := order.NewFileEventStore(filepath.Join(t.TempDir(), "events.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	for _, id := range []string{"ABC123", "XYZ789"} {
+		if err := handler.Handle(context.Background(), order.Place{OrderID: order.OrderID(id), Lines: testLines}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := store.LoadMany(context.Background(), order.AggregateTypeOrder, []string{"ABC123", "XYZ789", "MISSING"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for id, want := range map[string]int{"ABC123": 2, "XYZ789": 1, "MISSING": 0} {
+		if got := len(events[id]); got != want {
+			t.Errorf("expected: %v, got: %v", want, got)
+		}
+	}
+
+	if _, ok := events["MISSING"]; ok {
+		t.Errorf("expected missing order to be absent")
+	}
+}
+
+func TestFileEventStoreSkipsSavedEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	store, err := order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := []order.PersistedEvent{{
+		Event:   order.Placed{OrderID: "ABC123", Lines: testLines},
+		EventID: "0157b0c4-a400-7000-8000-000000000001",
+	}}
+
+	if err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The saved event IDs are read back when the store is reopened.
+	store, err = order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(loaded) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(loaded))
+	}
+	if loaded[0].EventID != events[0].EventID {
+		t.Errorf("expected: %v, got: %v", events[0].EventID, loaded[0].EventID)
+	}
+}
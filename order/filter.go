@@ -0,0 +1,26 @@
+package order
+
+import "reflect"
+
+// EventFilter reports whether a subscriber is interested in an event.
+type EventFilter func(PersistedEvent) bool
+
+// ByType returns a filter matching events of the same types as the samples.
+func ByType(samples ...Event) EventFilter {
+	types := make(map[reflect.Type]bool, len(samples))
+	for _, e := range samples {
+		types[reflect.TypeOf(e)] = true
+	}
+
+	return func(e PersistedEvent) bool {
+		return types[reflect.TypeOf(e.Event)]
+	}
+}
+
+// ByAggregate returns a filter matching the events of the aggregate with the
+// given ID.
+func ByAggregate(id string) EventFilter {
+	return func(e PersistedEvent) bool {
+		return e.AggregateID == id
+	}
+}
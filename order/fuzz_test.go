@@ -0,0 +1,147 @@
+package order_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// Operations decoded from the fuzz input, one per byte.
+const (
+	opPlace byte = iota
+	opActivate
+	opCancel
+	opShip
+	opDeliver
+	opHold
+	opReactivate
+	opAddLine
+	opRemoveLine
+	opCount
+)
+
+// applyOps applies the operations to the order, ignoring the ones that are
+// not allowed in its current status. The operand of an operation chooses its
+// product and quantity.
+func applyOps(o *order.Order, ops []byte) {
+	for i := 0; i < len(ops); i++ {
+		var operand byte
+		if i+1 < len(ops) {
+			operand = ops[i+1]
+		}
+
+		line := order.Line{
+			ProductID: string(rune('A' + operand%4)),
+			Quantity:  int(operand%5) + 1,
+			UnitPrice: int64(operand) * 10,
+		}
+
+		switch ops[i] % opCount {
+		case opPlace:
+			o.PlaceForCustomer("alice", []order.Line{line})
+		case opActivate:
+			o.Activate()
+		case opCancel:
+			o.Cancel()
+		case opShip:
+			o.Ship()
+		case opDeliver:
+			o.Deliver()
+		case opHold:
+			o.Hold()
+		case opReactivate:
+			o.Reactivate()
+		case opAddLine:
+			o.AddLine(line)
+		case opRemoveLine:
+			o.RemoveLine(line.ProductID)
+		}
+	}
+}
+
+func FuzzReplayDeterminism(f *testing.F) {
+	for _, seed := range [][]byte{
+		{opPlace},
+		{opPlace, opActivate},
+		{opPlace, opCancel},
+		{opPlace, opActivate, opCancel},
+		{opPlace, opActivate, opShip},
+		{opPlace, opActivate, opShip, opDeliver},
+		{opPlace, opActivate, opHold, opReactivate, opShip},
+		{opPlace, opAddLine, 7, opRemoveLine, 0, opActivate},
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		original := order.NewOrder("ABC123")
+		applyOps(&original, ops)
+
+		if len(original.UncommittedEvents()) == 0 {
+			return
+		}
+
+		// Save a copy to learn the metadata the event stores persist along
+		// with the events.
+		saved := original
+		source := order.NewEventStore()
+		if err := order.NewRepository(source).Save(context.Background(), &saved); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		events, err := source.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Round-trip the events through their stored encoding.
+		history := make([]order.PersistedEvent, len(events))
+		for i, e := range events {
+			data, name, err := order.MarshalEvent(e.Event)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			decoded, err := order.UnmarshalEvent(name, data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			history[i] = order.PersistedEvent{Event: decoded, OccurredAt: e.OccurredAt}
+		}
+
+		store := order.NewEventStore()
+		if err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, history); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		replayed, err := order.NewRepository(store).Load(context.Background(), "ABC123")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Save a copy through a repository snapshotting on every save, so that
+		// loading it restores the snapshot.
+		snapshotted := original
+		repo := order.NewRepository(order.NewEventStore(), order.WithSnapshotEvery(1))
+		if err := repo.Save(context.Background(), &snapshotted); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		restored, err := repo.Load(context.Background(), "ABC123")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		original.MarkCommitted()
+
+		if !reflect.DeepEqual(replayed, original) {
+			t.Errorf("replayed: expected: %+v, got: %+v", original, replayed)
+		}
+		if !reflect.DeepEqual(restored, original) {
+			t.Errorf("restored: expected: %+v, got: %+v", original, restored)
+		}
+	})
+}
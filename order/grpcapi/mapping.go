@@ -0,0 +1,51 @@
+package grpcapi
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/marcusolsson/cqrs-example/order"
+	"github.com/marcusolsson/cqrs-example/order/grpcapi/orderpb"
+)
+
+// statuses maps the order statuses to their proto enum values.
+var statuses = map[order.Status]orderpb.Status{
+	order.StatusPlaced:    orderpb.Status_STATUS_PLACED,
+	order.StatusActivated: orderpb.Status_STATUS_ACTIVATED,
+	order.StatusCancelled: orderpb.Status_STATUS_CANCELLED,
+	order.StatusShipped:   orderpb.Status_STATUS_SHIPPED,
+	order.StatusDelivered: orderpb.Status_STATUS_DELIVERED,
+	order.StatusHeld:      orderpb.Status_STATUS_HELD,
+}
+
+func placeFromProto(req *orderpb.PlaceRequest) order.Place {
+	lines := make([]order.Line, len(req.GetLines()))
+	for i, l := range req.GetLines() {
+		lines[i] = order.Line{
+			ProductID: l.GetProductId(),
+			Quantity:  int(l.GetQuantity()),
+			UnitPrice: l.GetUnitPrice(),
+		}
+	}
+
+	return order.Place{
+		OrderID:    order.OrderID(req.GetOrderId()),
+		CustomerID: req.GetCustomerId(),
+		Lines:      lines,
+	}
+}
+
+func summaryToProto(s order.OrderSummary) *orderpb.Order {
+	o := &orderpb.Order{
+		OrderId:    s.ID,
+		CustomerId: s.CustomerID,
+		Status:     statuses[s.Status],
+		LineCount:  int32(s.LineCount),
+		TotalCents: s.TotalCents,
+	}
+
+	if !s.LastUpdated.IsZero() {
+		o.LastUpdated = timestamppb.New(s.LastUpdated)
+	}
+
+	return o
+}
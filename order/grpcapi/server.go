@@ -0,0 +1,97 @@
+// Package grpcapi exposes order commands and queries as a gRPC service.
+package grpcapi
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative orderpb/order.proto
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/marcusolsson/cqrs-example/order"
+	"github.com/marcusolsson/cqrs-example/order/grpcapi/orderpb"
+)
+
+// Server implements the order service on top of the command and query
+// handlers.
+type Server struct {
+	orderpb.UnimplementedOrderServiceServer
+
+	commands order.CommandHandler
+	queries  order.QueryHandler
+}
+
+var _ orderpb.OrderServiceServer = (*Server)(nil)
+
+// NewServer returns a new server adapting requests to the handlers.
+func NewServer(ch order.CommandHandler, qh order.QueryHandler) *Server {
+	return &Server{commands: ch, queries: qh}
+}
+
+// Place places a new order.
+func (s *Server) Place(ctx context.Context, req *orderpb.PlaceRequest) (*orderpb.PlaceResponse, error) {
+	if err := s.commands.Handle(ctx, placeFromProto(req)); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &orderpb.PlaceResponse{OrderId: req.GetOrderId()}, nil
+}
+
+// Activate activates a placed order.
+func (s *Server) Activate(ctx context.Context, req *orderpb.ActivateRequest) (*orderpb.ActivateResponse, error) {
+	if err := s.commands.Handle(ctx, order.Activate{OrderID: order.OrderID(req.GetOrderId())}); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &orderpb.ActivateResponse{}, nil
+}
+
+// GetOrder returns the summary of an order.
+func (s *Server) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error) {
+	res, err := s.queries.Handle(ctx, order.GetOrder{OrderID: req.GetOrderId()})
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	summary, ok := res.(order.OrderSummary)
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "unexpected query result %T", res)
+	}
+
+	return summaryToProto(summary), nil
+}
+
+// statusError returns the gRPC status for an error returned by a command or
+// query handler.
+func statusError(err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, order.ErrMissingOrderID),
+		errors.Is(err, order.ErrEmptyOrderLine),
+		errors.Is(err, order.ErrInvalidOrderLine),
+		errors.Is(err, order.ErrCurrencyMismatch),
+		errors.Is(err, order.ErrInvalidDiscount),
+		errors.Is(err, order.ErrInvalidCommand):
+		code = codes.InvalidArgument
+	case errors.Is(err, order.ErrUnauthorized):
+		code = codes.PermissionDenied
+	case errors.Is(err, order.ErrOrderNotFound):
+		code = codes.NotFound
+	case errors.Is(err, order.ErrConcurrencyConflict),
+		errors.Is(err, order.ErrAlreadyPlaced),
+		errors.Is(err, order.ErrDiscountAlreadyApplied):
+		code = codes.Aborted
+	case errors.Is(err, order.ErrInvalidTransition):
+		code = codes.FailedPrecondition
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,114 @@
+package grpcapi_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/marcusolsson/cqrs-example/order"
+	"github.com/marcusolsson/cqrs-example/order/grpcapi"
+	"github.com/marcusolsson/cqrs-example/order/grpcapi/orderpb"
+)
+
+func newClient(t *testing.T) orderpb.OrderServiceClient {
+	bus := order.NewEventBus()
+
+	projection := order.NewSummaryProjection()
+	bus.Subscribe(projection.Apply)
+
+	ch := order.NewCommandHandler(order.NewRepository(order.NewEventStore(), order.WithEventBus(bus)))
+	qh := order.NewQueryHandler(projection)
+
+	lis := bufconn.Listen(1024 * 1024)
+
+	srv := grpc.NewServer()
+	orderpb.RegisterOrderServiceServer(srv, grpcapi.NewServer(ch, qh))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return orderpb.NewOrderServiceClient(conn)
+}
+
+func TestPlaceThenGet(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+
+	_, err := client.Place(ctx, &orderpb.PlaceRequest{
+		OrderId:    "ABC123",
+		CustomerId: "alice",
+		Lines:      []*orderpb.Line{{ProductId: "P1", Quantity: 2, UnitPrice: 250}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := client.Activate(ctx, &orderpb.ActivateRequest{OrderId: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := client.GetOrder(ctx, &orderpb.GetOrderRequest{OrderId: "ABC123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.GetOrderId() != "ABC123" || o.GetCustomerId() != "alice" || o.GetStatus() != orderpb.Status_STATUS_ACTIVATED || o.GetLineCount() != 1 || o.GetTotalCents() != 500 {
+		t.Errorf("unexpected order: %v", o)
+	}
+}
+
+func TestStatusCodes(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+
+	place := &orderpb.PlaceRequest{
+		OrderId: "ABC123",
+		Lines:   []*orderpb.Line{{ProductId: "P1", Quantity: 1, UnitPrice: 100}},
+	}
+	if _, err := client.Place(ctx, place); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var tests = []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{name: "empty lines", call: func() error {
+			_, err := client.Place(ctx, &orderpb.PlaceRequest{OrderId: "XYZ789"})
+			return err
+		}, want: codes.InvalidArgument},
+		{name: "already placed", call: func() error {
+			_, err := client.Place(ctx, place)
+			return err
+		}, want: codes.Aborted},
+		{name: "not found", call: func() error {
+			_, err := client.GetOrder(ctx, &orderpb.GetOrderRequest{OrderId: "MISSING"})
+			return err
+		}, want: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call()); got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Package httpapi exposes order commands and queries as JSON over HTTP.
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// lineRequest is the JSON encoding of an order line.
+type lineRequest struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int64  `json:"unit_price"`
+}
+
+// placeRequest is the body of a request to place an order.
+type placeRequest struct {
+	ID         string        `json:"id"`
+	CustomerID string        `json:"customer_id"`
+	Lines      []lineRequest `json:"lines"`
+}
+
+// orderResponse is the JSON encoding of an order summary.
+type orderResponse struct {
+	ID          string    `json:"id"`
+	CustomerID  string    `json:"customer_id,omitempty"`
+	Status      string    `json:"status"`
+	LineCount   int       `json:"line_count"`
+	TotalCents  int64     `json:"total_cents"`
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// errorResponse is the body of a failed request.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// errBadRequest is returned when the request body can't be decoded.
+var errBadRequest = errors.New("malformed request body")
+
+type handler struct {
+	commands  order.CommandHandler
+	queries   order.QueryHandler
+	bus       order.EventBus
+	broker    *broker
+	keepAlive time.Duration
+}
+
+// Option configures the handler.
+type Option func(*handler)
+
+// WithEventBus enables streaming the events published to the bus.
+func WithEventBus(b order.EventBus) Option {
+	return func(h *handler) {
+		h.bus = b
+	}
+}
+
+// WithKeepAlive sets the interval between keep-alive comments sent on event
+// streams that are otherwise idle. The default is 15 seconds.
+func WithKeepAlive(d time.Duration) Option {
+	return func(h *handler) {
+		h.keepAlive = d
+	}
+}
+
+// NewHandler returns a handler serving the following endpoints:
+//
+//	POST /orders               places an order
+//	POST /orders/{id}/activate activates an order
+//	GET  /orders/{id}          returns the summary of an order
+//	GET  /orders/{id}/stream   streams the new events of an order
+//
+// The stream endpoint is only served when an event bus is given.
+func NewHandler(ch order.CommandHandler, qh order.QueryHandler, opts ...Option) http.Handler {
+	h := &handler{
+		commands:  ch,
+		queries:   qh,
+		keepAlive: 15 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /orders", h.place)
+	mux.HandleFunc("POST /orders/{id}/activate", h.activate)
+	mux.HandleFunc("GET /orders/{id}", h.get)
+
+	if h.bus != nil {
+		h.broker = newBroker(h.bus)
+		mux.HandleFunc("GET /orders/{id}/stream", h.stream)
+	}
+
+	return mux
+}
+
+func (h *handler) place(w http.ResponseWriter, r *http.Request) {
+	var req placeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errBadRequest)
+		return
+	}
+
+	lines := make([]order.Line, len(req.Lines))
+	for i, l := range req.Lines {
+		lines[i] = order.Line{ProductID: l.ProductID, Quantity: l.Quantity, UnitPrice: l.UnitPrice}
+	}
+
+	err := h.commands.Handle(r.Context(), order.Place{
+		OrderID:    order.OrderID(req.ID),
+		CustomerID: req.CustomerID,
+		Lines:      lines,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/orders/"+req.ID)
+	writeJSON(w, http.StatusCreated, struct {
+		ID string `json:"id"`
+	}{ID: req.ID})
+}
+
+func (h *handler) activate(w http.ResponseWriter, r *http.Request) {
+	if err := h.commands.Handle(r.Context(), order.Activate{OrderID: order.OrderID(r.PathValue("id"))}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	res, err := h.queries.Handle(r.Context(), order.GetOrder{OrderID: r.PathValue("id")})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s, ok := res.(order.OrderSummary)
+	if !ok {
+		writeError(w, errors.New("unexpected query result"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orderResponse{
+		ID:          s.ID,
+		CustomerID:  s.CustomerID,
+		Status:      s.Status.String(),
+		LineCount:   s.LineCount,
+		TotalCents:  s.TotalCents,
+		LastUpdated: s.LastUpdated,
+	})
+}
+
+// StatusCodeFor returns the HTTP status code for an error returned by a
+// command or query handler, which is the status code of every error response
+// of the handler. Wrapped errors map to the status code of the domain error
+// they wrap, and errors that aren't domain errors map to 500.
+func StatusCodeFor(err error) int {
+	switch {
+	case errors.Is(err, errBadRequest),
+		errors.Is(err, order.ErrMissingOrderID),
+		errors.Is(err, order.ErrEmptyOrderLine),
+		errors.Is(err, order.ErrInvalidOrderLine),
+		errors.Is(err, order.ErrCurrencyMismatch),
+		errors.Is(err, order.ErrInvalidDiscount),
+		errors.Is(err, order.ErrInvalidCommand):
+		return http.StatusBadRequest
+	case errors.Is(err, order.ErrUnauthorized):
+		return http.StatusForbidden
+	case errors.Is(err, order.ErrOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, order.ErrConcurrencyConflict),
+		errors.Is(err, order.ErrAlreadyPlaced),
+		errors.Is(err, order.ErrDiscountAlreadyApplied),
+		errors.Is(err, order.ErrInvalidTransition):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := StatusCodeFor(err)
+
+	msg := err.Error()
+	if code == http.StatusInternalServerError {
+		msg = http.StatusText(code)
+	}
+
+	writeJSON(w, code, errorResponse{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
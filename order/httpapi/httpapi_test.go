@@ -0,0 +1,177 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+	"github.com/marcusolsson/cqrs-example/order/httpapi"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	bus := order.NewEventBus()
+
+	projection := order.NewSummaryProjection()
+	bus.Subscribe(projection.Apply)
+
+	ch := order.NewCommandHandler(order.NewRepository(order.NewEventStore(), order.WithEventBus(bus)))
+	qh := order.NewQueryHandler(projection)
+
+	srv := httptest.NewServer(httpapi.NewHandler(ch, qh))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func do(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+const placeBody = `{"id": "ABC123", "customer_id": "alice", "lines": [{"product_id": "P1", "quantity": 2, "unit_price": 250}]}`
+
+func TestPlaceActivateGet(t *testing.T) {
+	srv := newServer(t)
+
+	resp := do(t, http.MethodPost, srv.URL+"/orders", placeBody)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+	}
+	if got := resp.Header.Get("Location"); got != "/orders/ABC123" {
+		t.Errorf("expected: %v, got: %v", "/orders/ABC123", got)
+	}
+
+	resp = do(t, http.MethodPost, srv.URL+"/orders/ABC123/activate", "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected: %v, got: %v", http.StatusNoContent, resp.StatusCode)
+	}
+
+	resp = do(t, http.MethodGet, srv.URL+"/orders/ABC123", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	var got struct {
+		ID         string `json:"id"`
+		CustomerID string `json:"customer_id"`
+		Status     string `json:"status"`
+		LineCount  int    `json:"line_count"`
+		TotalCents int64  `json:"total_cents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.ID != "ABC123" || got.CustomerID != "alice" || got.Status != "activated" || got.LineCount != 1 || got.TotalCents != 500 {
+		t.Errorf("unexpected order: %+v", got)
+	}
+}
+
+func TestErrorStatusCodes(t *testing.T) {
+	srv := newServer(t)
+
+	if resp := do(t, http.MethodPost, srv.URL+"/orders", placeBody); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+	}
+
+	var tests = []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "malformed body", method: http.MethodPost, path: "/orders", body: `{`, want: http.StatusBadRequest},
+		{name: "missing id", method: http.MethodPost, path: "/orders", body: `{"lines": [{"product_id": "P1", "quantity": 1, "unit_price": 100}]}`, want: http.StatusBadRequest},
+		{name: "empty lines", method: http.MethodPost, path: "/orders", body: `{"id": "XYZ789"}`, want: http.StatusBadRequest},
+		{name: "invalid line", method: http.MethodPost, path: "/orders", body: `{"id": "XYZ789", "lines": [{"product_id": "P1"}]}`, want: http.StatusBadRequest},
+		{name: "already placed", method: http.MethodPost, path: "/orders", body: placeBody, want: http.StatusConflict},
+		{name: "activate unknown", method: http.MethodPost, path: "/orders/MISSING/activate", want: http.StatusNotFound},
+		{name: "get unknown", method: http.MethodGet, path: "/orders/MISSING", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(t, tt.method, srv.URL+tt.path, tt.body)
+			if resp.StatusCode != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, resp.StatusCode)
+			}
+
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if body.Error == "" {
+				t.Errorf("expected error message")
+			}
+		})
+	}
+}
+
+type failingHandler struct {
+	err error
+}
+
+func (h failingHandler) Handle(ctx context.Context, c interface{}) error {
+	return h.err
+}
+
+func TestInternalError(t *testing.T) {
+	var tests = []struct {
+		err  error
+		want int
+	}{
+		{err: &order.ConcurrencyError{AggregateID: "ABC123", Expected: 1, Actual: 2}, want: http.StatusConflict},
+		{err: errors.New("disk full"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		srv := httptest.NewServer(httpapi.NewHandler(failingHandler{err: tt.err}, order.NewQueryHandler(order.NewSummaryProjection())))
+		defer srv.Close()
+
+		resp := do(t, http.MethodPost, srv.URL+"/orders/ABC123/activate", "")
+		if resp.StatusCode != tt.want {
+			t.Errorf("expected: %v, got: %v", tt.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestStatusCodeFor(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{err: order.ErrOrderNotFound, want: http.StatusNotFound},
+		{err: order.ErrInvalidOrderLine, want: http.StatusBadRequest},
+		{err: order.ErrEmptyOrderLine, want: http.StatusBadRequest},
+		{err: order.ErrConcurrencyConflict, want: http.StatusConflict},
+		{err: order.ErrUnauthorized, want: http.StatusForbidden},
+		{err: &order.ValidationError{Fields: []order.FieldError{{Field: "OrderID", Reason: "must not be empty"}}}, want: http.StatusBadRequest},
+		{err: fmt.Errorf("load ABC123: %w", order.ErrOrderNotFound), want: http.StatusNotFound},
+		{err: errors.New("database is down"), want: http.StatusInternalServerError},
+	} {
+		if got := httpapi.StatusCodeFor(tt.err); got != tt.want {
+			t.Errorf("%v: expected: %v, got: %v", tt.err, tt.want, got)
+		}
+	}
+}
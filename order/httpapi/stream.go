@@ -0,0 +1,146 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// streamBuffer is the number of events buffered for each stream. A client
+// that falls further behind is disconnected.
+const streamBuffer = 64
+
+// eventFrame is the JSON encoding of an event in a stream.
+type eventFrame struct {
+	Type       string          `json:"type"`
+	OrderID    string          `json:"order_id"`
+	Sequence   int             `json:"sequence"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Event      json.RawMessage `json:"event"`
+}
+
+// stream receives the events of a single order.
+type stream struct {
+	id     string
+	events chan order.PersistedEvent
+}
+
+// broker fans out the events published to an event bus to the open streams.
+// The bus is only subscribed to once, since it doesn't support
+// unsubscribing.
+type broker struct {
+	mu      sync.Mutex
+	streams map[*stream]struct{}
+}
+
+func newBroker(bus order.EventBus) *broker {
+	b := &broker{streams: make(map[*stream]struct{})}
+	bus.Subscribe(b.publish)
+	return b
+}
+
+func (b *broker) publish(e order.PersistedEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.streams {
+		if s.id != e.AggregateID {
+			continue
+		}
+
+		select {
+		case s.events <- e:
+		default:
+			// Close the stream rather than blocking the bus.
+			delete(b.streams, s)
+			close(s.events)
+		}
+	}
+}
+
+func (b *broker) subscribe(id string) *stream {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &stream{id: id, events: make(chan order.PersistedEvent, streamBuffer)}
+	b.streams[s] = struct{}{}
+
+	return s
+}
+
+func (b *broker) unsubscribe(s *stream) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.streams[s]; ok {
+		delete(b.streams, s)
+		close(s.events)
+	}
+}
+
+// stream writes the events of the order as server-sent events until the
+// client disconnects.
+func (h *handler) stream(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	s := h.broker.subscribe(r.PathValue("id"))
+	defer h.broker.unsubscribe(s)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(h.keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-s.events:
+			if !ok {
+				return
+			}
+			if err := writeFrame(w, e); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeFrame(w http.ResponseWriter, e order.PersistedEvent) error {
+	payload, name, err := order.MarshalEvent(e.Event)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(eventFrame{
+		Type:       name,
+		OrderID:    e.AggregateID,
+		Sequence:   e.Sequence,
+		OccurredAt: e.OccurredAt,
+		Event:      payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
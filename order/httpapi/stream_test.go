@@ -0,0 +1,87 @@
+package httpapi_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+	"github.com/marcusolsson/cqrs-example/order/httpapi"
+)
+
+func TestStream(t *testing.T) {
+	bus := order.NewEventBus()
+
+	projection := order.NewSummaryProjection()
+	bus.Subscribe(projection.Apply)
+
+	ch := order.NewCommandHandler(order.NewRepository(order.NewEventStore(), order.WithEventBus(bus)))
+	qh := order.NewQueryHandler(projection)
+
+	srv := httptest.NewServer(httpapi.NewHandler(ch, qh,
+		httpapi.WithEventBus(bus),
+		httpapi.WithKeepAlive(10*time.Millisecond),
+	))
+	defer srv.Close()
+
+	if resp := do(t, http.MethodPost, srv.URL+"/orders", placeBody); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/orders/ABC123/stream", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("expected: %v, got: %v", "text/event-stream", got)
+	}
+
+	// Events of other orders must not be forwarded.
+	other := `{"id": "XYZ789", "lines": [{"product_id": "P1", "quantity": 1, "unit_price": 100}]}`
+	if resp := do(t, http.MethodPost, srv.URL+"/orders", other); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+	}
+	if resp := do(t, http.MethodPost, srv.URL+"/orders/ABC123/activate", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected: %v, got: %v", http.StatusNoContent, resp.StatusCode)
+	}
+
+	var frame struct {
+		Type     string `json:"type"`
+		OrderID  string `json:"order_id"`
+		Sequence int    `json:"sequence"`
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Skip keep-alive comments.
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			if err := json.Unmarshal([]byte(data), &frame); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if frame.Type != "order.Activated" || frame.OrderID != "ABC123" || frame.Sequence != 2 {
+		t.Errorf("unexpected frame: %+v", frame)
+	}
+}
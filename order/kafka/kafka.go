@@ -0,0 +1,90 @@
+// Package kafka provides a publisher of committed events to Apache Kafka.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// EventTypeHeader is the message header carrying the name of the event type.
+const EventTypeHeader = "event-type"
+
+// Writer writes messages to Kafka. It is implemented by *kafkago.Writer.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+}
+
+// message is the JSON encoding of a persisted event in a Kafka message.
+type message struct {
+	AggregateType  string          `json:"aggregate_type"`
+	AggregateID    string          `json:"aggregate_id"`
+	Sequence       int             `json:"sequence"`
+	GlobalPosition int64           `json:"global_position"`
+	Type           string          `json:"type"`
+	Payload        json.RawMessage `json:"payload"`
+	OccurredAt     time.Time       `json:"occurred_at"`
+	Metadata       order.Metadata  `json:"metadata"`
+	SchemaVersion  int             `json:"schema_version"`
+}
+
+// Publisher publishes committed events to a Kafka topic. Each message is keyed
+// by the aggregate ID, so that the events of an order end up on the same
+// partition and are consumed in order.
+type Publisher struct {
+	writer Writer
+	topic  string
+}
+
+var _ order.Publisher = (*Publisher)(nil)
+
+// Publish writes the events to the topic in a single batch.
+func (p *Publisher) Publish(ctx context.Context, events []order.PersistedEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	msgs := make([]kafkago.Message, len(events))
+	for i, e := range events {
+		payload, name, err := order.MarshalEvent(e.Event)
+		if err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(message{
+			AggregateType:  e.AggregateType,
+			AggregateID:    e.AggregateID,
+			Sequence:       e.Sequence,
+			GlobalPosition: e.GlobalPosition,
+			Type:           name,
+			Payload:        payload,
+			OccurredAt:     e.OccurredAt,
+			Metadata:       e.Metadata,
+			SchemaVersion:  e.SchemaVersion,
+		})
+		if err != nil {
+			return err
+		}
+
+		msgs[i] = kafkago.Message{
+			Topic: p.topic,
+			Key:   []byte(e.AggregateID),
+			Value: value,
+			Headers: []kafkago.Header{
+				{Key: EventTypeHeader, Value: []byte(name)},
+			},
+		}
+	}
+
+	return p.writer.WriteMessages(ctx, msgs...)
+}
+
+// NewKafkaPublisher returns a publisher writing to the given topic. Since the
+// topic is set on each message, the writer must not have a topic of its own.
+func NewKafkaPublisher(writer Writer, topic string) *Publisher {
+	return &Publisher{writer: writer, topic: topic}
+}
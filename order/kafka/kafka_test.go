@@ -0,0 +1,98 @@
+package kafka_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/marcusolsson/cqrs-example/order"
+	"github.com/marcusolsson/cqrs-example/order/kafka"
+)
+
+type mockWriter struct {
+	batches [][]kafkago.Message
+	err     error
+}
+
+func (w *mockWriter) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	w.batches = append(w.batches, msgs)
+	return w.err
+}
+
+var _ kafka.Writer = (*kafkago.Writer)(nil)
+
+func TestPublisher(t *testing.T) {
+	w := &mockWriter{}
+	p := kafka.NewKafkaPublisher(w, "orders")
+
+	events := []order.PersistedEvent{
+		{Event: order.Placed{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 1},
+		{Event: order.Activated{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 2},
+	}
+
+	if err := p.Publish(context.Background(), events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(w.batches) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(w.batches))
+	}
+
+	msgs := w.batches[0]
+	if len(msgs) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(msgs))
+	}
+
+	for i, want := range []string{"order.Placed", "order.Activated"} {
+		msg := msgs[i]
+
+		if msg.Topic != "orders" {
+			t.Errorf("expected: %v, got: %v", "orders", msg.Topic)
+		}
+		if string(msg.Key) != "ABC123" {
+			t.Errorf("expected: %v, got: %v", "ABC123", string(msg.Key))
+		}
+		if len(msg.Headers) != 1 || msg.Headers[0].Key != kafka.EventTypeHeader || string(msg.Headers[0].Value) != want {
+			t.Errorf("unexpected headers: %v", msg.Headers)
+		}
+
+		var value struct {
+			AggregateID string          `json:"aggregate_id"`
+			Sequence    int             `json:"sequence"`
+			Type        string          `json:"type"`
+			Payload     json.RawMessage `json:"payload"`
+		}
+		if err := json.Unmarshal(msg.Value, &value); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if value.AggregateID != "ABC123" || value.Sequence != i+1 || value.Type != want {
+			t.Errorf("unexpected value: %s", msg.Value)
+		}
+
+		e, err := order.UnmarshalEvent(value.Type, value.Payload)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(e, events[i].Event) {
+			t.Errorf("expected: %v, got: %v", events[i].Event, e)
+		}
+	}
+}
+
+func TestPublisherError(t *testing.T) {
+	errWrite := errors.New("broker unavailable")
+
+	p := kafka.NewKafkaPublisher(&mockWriter{err: errWrite}, "orders")
+
+	err := p.Publish(context.Background(), []order.PersistedEvent{
+		{Event: order.Activated{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 2},
+	})
+	if !errors.Is(err, errWrite) {
+		t.Errorf("expected: %v, got: %v", errWrite, err)
+	}
+}
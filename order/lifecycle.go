@@ -0,0 +1,118 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned when running a background component after it has
+// been closed.
+var ErrClosed = errors.New("closed")
+
+// lifecycle keeps track of the loops run by a background component, so that
+// closing the component stops the loops and waits for them to return. The
+// zero value is ready to use.
+type lifecycle struct {
+	mu     sync.Mutex
+	stop   chan struct{}
+	closed bool
+	err    error
+	loops  sync.WaitGroup
+}
+
+// stopping returns a channel that is closed once the component is closed.
+func (l *lifecycle) stopping() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.stopChan()
+}
+
+// stopChan returns the stop channel, creating it if needed. The caller must
+// hold the lock.
+func (l *lifecycle) stopChan() chan struct{} {
+	if l.stop == nil {
+		l.stop = make(chan struct{})
+	}
+	return l.stop
+}
+
+// check returns ErrClosed once the component is closed.
+func (l *lifecycle) check() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// enter registers a loop about to run, unless the component is closed.
+func (l *lifecycle) enter() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	l.loops.Add(1)
+
+	return nil
+}
+
+// exit registers that a loop has returned.
+func (l *lifecycle) exit() {
+	l.loops.Done()
+}
+
+// start runs the loop in a new goroutine, keeping the error it returns, if
+// any, to be returned when closing the component.
+func (l *lifecycle) start(run func() error) error {
+	if err := l.enter(); err != nil {
+		return err
+	}
+
+	go func() {
+		defer l.exit()
+
+		if err := run(); err != nil {
+			l.mu.Lock()
+			l.err = err
+			l.mu.Unlock()
+		}
+	}()
+
+	return nil
+}
+
+// close stops the loops and waits for them to return, or for the context to
+// be done. It returns the error that stopped a loop started with start, if
+// any.
+func (l *lifecycle) close(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.stopChan())
+	}
+	l.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		l.loops.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.err
+}
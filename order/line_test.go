@@ -0,0 +1,184 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestAddAndRemoveLines(t *testing.T) {
+	repo := order.NewRepository(order.NewEventStore())
+	handler := order.NewCommandHandler(repo)
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: []order.Line{{ProductID: "P1", Quantity: 1, UnitPrice: 100}}},
+		order.AddLine{OrderID: "ABC123", Line: order.Line{ProductID: "P2", Quantity: 2, UnitPrice: 250}},
+		order.AddLine{OrderID: "ABC123", Line: order.Line{ProductID: "P3", Quantity: 1, UnitPrice: 1000}},
+		order.RemoveLine{OrderID: "ABC123", ProductID: "P1"},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error for %T: %v", cmd, err)
+		}
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []order.Line{
+		{ProductID: "P2", Quantity: 2, UnitPrice: 250},
+		{ProductID: "P3", Quantity: 1, UnitPrice: 1000},
+	}
+
+	if !reflect.DeepEqual(o.Lines, want) {
+		t.Errorf("expected: %v, got: %v", want, o.Lines)
+	}
+
+	if got, _ := o.Total(); got != 1500 {
+		t.Errorf("expected: %v, got: %v", 1500, got)
+	}
+}
+
+func TestRemoveMissingLine(t *testing.T) {
+	handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore()))
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := handler.Handle(context.Background(), order.RemoveLine{OrderID: "ABC123", ProductID: "P9"})
+	if !errors.Is(err, order.ErrLineNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrLineNotFound, err)
+	}
+}
+
+func TestAddLineAfterActivation(t *testing.T) {
+	handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore()))
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := handler.Handle(context.Background(), order.AddLine{OrderID: "ABC123", Line: order.Line{ProductID: "P2", Quantity: 1}})
+	if !errors.Is(err, order.ErrOrderNotAmendable) {
+		t.Errorf("expected: %v, got: %v", order.ErrOrderNotAmendable, err)
+	}
+}
+
+func TestAddInvalidLine(t *testing.T) {
+	handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore()))
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := handler.Handle(context.Background(), order.AddLine{OrderID: "ABC123", Line: order.Line{ProductID: "P2"}})
+	if !errors.Is(err, order.ErrInvalidOrderLine) {
+		t.Errorf("expected: %v, got: %v", order.ErrInvalidOrderLine, err)
+	}
+}
+
+func TestChangeLineQuantity(t *testing.T) {
+	store := order.NewEventStore()
+	repo := order.NewRepository(store)
+	handler := order.NewCommandHandler(repo)
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: []order.Line{{ProductID: "P1", Quantity: 1, UnitPrice: 100}}},
+		order.AddLine{OrderID: "ABC123", Line: order.Line{ProductID: "P2", Quantity: 2, UnitPrice: 250}},
+		order.ChangeLineQuantity{OrderID: "ABC123", ProductID: "P1", NewQuantity: 3},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error for %T: %v", cmd, err)
+		}
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []order.Line{
+		{ProductID: "P1", Quantity: 3, UnitPrice: 100},
+		{ProductID: "P2", Quantity: 2, UnitPrice: 250},
+	}
+
+	if !reflect.DeepEqual(o.Lines, want) {
+		t.Errorf("expected: %v, got: %v", want, o.Lines)
+	}
+
+	if got, _ := o.Total(); got != 800 {
+		t.Errorf("expected: %v, got: %v", 800, got)
+	}
+
+	events, err := store.LoadAll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p := order.NewSummaryProjection()
+	p.Rebuild(events)
+
+	if s, _ := p.Get("ABC123"); s.TotalCents != 800 {
+		t.Errorf("expected: %v, got: %v", 800, s.TotalCents)
+	}
+}
+
+func TestChangeLineQuantityErrors(t *testing.T) {
+	handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore()))
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		cmd  order.ChangeLineQuantity
+		want error
+	}{
+		{
+			name: "invalid quantity",
+			cmd:  order.ChangeLineQuantity{OrderID: "ABC123", ProductID: "P1", NewQuantity: 0},
+			want: order.ErrInvalidOrderLine,
+		},
+		{
+			name: "missing product",
+			cmd:  order.ChangeLineQuantity{OrderID: "ABC123", ProductID: "P9", NewQuantity: 2},
+			want: order.ErrLineNotFound,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := handler.Handle(context.Background(), tt.cmd); !errors.Is(err, tt.want) {
+				t.Errorf("expected: %v, got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSummaryProjectionLines(t *testing.T) {
+	p := order.NewSummaryProjection()
+
+	for i, e := range []order.Event{
+		order.Placed{OrderID: "ABC123", Lines: []order.Line{{ProductID: "P1", Quantity: 1, UnitPrice: 100}}},
+		order.LineAdded{OrderID: "ABC123", Line: order.Line{ProductID: "P2", Quantity: 2, UnitPrice: 250}},
+		order.LineRemoved{OrderID: "ABC123", ProductID: "P1"},
+	} {
+		p.Apply(order.PersistedEvent{Event: e, Sequence: i + 1, AggregateID: "ABC123"})
+	}
+
+	s, _ := p.Get("ABC123")
+
+	if s.LineCount != 1 {
+		t.Errorf("expected: %v, got: %v", 1, s.LineCount)
+	}
+	if s.TotalCents != 500 {
+		t.Errorf("expected: %v, got: %v", 500, s.TotalCents)
+	}
+}
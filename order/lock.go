@@ -0,0 +1,98 @@
+package order
+
+import (
+	"context"
+	"sync"
+)
+
+// AggregateLocker serializes writes to the same aggregate, so that concurrent
+// commands to a hot aggregate wait for each other instead of conflicting,
+// while commands to different aggregates proceed in parallel. The locker is
+// safe for concurrent use by multiple goroutines.
+//
+// The locker only serializes writes within a single process; conflicts with
+// other processes writing to the same event store are still detected by the
+// store.
+type AggregateLocker struct {
+	mu    sync.Mutex
+	locks map[string]*aggregateLock
+}
+
+// aggregateLock is the lock of a single aggregate, held by sending to the
+// channel. It is removed from the locker once no one holds or waits for it.
+type aggregateLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// NewAggregateLocker returns a new locker without any held locks.
+func NewAggregateLocker() *AggregateLocker {
+	return &AggregateLocker{
+		locks: make(map[string]*aggregateLock),
+	}
+}
+
+// Lock waits until the lock of the aggregate is acquired, or until the
+// context is cancelled. The returned function releases the lock.
+func (l *AggregateLocker) Lock(ctx context.Context, id string) (func(), error) {
+	l.mu.Lock()
+	lock, ok := l.locks[id]
+	if !ok {
+		lock = &aggregateLock{ch: make(chan struct{}, 1)}
+		l.locks[id] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	select {
+	case lock.ch <- struct{}{}:
+	case <-ctx.Done():
+		l.release(id, lock)
+		return nil, ctx.Err()
+	}
+
+	return func() {
+		<-lock.ch
+		l.release(id, lock)
+	}, nil
+}
+
+// release drops a reference to the lock, removing it once it is unused.
+func (l *AggregateLocker) release(id string, lock *aggregateLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.locks, id)
+	}
+}
+
+// LockingMiddleware handles the commands to the same order one at a time,
+// using the locker. Commands wrapped in a CommandEnvelope are locked on the
+// order of the wrapped command, and commands without an order ID aren't
+// locked.
+func LockingMiddleware(l *AggregateLocker) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+			cmd := c
+			if env, ok := c.(CommandEnvelope); ok {
+				ctx = envelopeContext(ctx, env)
+				cmd = env.Command
+			}
+
+			id := commandOrderID(cmd)
+			if id == "" {
+				return next.Handle(ctx, c)
+			}
+
+			unlock, err := l.Lock(ctx, id)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			return next.Handle(ctx, c)
+		})
+	}
+}
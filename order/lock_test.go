@@ -0,0 +1,83 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestLockingMiddlewareSerializesCommands(t *testing.T) {
+	repo := order.NewRepository(order.NewEventStore())
+	handler := order.Chain(order.NewCommandHandler(repo), order.LockingMiddleware(order.NewAggregateLocker()))
+
+	bus := order.NewCommandBus()
+	for _, sample := range []interface{}{order.Place{}, order.AddLine{}} {
+		if err := bus.Register(sample, handler.Handle); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if _, err := bus.Dispatch(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const n = 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			line := order.Line{ProductID: fmt.Sprintf("P%d", i+2), Quantity: 1, UnitPrice: 100}
+			if _, err := bus.Dispatch(context.Background(), order.AddLine{OrderID: "ABC123", Line: line}); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(o.Lines) != n+1 {
+		t.Errorf("expected: %v, got: %v", n+1, len(o.Lines))
+	}
+}
+
+func TestAggregateLockerCancelledWait(t *testing.T) {
+	l := order.NewAggregateLocker()
+
+	unlock, err := l.Lock(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer unlock()
+
+	// Other aggregates aren't blocked.
+	other, err := l.Lock(context.Background(), "XYZ789")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := l.Lock(ctx, "ABC123"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected: %v, got: %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,104 @@
+package order_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// capturingHandler records every log record along with its attributes.
+type capturingHandler struct {
+	mu      *sync.Mutex
+	attrs   []slog.Attr
+	records *[]capturedRecord
+}
+
+type capturedRecord struct {
+	level   slog.Level
+	message string
+	attrs   map[string]string
+}
+
+func newCapturingHandler() *capturingHandler {
+	return &capturingHandler{
+		mu:      &sync.Mutex{},
+		records: &[]capturedRecord{},
+	}
+}
+
+func (h *capturingHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *capturingHandler) Handle(_ context.Context, r slog.Record) error {
+	rec := capturedRecord{
+		level:   r.Level,
+		message: r.Message,
+		attrs:   make(map[string]string),
+	}
+
+	for _, a := range h.attrs {
+		rec.attrs[a.Key] = a.Value.String()
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		rec.attrs[a.Key] = a.Value.String()
+		return true
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	*h.records = append(*h.records, rec)
+
+	return nil
+}
+
+func (h *capturingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &capturingHandler{
+		mu:      h.mu,
+		attrs:   append(append([]slog.Attr(nil), h.attrs...), attrs...),
+		records: h.records,
+	}
+}
+
+func (h *capturingHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+func TestFailedCommandLogsError(t *testing.T) {
+	capture := newCapturingHandler()
+
+	handler := order.NewCommandHandler(
+		order.NewRepository(order.NewEventStore()),
+		order.WithLogger(slog.New(capture)),
+	)
+
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	var found bool
+	for _, rec := range *capture.records {
+		if rec.level != slog.LevelError {
+			continue
+		}
+		found = true
+
+		if got := rec.attrs["aggregate_id"]; got != "ABC123" {
+			t.Errorf("expected: %v, got: %v", "ABC123", got)
+		}
+		if got := rec.attrs["command_type"]; got != "order.Activate" {
+			t.Errorf("expected: %v, got: %v", "order.Activate", got)
+		}
+		if got := rec.attrs["error"]; got != order.ErrOrderNotFound.Error() {
+			t.Errorf("expected: %v, got: %v", order.ErrOrderNotFound, got)
+		}
+	}
+
+	if !found {
+		t.Errorf("expected an error to be logged")
+	}
+}
@@ -0,0 +1,137 @@
+package order
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Metadata links an event to the command that caused it. Every event caused,
+// directly or indirectly, by the same initial command shares its correlation
+// ID, while the causation ID identifies the command that caused the event.
+type Metadata struct {
+	CorrelationID string
+	CausationID   string
+
+	// TenantID and UserID identify the tenant and the user on whose behalf
+	// the command was issued.
+	TenantID string `json:",omitempty"`
+	UserID   string `json:",omitempty"`
+
+	// Values holds the metadata of the command envelope.
+	Values map[string]string `json:",omitempty"`
+}
+
+// Child returns the metadata for events caused by the given command, issued in
+// response to an event with this metadata.
+func (m Metadata) Child(commandID string) Metadata {
+	return Metadata{
+		CorrelationID: m.CorrelationID,
+		CausationID:   commandID,
+		TenantID:      m.TenantID,
+		UserID:        m.UserID,
+		Values:        copyValues(m.Values),
+	}
+}
+
+// FollowUp wraps a command issued in response to the parent event, continuing
+// the correlation of the parent on behalf of the same tenant and user.
+func FollowUp(parent PersistedEvent, commandID string, cmd interface{}) CommandEnvelope {
+	return CommandEnvelope{
+		CommandID:     commandID,
+		CorrelationID: parent.Metadata.CorrelationID,
+		TenantID:      parent.Metadata.TenantID,
+		UserID:        parent.Metadata.UserID,
+		Metadata:      copyValues(parent.Metadata.Values),
+		Command:       cmd,
+	}
+}
+
+// NewCommandEnvelope wraps a bare command with a new command ID, continuing
+// the correlation, tenant and user carried by the context, if any.
+func NewCommandEnvelope(ctx context.Context, cmd interface{}) CommandEnvelope {
+	env := CommandEnvelope{
+		CommandID:     newCommandID(),
+		CorrelationID: metadataFrom(ctx).CorrelationID,
+		Command:       cmd,
+	}
+
+	env.TenantID, _ = TenantFromContext(ctx)
+	env.UserID, _ = UserIDFromContext(ctx)
+
+	return env
+}
+
+// newCommandID returns a random command ID.
+func newCommandID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// envelopeMetadata returns the metadata for events caused by the command in
+// the envelope. A command without a correlation ID starts a new correlation.
+func envelopeMetadata(env CommandEnvelope) Metadata {
+	correlationID := env.CorrelationID
+	if correlationID == "" {
+		correlationID = env.CommandID
+	}
+
+	return Metadata{
+		CorrelationID: correlationID,
+		CausationID:   env.CommandID,
+		TenantID:      env.TenantID,
+		UserID:        env.UserID,
+		Values:        copyValues(env.Metadata),
+	}
+}
+
+// envelopeContext returns a copy of the context carrying the metadata of the
+// envelope, along with its tenant and user, if any, for the event stores and
+// middlewares reading them from the context.
+func envelopeContext(ctx context.Context, env CommandEnvelope) context.Context {
+	if env.TenantID != "" {
+		ctx = WithTenant(ctx, env.TenantID)
+	}
+	if env.UserID != "" {
+		ctx = WithUserID(ctx, env.UserID)
+	}
+	return withMetadata(ctx, envelopeMetadata(env))
+}
+
+// unmarshalMetadata decodes the metadata stored as JSON by the SQL event
+// stores into m. Events stored before the metadata was, whose stored metadata
+// is empty, keep the correlation and causation IDs already in m.
+func unmarshalMetadata(data []byte, m *Metadata) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, m)
+}
+
+// copyValues returns a copy of the metadata values.
+func copyValues(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+
+	result := make(map[string]string, len(values))
+	for k, v := range values {
+		result[k] = v
+	}
+	return result
+}
+
+type metadataKey struct{}
+
+// withMetadata returns a copy of the context carrying the metadata.
+func withMetadata(ctx context.Context, m Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, m)
+}
+
+// metadataFrom returns the metadata carried by the context, if any.
+func metadataFrom(ctx context.Context) Metadata {
+	m, _ := ctx.Value(metadataKey{}).(Metadata)
+	return m
+}
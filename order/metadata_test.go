@@ -0,0 +1,113 @@
+package order_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestEventMetadata(t *testing.T) {
+	store := order.NewEventStore()
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	if err := handler.Handle(context.Background(), order.CommandEnvelope{
+		CommandID: "CMD1",
+		Command:   order.Place{OrderID: "ABC123", Lines: testLines},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := handler.Handle(context.Background(), order.FollowUp(events[0], "CMD2", order.Activate{OrderID: "ABC123"})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err = store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []order.Metadata{
+		{CorrelationID: "CMD1", CausationID: "CMD1"},
+		{CorrelationID: "CMD1", CausationID: "CMD2"},
+	}
+
+	for i, e := range events {
+		if !reflect.DeepEqual(e.Metadata, want[i]) {
+			t.Errorf("expected: %+v, got: %+v", want[i], e.Metadata)
+		}
+	}
+}
+
+func TestMetadataChild(t *testing.T) {
+	parent := order.Metadata{CorrelationID: "CMD1", CausationID: "CMD1"}
+
+	got := parent.Child("CMD2")
+	want := order.Metadata{CorrelationID: "CMD1", CausationID: "CMD2"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected: %+v, got: %+v", want, got)
+	}
+}
+
+func TestCommandEnvelopeMetadata(t *testing.T) {
+	handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore()))
+
+	bus := order.NewCommandBus()
+	if err := bus.Register(order.Place{}, handler.Handle); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := bus.Dispatch(context.Background(), order.CommandEnvelope{
+		CommandID: "CMD1",
+		TenantID:  "acme",
+		UserID:    "alice",
+		Metadata:  map[string]string{"source": "web"},
+		Command:   order.Place{OrderID: "ABC123", Lines: testLines},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(events))
+	}
+
+	want := order.Metadata{
+		CorrelationID: "CMD1",
+		CausationID:   "CMD1",
+		TenantID:      "acme",
+		UserID:        "alice",
+		Values:        map[string]string{"source": "web"},
+	}
+
+	if !reflect.DeepEqual(events[0].Metadata, want) {
+		t.Errorf("expected: %+v, got: %+v", want, events[0].Metadata)
+	}
+}
+
+func TestNewCommandEnvelope(t *testing.T) {
+	ctx := order.WithUserID(order.WithTenant(context.Background(), "acme"), "alice")
+
+	env := order.NewCommandEnvelope(ctx, order.Activate{OrderID: "ABC123"})
+
+	if env.CommandID == "" {
+		t.Errorf("expected a command ID")
+	}
+	if env.TenantID != "acme" || env.UserID != "alice" {
+		t.Errorf("expected: %v, got: %v", []string{"acme", "alice"}, []string{env.TenantID, env.UserID})
+	}
+	if env.Command != (order.Activate{OrderID: "ABC123"}) {
+		t.Errorf("expected: %v, got: %v", order.Activate{OrderID: "ABC123"}, env.Command)
+	}
+
+	if other := order.NewCommandEnvelope(ctx, env.Command); other.CommandID == env.CommandID {
+		t.Errorf("expected unique command IDs, got: %v twice", env.CommandID)
+	}
+}
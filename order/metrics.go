@@ -0,0 +1,15 @@
+package order
+
+import "time"
+
+// Metrics receives measurements of the handled commands and saved events.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	ObserveCommand(name string, d time.Duration, err error)
+	CountEvents(aggregateType string, n int)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveCommand(string, time.Duration, error) {}
+func (nopMetrics) CountEvents(string, int)                     {}
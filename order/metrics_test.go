@@ -0,0 +1,75 @@
+package order_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+type fakeMetrics struct {
+	mu        sync.Mutex
+	commands  map[string]int
+	durations map[string]time.Duration
+	failures  map[string]int
+	events    map[string]int
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{
+		commands:  make(map[string]int),
+		durations: make(map[string]time.Duration),
+		failures:  make(map[string]int),
+		events:    make(map[string]int),
+	}
+}
+
+func (m *fakeMetrics) ObserveCommand(name string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.commands[name]++
+	m.durations[name] += d
+	if err != nil {
+		m.failures[name]++
+	}
+}
+
+func (m *fakeMetrics) CountEvents(aggregateType string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events[aggregateType] += n
+}
+
+func TestCommandMetrics(t *testing.T) {
+	metrics := newFakeMetrics()
+
+	repo := order.NewRepository(order.NewEventStore(), order.WithRepositoryMetrics(metrics))
+	handler := order.NewCommandHandler(repo, order.WithMetrics(metrics))
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "XYZ789"}); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if got := metrics.commands["order.Place"]; got != 1 {
+		t.Errorf("expected: %v, got: %v", 1, got)
+	}
+	if got := metrics.durations["order.Place"]; got <= 0 {
+		t.Errorf("expected a positive duration, got: %v", got)
+	}
+	if got := metrics.failures["order.Place"]; got != 0 {
+		t.Errorf("expected: %v, got: %v", 0, got)
+	}
+	if got := metrics.failures["order.Activate"]; got != 1 {
+		t.Errorf("expected: %v, got: %v", 1, got)
+	}
+	if got := metrics.events["order"]; got != 1 {
+		t.Errorf("expected: %v, got: %v", 1, got)
+	}
+}
@@ -0,0 +1,120 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// CommandHandlerFunc is an adapter allowing the use of ordinary functions as
+// command handlers.
+type CommandHandlerFunc func(ctx context.Context, c interface{}) error
+
+// Handle calls f(ctx, c).
+func (f CommandHandlerFunc) Handle(ctx context.Context, c interface{}) error {
+	return f(ctx, c)
+}
+
+// Middleware wraps a command handler to add behavior before or after it
+// handles a command.
+type Middleware func(next CommandHandler) CommandHandler
+
+// Chain wraps the handler with the middlewares. The first middleware is the
+// outermost, i.e. it is the first to see each command.
+func Chain(h CommandHandler, mws ...Middleware) CommandHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Logger is the interface used by the logging middleware. It is satisfied by
+// *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// LoggingMiddleware logs the type of every command along with the time it took
+// to handle it.
+func LoggingMiddleware(logger Logger) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+			defer func(begin time.Time) {
+				logger.Printf("command=%T took=%v", c, time.Since(begin))
+			}(time.Now())
+
+			return next.Handle(ctx, c)
+		})
+	}
+}
+
+// RetryMiddleware retries commands that failed with a concurrency conflict,
+// making at most maxAttempts attempts in total. The command handler reloads
+// the order on every attempt, so a retry re-applies the command to the latest
+// version of the order. Before each retry, the middleware waits for the
+// duration returned by backoff for the number of the retry, starting at 1.
+// The last error is returned once all attempts have failed, or the error of
+// the context if it is cancelled while waiting.
+func RetryMiddleware(maxAttempts int, backoff func(attempt int) time.Duration) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+			err := next.Handle(ctx, c)
+
+			for attempt := 1; attempt < maxAttempts && retryable(err); attempt++ {
+				timer := time.NewTimer(backoff(attempt))
+
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+
+				err = next.Handle(ctx, c)
+			}
+
+			return err
+		})
+	}
+}
+
+// retryable reports whether the command may succeed if handled again. Placing
+// an order that already exists conflicts on every attempt.
+func retryable(err error) bool {
+	return errors.Is(err, ErrConcurrencyConflict) && !errors.Is(err, ErrAlreadyPlaced)
+}
+
+// TimeoutMiddleware limits the time spent handling each command to d. A
+// command that fails after the deadline has passed returns
+// context.DeadlineExceeded, even if the handler reported a different error as
+// a consequence.
+func TimeoutMiddleware(d time.Duration) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			err := next.Handle(ctx, c)
+			if err != nil && ctx.Err() == context.DeadlineExceeded {
+				return context.DeadlineExceeded
+			}
+
+			return err
+		})
+	}
+}
+
+// ExponentialBackoff returns a backoff for RetryMiddleware that doubles the
+// wait for every attempt, starting at base and capped at max.
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
@@ -0,0 +1,235 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+
+	record := func(name string) order.Middleware {
+		return func(next order.CommandHandler) order.CommandHandler {
+			return order.CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+				calls = append(calls, name+" before")
+				err := next.Handle(ctx, c)
+				calls = append(calls, name+" after")
+				return err
+			})
+		}
+	}
+
+	inner := order.CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+
+	h := order.Chain(inner, record("first"), record("second"))
+	if err := h.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"first before",
+		"second before",
+		"handler",
+		"second after",
+		"first after",
+	}
+
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected: %v, got: %v", want, calls)
+	}
+}
+
+type logRecorder struct {
+	lines []string
+}
+
+func (l *logRecorder) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	logger := &logRecorder{}
+
+	repo := order.NewRepository(order.NewEventStore())
+	handler := order.Chain(order.NewCommandHandler(repo), order.LoggingMiddleware(logger))
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(logger.lines) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(logger.lines))
+	}
+
+	if !strings.Contains(logger.lines[0], "command=order.Place") {
+		t.Errorf("expected command type in %q", logger.lines[0])
+	}
+}
+
+// conflictingStore fails the first saves with a concurrency conflict.
+type conflictingStore struct {
+	order.EventStore
+	conflicts int
+	saves     int
+}
+
+func (s *conflictingStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []order.PersistedEvent) error {
+	s.saves++
+	if s.saves <= s.conflicts {
+		return &order.ConcurrencyError{AggregateID: id, Expected: expectedVersion, Actual: expectedVersion + 1}
+	}
+	return s.EventStore.Save(ctx, aggregateType, id, expectedVersion, events)
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	store := &conflictingStore{EventStore: order.NewEventStore()}
+	repo := order.NewRepository(store)
+
+	if err := order.NewCommandHandler(repo).Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store.saves = 0
+	store.conflicts = 1
+
+	var retries []int
+	backoff := func(attempt int) time.Duration {
+		retries = append(retries, attempt)
+		return time.Millisecond
+	}
+
+	handler := order.Chain(order.NewCommandHandler(repo), order.RetryMiddleware(3, backoff))
+
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(retries, []int{1}) {
+		t.Errorf("expected: %v, got: %v", []int{1}, retries)
+	}
+	if store.saves != 2 {
+		t.Errorf("expected: %v, got: %v", 2, store.saves)
+	}
+}
+
+func TestRetryMiddlewareExhausted(t *testing.T) {
+	var attempts int
+	inner := order.CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+		attempts++
+		return &order.ConcurrencyError{AggregateID: "ABC123", Expected: attempts, Actual: attempts + 1}
+	})
+
+	handler := order.Chain(inner, order.RetryMiddleware(3, func(int) time.Duration { return 0 }))
+
+	err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"})
+
+	var conflict *order.ConcurrencyError
+	if !errors.As(err, &conflict) || conflict.Expected != 3 {
+		t.Errorf("expected the last conflict, got: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected: %v, got: %v", 3, attempts)
+	}
+}
+
+func TestRetryMiddlewareCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var attempts int
+	inner := order.CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+		attempts++
+		cancel()
+		return order.ErrConcurrencyConflict
+	})
+
+	handler := order.Chain(inner, order.RetryMiddleware(3, func(int) time.Duration { return time.Hour }))
+
+	if err := handler.Handle(ctx, order.Activate{OrderID: "ABC123"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected: %v, got: %v", context.Canceled, err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected: %v, got: %v", 1, attempts)
+	}
+}
+
+func TestRetryMiddlewareAlreadyPlaced(t *testing.T) {
+	repo := order.NewRepository(order.NewEventStore())
+
+	var retries int
+	handler := order.Chain(order.NewCommandHandler(repo), order.RetryMiddleware(3, func(int) time.Duration {
+		retries++
+		return 0
+	}))
+
+	place := order.Place{OrderID: "ABC123", Lines: testLines}
+	if err := handler.Handle(context.Background(), place); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := handler.Handle(context.Background(), place); !errors.Is(err, order.ErrAlreadyPlaced) {
+		t.Errorf("expected: %v, got: %v", order.ErrAlreadyPlaced, err)
+	}
+	if retries != 0 {
+		t.Errorf("expected: %v, got: %v", 0, retries)
+	}
+}
+
+// slowStore delays saves until the delay has passed or the context is done.
+type slowStore struct {
+	order.EventStore
+	delay time.Duration
+}
+
+func (s slowStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []order.PersistedEvent) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(s.delay):
+	}
+	return s.EventStore.Save(ctx, aggregateType, id, expectedVersion, events)
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	repo := order.NewRepository(slowStore{EventStore: order.NewEventStore(), delay: time.Second})
+	handler := order.Chain(order.NewCommandHandler(repo), order.TimeoutMiddleware(10*time.Millisecond))
+
+	err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines})
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestTimeoutMiddlewareInTime(t *testing.T) {
+	repo := order.NewRepository(slowStore{EventStore: order.NewEventStore(), delay: time.Millisecond})
+	handler := order.Chain(order.NewCommandHandler(repo), order.TimeoutMiddleware(time.Second))
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := order.ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+
+	for attempt, want := range map[int]time.Duration{
+		1: 10 * time.Millisecond,
+		2: 20 * time.Millisecond,
+		3: 40 * time.Millisecond,
+		4: 50 * time.Millisecond,
+		9: 50 * time.Millisecond,
+	} {
+		if got := backoff(attempt); got != want {
+			t.Errorf("attempt %d: expected: %v, got: %v", attempt, want, got)
+		}
+	}
+}
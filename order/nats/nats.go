@@ -0,0 +1,167 @@
+// Package nats provides a NATS JetStream subscriber feeding committed events
+// into projections.
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// message is the JSON encoding of a persisted event in a NATS message.
+type message struct {
+	AggregateType  string          `json:"aggregate_type"`
+	AggregateID    string          `json:"aggregate_id"`
+	Sequence       int             `json:"sequence"`
+	GlobalPosition int64           `json:"global_position"`
+	Type           string          `json:"type"`
+	Payload        json.RawMessage `json:"payload"`
+	OccurredAt     time.Time       `json:"occurred_at"`
+	Metadata       order.Metadata  `json:"metadata"`
+	SchemaVersion  int             `json:"schema_version"`
+}
+
+// Encode returns the JSON encoding of the event expected by the subscriber.
+func Encode(e order.PersistedEvent) ([]byte, error) {
+	payload, name, err := order.MarshalEvent(e.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(message{
+		AggregateType:  e.AggregateType,
+		AggregateID:    e.AggregateID,
+		Sequence:       e.Sequence,
+		GlobalPosition: e.GlobalPosition,
+		Type:           name,
+		Payload:        payload,
+		OccurredAt:     e.OccurredAt,
+		Metadata:       e.Metadata,
+		SchemaVersion:  e.SchemaVersion,
+	})
+}
+
+// Decode returns the event in the JSON encoding returned by Encode.
+func Decode(data []byte) (order.PersistedEvent, error) {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return order.PersistedEvent{}, err
+	}
+
+	// Messages without a schema version were encoded before versions were
+	// recorded.
+	if msg.SchemaVersion == 0 {
+		msg.SchemaVersion = 1
+	}
+
+	e, err := order.UnmarshalEventVersion(msg.Type, msg.SchemaVersion, msg.Payload)
+	if err != nil {
+		return order.PersistedEvent{}, err
+	}
+
+	// The event has been upcast to the current version of its type.
+	version, err := order.DefaultRegistry.SchemaVersion(e)
+	if err != nil {
+		return order.PersistedEvent{}, err
+	}
+
+	return order.PersistedEvent{
+		Event:          e,
+		Sequence:       msg.Sequence,
+		GlobalPosition: msg.GlobalPosition,
+		AggregateType:  msg.AggregateType,
+		AggregateID:    msg.AggregateID,
+		OccurredAt:     msg.OccurredAt,
+		Metadata:       msg.Metadata,
+		SchemaVersion:  version,
+	}, nil
+}
+
+// Subscriber applies the events consumed from a JetStream consumer to a
+// projection. Messages are acked once the event has been applied, and are
+// redelivered if they can't be decoded.
+//
+// The consumer should be durable with explicit acks, so that the subscriber
+// resumes after the last acked message after a restart. Since an ack may be
+// lost, the subscriber keeps track of the stream sequence of the last applied
+// message and acks redeliveries of it without applying them again.
+type Subscriber struct {
+	consumer   jetstream.Consumer
+	projection order.Projection
+
+	mu       sync.Mutex
+	sequence uint64
+}
+
+// Run consumes messages until the context is cancelled.
+func (s *Subscriber) Run(ctx context.Context) error {
+	iter, err := s.consumer.Messages()
+	if err != nil {
+		return err
+	}
+	defer iter.Stop()
+
+	stop := context.AfterFunc(ctx, iter.Stop)
+	defer stop()
+
+	for {
+		msg, err := iter.Next()
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Sequence returns the stream sequence of the last applied message.
+func (s *Subscriber) Sequence() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sequence
+}
+
+func (s *Subscriber) handle(msg jetstream.Msg) error {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if meta.Sequence.Stream <= s.sequence {
+		return msg.Ack()
+	}
+
+	e, err := Decode(msg.Data())
+	if err != nil {
+		return msg.Nak()
+	}
+
+	s.projection.Apply(e)
+	s.sequence = meta.Sequence.Stream
+
+	// A failed ack causes a redelivery, which is skipped above.
+	msg.Ack()
+
+	return nil
+}
+
+// NewSubscriber returns a subscriber applying the events of the consumer to
+// the projection.
+func NewSubscriber(consumer jetstream.Consumer, projection order.Projection) *Subscriber {
+	return &Subscriber{consumer: consumer, projection: projection}
+}
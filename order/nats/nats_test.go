@@ -0,0 +1,126 @@
+//go:build nats
+
+package nats_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/marcusolsson/cqrs-example/order"
+	"github.com/marcusolsson/cqrs-example/order/nats"
+)
+
+func runServer(t *testing.T) *server.Server {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	go srv.Start()
+	t.Cleanup(srv.Shutdown)
+
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("server not ready")
+	}
+
+	return srv
+}
+
+func TestSubscriber(t *testing.T) {
+	srv := runServer(t)
+
+	nc, err := natsgo.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer nc.Close()
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     "ORDERS",
+		Subjects: []string{"orders.>"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	consumer, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:   "summary",
+		AckPolicy: jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, e := range []order.PersistedEvent{
+		{Event: order.Placed{OrderID: "ABC123", Lines: testLines}, AggregateID: "ABC123", Sequence: 1},
+		{Event: order.Activated{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 2},
+	} {
+		data, err := nats.Encode(e)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := js.Publish(ctx, "orders.ABC123", data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	projection := order.NewSummaryProjection()
+	sub := nats.NewSubscriber(consumer, projection)
+
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- sub.Run(runCtx) }()
+
+	for sub.Sequence() < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for events, applied: %v", sub.Sequence())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	stop()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected: %v, got: %v", context.Canceled, err)
+	}
+
+	summary, ok := projection.Get("ABC123")
+	if !ok {
+		t.Fatal("expected order to be projected")
+	}
+	if summary.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, summary.Status)
+	}
+
+	info, err := consumer.Info(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.AckFloor.Stream != 2 || info.NumAckPending != 0 {
+		t.Errorf("expected both events acked, got ack floor %v with %v pending", info.AckFloor.Stream, info.NumAckPending)
+	}
+}
+
+var testLines = []order.Line{
+	{ProductID: "P1", Quantity: 1, UnitPrice: 100},
+}
@@ -1,14 +1,74 @@
 package order
 
 import (
+	"context"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 var (
-	errAlreadyPlaced  = errors.New("order has already been placed")
-	errEmptyOrderLine = errors.New("empty order line")
-	errOrderNotFound  = errors.New("order was not found")
+	// ErrOrderNotFound is returned when no events exist for an order.
+	ErrOrderNotFound = errors.New("order was not found")
+
+	// ErrConcurrencyConflict is returned when events are saved against a
+	// version of an order that is no longer the latest. Event stores return a
+	// *ConcurrencyError matching it.
+	ErrConcurrencyConflict = errors.New("concurrency conflict")
+
+	// ErrOrderAlreadyCancelled is returned when cancelling an order that has
+	// already been cancelled.
+	ErrOrderAlreadyCancelled = errors.New("order has already been cancelled")
+
+	// ErrInvalidOrderLine is returned when placing an order with an invalid
+	// order line. The returned error is a *LineError.
+	ErrInvalidOrderLine = errors.New("invalid order line")
+
+	// ErrLineNotFound is returned when removing, or changing the quantity
+	// of, a product that is not part of the order.
+	ErrLineNotFound = errors.New("order line was not found")
+
+	// ErrOrderNotAmendable is returned when changing the order lines of an
+	// order that is no longer placed.
+	ErrOrderNotAmendable = errors.New("order can no longer be amended")
+
+	// ErrUnhandledEvent is returned when applying an event to an order for
+	// which no applier has been registered.
+	ErrUnhandledEvent = errors.New("unhandled event")
+
+	// ErrUnknownCommand is returned when a command handler receives a
+	// command it does not know how to handle.
+	ErrUnknownCommand = errors.New("unknown command")
+
+	// ErrAlreadyPlaced is returned when placing an order that has already
+	// been placed.
+	ErrAlreadyPlaced = errors.New("order has already been placed")
+
+	// ErrEmptyOrderLine is returned when placing an order without any order
+	// lines.
+	ErrEmptyOrderLine = errors.New("empty order line")
+
+	// ErrMissingOrderID is returned when placing an order without an ID.
+	ErrMissingOrderID = errors.New("missing order id")
+
+	// ErrDiscountAlreadyApplied is returned when applying a discount code
+	// that has already been applied to the order.
+	ErrDiscountAlreadyApplied = errors.New("discount has already been applied")
+
+	// ErrInvalidDiscount is returned when applying a discount without a code
+	// or with a non-positive amount.
+	ErrInvalidDiscount = errors.New("invalid discount")
+
+	// ErrCurrencyMismatch is returned when an order line is priced in a
+	// different currency than the order.
+	ErrCurrencyMismatch = errors.New("currency mismatch")
 )
 
 // Status represents the order status.
@@ -18,36 +78,287 @@ type Status int
 const (
 	StatusPlaced Status = iota
 	StatusActivated
+	StatusCancelled
+	StatusShipped
+	StatusDelivered
+	StatusHeld
+	StatusExpired
 )
 
+var statusNames = map[Status]string{
+	StatusPlaced:    "placed",
+	StatusActivated: "activated",
+	StatusCancelled: "cancelled",
+	StatusShipped:   "shipped",
+	StatusDelivered: "delivered",
+	StatusHeld:      "held",
+	StatusExpired:   "expired",
+}
+
+func (s Status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
 // Order is the aggregate root.
 type Order struct {
-	ID     string
-	Status Status
+	AggregateRoot
+
+	CustomerID string
+	Status     Status
+	Lines      []Line
+
+	// Currency is the ISO 4217 code of the currency the lines are priced in,
+	// taken from the first line.
+	Currency string
+
+	// Discounts are the amounts in cents of the applied discounts, keyed by
+	// discount code.
+	Discounts map[string]int64
+
+	placed   bool
+	placedAt time.Time
+
+	// appliedSequence is the sequence number of the latest event applied to
+	// the order, guarding against applying a saved event twice.
+	appliedSequence int
+}
+
+// AggregateTypeOrder is the aggregate type under which orders are stored.
+const AggregateTypeOrder = "order"
+
+var _ Aggregate = (*Order)(nil)
+
+// NewOrder returns a new order with the given ID that has yet to be placed.
+func NewOrder(id OrderID) Order {
+	return Order{
+		AggregateRoot: AggregateRoot{ID: string(id)},
+	}
+}
+
+// OrderID returns the ID of the order. The ID is kept as a string by the
+// aggregate root, which is shared by all aggregates.
+func (o Order) OrderID() OrderID {
+	return OrderID(o.ID)
+}
 
-	uncommitted []Event
+// Clone returns a copy of the order that shares no lines or uncommitted
+// events with it, so that changes to one don't affect the other.
+func (o Order) Clone() Order {
+	if o.Lines != nil {
+		lines := make([]Line, len(o.Lines))
+		copy(lines, o.Lines)
+		o.Lines = lines
+	}
+
+	if o.Discounts != nil {
+		discounts := make(map[string]int64, len(o.Discounts))
+		for code, amount := range o.Discounts {
+			discounts[code] = amount
+		}
+		o.Discounts = discounts
+	}
+
+	if o.uncommitted != nil {
+		uncommitted := make([]PersistedEvent, len(o.uncommitted))
+		copy(uncommitted, o.uncommitted)
+		o.uncommitted = uncommitted
+	}
+
+	return o
 }
 
 // Place places the order by assigning order lines if not already placed.
 func (o *Order) Place(orderLines []Line) error {
-	if o.ID == "" {
-		return errAlreadyPlaced
+	return o.PlaceForCustomer("", orderLines)
+}
+
+// PlaceForCustomer places the order on behalf of the customer by assigning
+// order lines if not already placed.
+func (o *Order) PlaceForCustomer(customerID string, orderLines []Line) error {
+	if err := o.OrderID().Validate(); err != nil {
+		return err
+	}
+
+	if o.placed {
+		return ErrAlreadyPlaced
 	}
 
 	if len(orderLines) == 0 {
-		return errEmptyOrderLine
+		return ErrEmptyOrderLine
 	}
 
-	apply(o, Placed{OrderID: o.ID}, true)
+	currency := orderLines[0].Currency
 
-	return nil
+	for _, l := range orderLines {
+		if err := l.validate(); err != nil {
+			return err
+		}
+		if err := checkCurrency(currency, l); err != nil {
+			return err
+		}
+	}
+
+	return apply(o, Placed{OrderID: o.OrderID(), CustomerID: customerID, Lines: orderLines, Currency: currency}, true)
+}
+
+// Activate activates a placed order. Activating an order that is already
+// activated has no effect, while an order on hold is activated again with
+// Reactivate.
+func (o *Order) Activate() error {
+	if o.Status == StatusActivated {
+		return nil
+	}
+
+	if o.Status == StatusHeld {
+		return &TransitionError{From: o.Status, To: StatusActivated}
+	}
+
+	if err := transition(o, StatusActivated); err != nil {
+		return err
+	}
+
+	return apply(o, Activated{OrderID: o.OrderID()}, true)
+}
+
+// Cancel cancels the order unless it has already been cancelled.
+func (o *Order) Cancel() error {
+	if o.Status == StatusCancelled {
+		return ErrOrderAlreadyCancelled
+	}
+
+	if err := transition(o, StatusCancelled); err != nil {
+		return err
+	}
+
+	return apply(o, Cancelled{OrderID: o.OrderID()}, true)
+}
+
+// Ship ships an activated order.
+func (o *Order) Ship() error {
+	if err := transition(o, StatusShipped); err != nil {
+		return err
+	}
+
+	return apply(o, Shipped{OrderID: o.OrderID()}, true)
+}
+
+// Deliver delivers a shipped order.
+func (o *Order) Deliver() error {
+	if err := transition(o, StatusDelivered); err != nil {
+		return err
+	}
+
+	return apply(o, Delivered{OrderID: o.OrderID()}, true)
+}
+
+// Hold puts an activated order on hold.
+func (o *Order) Hold() error {
+	if err := transition(o, StatusHeld); err != nil {
+		return err
+	}
+
+	return apply(o, Held{OrderID: o.OrderID()}, true)
+}
+
+// Reactivate activates an order that has been put on hold.
+func (o *Order) Reactivate() error {
+	if o.Status != StatusHeld {
+		return &TransitionError{From: o.Status, To: StatusActivated}
+	}
+
+	return apply(o, Reactivated{OrderID: o.OrderID()}, true)
+}
+
+// Expire expires a placed order that hasn't been activated in time.
+func (o *Order) Expire() error {
+	if err := transition(o, StatusExpired); err != nil {
+		return err
+	}
+
+	return apply(o, Expired{OrderID: o.OrderID()}, true)
+}
+
+// PlacedAt returns the time the order was placed, which schedulers may use to
+// decide when to expire it. It is the zero time for orders that haven't been
+// placed, or that were only rebuilt from events without their metadata.
+func (o *Order) PlacedAt() time.Time {
+	return o.placedAt
+}
+
+// AddLine adds an order line to a placed order.
+func (o *Order) AddLine(l Line) error {
+	if o.Status != StatusPlaced {
+		return ErrOrderNotAmendable
+	}
+
+	if err := l.validate(); err != nil {
+		return err
+	}
+
+	if err := checkCurrency(o.Currency, l); err != nil {
+		return err
+	}
+
+	return apply(o, LineAdded{OrderID: o.OrderID(), Line: l}, true)
+}
+
+// RemoveLine removes the order lines for a product from a placed order.
+func (o *Order) RemoveLine(productID string) error {
+	if o.Status != StatusPlaced {
+		return ErrOrderNotAmendable
+	}
+
+	if !hasProduct(o.Lines, productID) {
+		return ErrLineNotFound
+	}
+
+	return apply(o, LineRemoved{OrderID: o.OrderID(), ProductID: productID}, true)
+}
+
+// ChangeLineQuantity changes the quantity of the product in a placed order.
+// Several lines for the product are merged into a single line with the new
+// quantity.
+func (o *Order) ChangeLineQuantity(productID string, quantity int) error {
+	if o.Status != StatusPlaced {
+		return ErrOrderNotAmendable
+	}
+
+	if quantity <= 0 {
+		return &LineError{Field: "Quantity", Reason: "must be positive"}
+	}
+
+	if !hasProduct(o.Lines, productID) {
+		return ErrLineNotFound
+	}
+
+	return apply(o, LineQuantityChanged{OrderID: o.OrderID(), ProductID: productID, Quantity: quantity}, true)
 }
 
-// Activate activates the order.
-func (o *Order) Activate() {
-	if o.Status == StatusPlaced {
-		apply(o, Activated{OrderID: o.ID}, true)
+// ApplyDiscount applies a discount of the given amount in cents to a placed
+// order. Each discount code can only be applied once.
+func (o *Order) ApplyDiscount(code string, amount int64) error {
+	if o.Status != StatusPlaced {
+		return ErrOrderNotAmendable
+	}
+
+	if code == "" || amount <= 0 {
+		return ErrInvalidDiscount
+	}
+
+	if _, ok := o.Discounts[code]; ok {
+		return fmt.Errorf("%w: %s", ErrDiscountAlreadyApplied, code)
 	}
+
+	return apply(o, DiscountApplied{OrderID: o.OrderID(), Code: code, AmountCents: amount}, true)
+}
+
+// Total returns the sum of the order lines in cents less the applied
+// discounts, but never less than zero, along with the currency of the order.
+func (o *Order) Total() (int64, string) {
+	return discountedTotal(o.Lines, o.Discounts), o.Currency
 }
 
 // Event is the interface for all domain events.
@@ -57,166 +368,1425 @@ type Event interface {
 
 // Placed represents the event when an order was placed.
 type Placed struct {
-	OrderID string
+	OrderID    OrderID
+	CustomerID string
+	Lines      []Line
+
+	// Currency is the currency of the order. It is empty for orders placed
+	// before currencies were recorded, in which case it is taken from the
+	// first line.
+	Currency string `json:",omitempty"`
 }
 
 // ID returns the identifier of the aggregate root, i.e. the order.
 func (e Placed) ID() string {
-	return e.OrderID
+	return string(e.OrderID)
 }
 
 // Activated represents the event when an order was activated.
 type Activated struct {
-	OrderID string
+	OrderID OrderID
 }
 
 // ID returns the identifier of the order (aggregate root).
 func (e Activated) ID() string {
-	return e.OrderID
+	return string(e.OrderID)
 }
 
-// Line represents an order line.
-type Line struct {
+// Cancelled represents the event when an order was cancelled.
+type Cancelled struct {
+	OrderID OrderID
 }
 
-// Place represents a command for placing an order.
-type Place struct {
-	OrderID string
-	Lines   []Line
+// ID returns the identifier of the order (aggregate root).
+func (e Cancelled) ID() string {
+	return string(e.OrderID)
 }
 
-// Activate represents a command for activating an order.
-type Activate struct {
-	OrderID string
+// Shipped represents the event when an order was shipped.
+type Shipped struct {
+	OrderID OrderID
 }
 
-// loadFromHistory builds a order from a series of events.
-func loadFromHistory(events []Event) Order {
-	var o Order
-	for _, e := range events {
-		apply(&o, e, false)
-	}
-	return o
+// ID returns the identifier of the order (aggregate root).
+func (e Shipped) ID() string {
+	return string(e.OrderID)
 }
 
-// apply updates meta data of the order and stores the new event after it has been handled.
-func apply(o *Order, e Event, isNew bool) {
-	o.ID = e.ID()
+// Held represents the event when an order was put on hold.
+type Held struct {
+	OrderID OrderID
+}
 
-	handle(o, e)
+// ID returns the identifier of the order (aggregate root).
+func (e Held) ID() string {
+	return string(e.OrderID)
+}
 
-	if isNew {
-		o.uncommitted = append(o.uncommitted, e)
+// Expired represents the event when a placed order expired without being
+// activated.
+type Expired struct {
+	OrderID OrderID
+}
+
+// ID returns the identifier of the order (aggregate root).
+func (e Expired) ID() string {
+	return string(e.OrderID)
+}
+
+// Reactivated represents the event when an order on hold was activated again.
+type Reactivated struct {
+	OrderID OrderID
+}
+
+// ID returns the identifier of the order (aggregate root).
+func (e Reactivated) ID() string {
+	return string(e.OrderID)
+}
+
+// Delivered represents the event when an order was delivered.
+type Delivered struct {
+	OrderID OrderID
+}
+
+// ID returns the identifier of the order (aggregate root).
+func (e Delivered) ID() string {
+	return string(e.OrderID)
+}
+
+// LineAdded represents the event when an order line was added to an order.
+type LineAdded struct {
+	OrderID OrderID
+	Line    Line
+}
+
+// ID returns the identifier of the order (aggregate root).
+func (e LineAdded) ID() string {
+	return string(e.OrderID)
+}
+
+// LineRemoved represents the event when the order lines for a product were
+// removed from an order.
+type LineRemoved struct {
+	OrderID   OrderID
+	ProductID string
+}
+
+// ID returns the identifier of the order (aggregate root).
+func (e LineRemoved) ID() string {
+	return string(e.OrderID)
+}
+
+// LineQuantityChanged represents the event when the quantity of a product in
+// an order was changed.
+type LineQuantityChanged struct {
+	OrderID   OrderID
+	ProductID string
+	Quantity  int
+}
+
+// ID returns the identifier of the order (aggregate root).
+func (e LineQuantityChanged) ID() string {
+	return string(e.OrderID)
+}
+
+// DiscountApplied represents the event when a discount was applied to an
+// order.
+type DiscountApplied struct {
+	OrderID     OrderID
+	Code        string
+	AmountCents int64
+}
+
+// ID returns the identifier of the order (aggregate root).
+func (e DiscountApplied) ID() string {
+	return string(e.OrderID)
+}
+
+// Line represents an order line.
+type Line struct {
+	ProductID string
+	Quantity  int
+	UnitPrice int64  // in cents
+	Currency  string `json:",omitempty"` // ISO 4217 code, e.g. EUR
+}
+
+// validate returns an error naming the first invalid field of the line.
+func (l Line) validate() error {
+	switch {
+	case l.ProductID == "":
+		return &LineError{Field: "ProductID", Reason: "must not be empty"}
+	case l.Quantity <= 0:
+		return &LineError{Field: "Quantity", Reason: "must be positive"}
+	case l.UnitPrice < 0:
+		return &LineError{Field: "UnitPrice", Reason: "must not be negative"}
+	case l.Currency != "" && !isCurrencyCode(l.Currency):
+		return &LineError{Field: "Currency", Reason: "must be an ISO 4217 code"}
 	}
+	return nil
 }
 
-// handle updates the state of the order for every events.
-func handle(o *Order, e Event) {
-	switch e.(type) {
-	case Activated:
-		o.Status = StatusActivated
-	case Placed:
-		o.Status = StatusPlaced
+// isCurrencyCode reports whether s has the form of an ISO 4217 code, i.e.
+// three upper-case letters.
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
 	}
+	return true
 }
 
-// EventStore defines the operations of a event store.
-type EventStore interface {
-	Save(id string, events []Event)
-	Load(id string) ([]Event, error)
+// checkCurrency returns an error unless the line is priced in the currency.
+func checkCurrency(currency string, l Line) error {
+	if l.Currency != currency {
+		return fmt.Errorf("%w: %s line in %s order", ErrCurrencyMismatch, l.Currency, currency)
+	}
+	return nil
 }
 
-type eventStore struct {
-	events []Event
+// total returns the sum of the lines in cents.
+func total(lines []Line) int64 {
+	var sum int64
+	for _, l := range lines {
+		sum += int64(l.Quantity) * l.UnitPrice
+	}
+	return sum
 }
 
-func (s *eventStore) Save(id string, events []Event) {
-	s.events = append(s.events, events...)
+// discountedTotal returns the sum of the lines in cents less the discounts,
+// but never less than zero.
+func discountedTotal(lines []Line, discounts map[string]int64) int64 {
+	sum := total(lines)
+	for _, amount := range discounts {
+		sum -= amount
+	}
+	if sum < 0 {
+		return 0
+	}
+	return sum
 }
 
-func (s *eventStore) Load(id string) ([]Event, error) {
-	var result []Event
-	for _, e := range s.events {
-		if e.ID() == id {
-			result = append(result, e)
+// hasProduct reports whether any of the lines are for the product.
+func hasProduct(lines []Line, productID string) bool {
+	for _, l := range lines {
+		if l.ProductID == productID {
+			return true
 		}
 	}
+	return false
+}
 
-	if len(result) == 0 {
-		return nil, errOrderNotFound
+// removeProduct returns the lines that are not for the product.
+func removeProduct(lines []Line, productID string) []Line {
+	var result []Line
+	for _, l := range lines {
+		if l.ProductID != productID {
+			result = append(result, l)
+		}
 	}
+	return result
+}
 
-	return result, nil
+// changeQuantity returns the lines with the lines for the product replaced by
+// a single line with the given quantity, in place of the first of them.
+func changeQuantity(lines []Line, productID string, quantity int) []Line {
+	var (
+		result  []Line
+		changed bool
+	)
+	for _, l := range lines {
+		if l.ProductID != productID {
+			result = append(result, l)
+			continue
+		}
+		if !changed {
+			l.Quantity = quantity
+			result = append(result, l)
+			changed = true
+		}
+	}
+	return result
 }
 
-// NewEventStore returns a new instance of the default event store.
-func NewEventStore() EventStore {
-	return &eventStore{}
+// LineError describes an invalid field of an order line.
+type LineError struct {
+	Field  string
+	Reason string
 }
 
-// Repository ...
-type Repository interface {
-	Save(Order)
-	Load(string) Order
+func (e *LineError) Error() string {
+	return fmt.Sprintf("%v: %s %s", ErrInvalidOrderLine, e.Field, e.Reason)
 }
 
-type defaultRepository struct {
-	Store EventStore
+// Is reports whether the target is ErrInvalidOrderLine.
+func (e *LineError) Is(target error) bool {
+	return target == ErrInvalidOrderLine
 }
 
-// Save ...
-func (r *defaultRepository) Save(order Order) {
-	if len(order.uncommitted) > 0 {
-		r.Store.Save(order.ID, order.uncommitted)
-	}
+// Place represents a command for placing an order.
+type Place struct {
+	OrderID    OrderID
+	CustomerID string
+	Lines      []Line
 }
 
-// Load ...
-func (r *defaultRepository) Load(id string) Order {
-	events, err := r.Store.Load(id)
-	if err != nil {
-		return Order{}
-	}
+// Activate represents a command for activating an order.
+type Activate struct {
+	OrderID OrderID
+}
 
-	return loadFromHistory(events)
+// Cancel represents a command for cancelling an order.
+type Cancel struct {
+	OrderID OrderID
 }
 
-// NewRepository returns a new instance of the default repository.
-func NewRepository(store EventStore) Repository {
-	return &defaultRepository{
-		Store: store,
-	}
+// AddLine represents a command for adding an order line to an order.
+type AddLine struct {
+	OrderID OrderID
+	Line    Line
 }
 
-// CommandHandler defines an interface for handling order commands.
-type CommandHandler interface {
-	Handle(c interface{})
+// RemoveLine represents a command for removing the order lines for a product
+// from an order.
+type RemoveLine struct {
+	OrderID   OrderID
+	ProductID string
 }
 
-type commandHandler struct {
-	Repository Repository
+// ChangeLineQuantity represents a command for changing the quantity of a
+// product in an order.
+type ChangeLineQuantity struct {
+	OrderID     OrderID
+	ProductID   string
+	NewQuantity int
 }
 
-func (h *commandHandler) Handle(c interface{}) {
-	switch cmd := c.(type) {
-	case Place:
-		order := Order{
-			ID: cmd.OrderID,
+// ApplyDiscount represents a command for applying a discount to an order.
+type ApplyDiscount struct {
+	OrderID     OrderID
+	Code        string
+	AmountCents int64
+}
+
+// Ship represents a command for shipping an order.
+type Ship struct {
+	OrderID OrderID
+}
+
+// Hold represents a command for putting an order on hold.
+type Hold struct {
+	OrderID OrderID
+}
+
+// Reactivate represents a command for activating an order on hold.
+type Reactivate struct {
+	OrderID OrderID
+}
+
+// Deliver represents a command for delivering an order.
+type Deliver struct {
+	OrderID OrderID
+}
+
+// Expire represents a command for expiring an order that hasn't been
+// activated.
+type Expire struct {
+	OrderID OrderID
+}
+
+// Apply updates the state of the order from a previously saved event.
+func (o *Order) Apply(e Event) error {
+	return apply(o, e, false)
+}
+
+// loadFromHistory builds a order from a series of events. The events are
+// applied as previously saved, so the order has no uncommitted events. The
+// lines are allocated up front, since the number of lines can't exceed the
+// number of lines added by the events.
+func loadFromHistory(events []PersistedEvent) (Order, error) {
+	var o Order
+	if n := addedLines(events); n > 0 {
+		o.Lines = make([]Line, 0, n)
+	}
+
+	for _, e := range events {
+		if err := applyPersisted(&o, e); err != nil {
+			return Order{}, err
 		}
-		if err := order.Place(cmd.Lines); err != nil {
-			log.Println(err)
+	}
+	return o, nil
+}
+
+// addedLines returns the number of lines added to the order by the events.
+func addedLines(events []PersistedEvent) int {
+	var n int
+	for _, e := range events {
+		switch evt := e.Event.(type) {
+		case Placed:
+			n += len(evt.Lines)
+		case LineAdded:
+			n++
 		}
-		h.Repository.Save(order)
-	case Activate:
-		order := h.Repository.Load(cmd.OrderID)
-		order.Activate()
-		h.Repository.Save(order)
 	}
+	return n
 }
 
-// NewCommandHandler returns a new instance of the default command handler.
-func NewCommandHandler(r Repository) CommandHandler {
-	return &commandHandler{
-		Repository: r,
+// apply updates meta data of the order and stores the new event after it has been handled.
+func apply(o *Order, e Event, isNew bool) error {
+	fn, ok := appliers[reflect.TypeOf(e)]
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrUnhandledEvent, e)
+	}
+
+	fn(o, e)
+
+	o.record(e, isNew)
+	o.appliedSequence++
+
+	if isNew {
+		latest := &o.uncommitted[len(o.uncommitted)-1]
+		latest.Sequence = o.appliedSequence
+
+		if _, ok := e.(Placed); ok {
+			o.placedAt = latest.OccurredAt.UTC().Round(0)
+		}
+	}
+
+	return nil
+}
+
+// applyPersisted applies a previously saved event to the order, along with
+// the metadata the order keeps track of. Times are kept in UTC without a
+// monotonic clock reading, as the event stores persist them.
+//
+// Events with a sequence number that isn't after that of the latest applied
+// event have already been applied, for example when an event is delivered
+// twice, and are ignored.
+func applyPersisted(o *Order, e PersistedEvent) error {
+	if e.Sequence > 0 && e.Sequence <= o.appliedSequence {
+		return nil
+	}
+
+	if err := apply(o, e.Event, false); err != nil {
+		return err
 	}
+
+	if e.Sequence > 0 {
+		o.appliedSequence = e.Sequence
+	}
+
+	if _, ok := e.Event.(Placed); ok {
+		o.placedAt = e.OccurredAt.UTC().Round(0)
+	}
+
+	return nil
+}
+
+// appliers holds the functions updating the state of the order for each type
+// of event. Adding an event to the order requires registering an applier.
+var appliers = map[reflect.Type]func(*Order, Event){
+	reflect.TypeOf(Placed{}): func(o *Order, e Event) {
+		evt := e.(Placed)
+		o.Status = StatusPlaced
+		o.CustomerID = evt.CustomerID
+		o.Lines = append(o.Lines[:0], evt.Lines...)
+		o.Currency = evt.Currency
+		if o.Currency == "" && len(evt.Lines) > 0 {
+			o.Currency = evt.Lines[0].Currency
+		}
+		o.placed = true
+	},
+	reflect.TypeOf(Activated{}): func(o *Order, e Event) {
+		o.Status = StatusActivated
+	},
+	reflect.TypeOf(LineAdded{}): func(o *Order, e Event) {
+		o.Lines = append(o.Lines, e.(LineAdded).Line)
+	},
+	reflect.TypeOf(LineRemoved{}): func(o *Order, e Event) {
+		o.Lines = removeProduct(o.Lines, e.(LineRemoved).ProductID)
+	},
+	reflect.TypeOf(LineQuantityChanged{}): func(o *Order, e Event) {
+		evt := e.(LineQuantityChanged)
+		o.Lines = changeQuantity(o.Lines, evt.ProductID, evt.Quantity)
+	},
+	reflect.TypeOf(DiscountApplied{}): func(o *Order, e Event) {
+		evt := e.(DiscountApplied)
+		if o.Discounts == nil {
+			o.Discounts = make(map[string]int64)
+		}
+		o.Discounts[evt.Code] = evt.AmountCents
+	},
+	reflect.TypeOf(Cancelled{}): func(o *Order, e Event) {
+		o.Status = StatusCancelled
+	},
+	reflect.TypeOf(Shipped{}): func(o *Order, e Event) {
+		o.Status = StatusShipped
+	},
+	reflect.TypeOf(Delivered{}): func(o *Order, e Event) {
+		o.Status = StatusDelivered
+	},
+	reflect.TypeOf(Held{}): func(o *Order, e Event) {
+		o.Status = StatusHeld
+	},
+	reflect.TypeOf(Expired{}): func(o *Order, e Event) {
+		o.Status = StatusExpired
+	},
+	reflect.TypeOf(Reactivated{}): func(o *Order, e Event) {
+		o.Status = StatusActivated
+	},
+}
+
+// PersistedEvent is an event along with its metadata. OccurredAt and
+// SchemaVersion are recorded when the event is applied, while Sequence and
+// GlobalPosition are assigned by the event store when it was saved. Sequence
+// orders the events of a single aggregate, and GlobalPosition orders the
+// events across all aggregates.
+type PersistedEvent struct {
+	Event Event
+
+	// EventID uniquely identifies the event. It is assigned when the event
+	// is applied, as a version 7 UUID, so that event IDs sort by the time
+	// the events occurred. Saving an event whose ID has already been saved
+	// has no effect, making it safe to retry a save.
+	EventID string
+
+	Sequence       int
+	GlobalPosition int64
+	AggregateType  string
+	AggregateID    string
+	OccurredAt     time.Time
+	Metadata       Metadata
+
+	// SchemaVersion is the version of the encoding of the event, as
+	// registered for its type.
+	SchemaVersion int
+}
+
+// EventStore defines the operations of a event store. The events of an
+// aggregate are identified by both the type and the ID of the aggregate, so
+// that aggregates of different types may share IDs.
+type EventStore interface {
+	Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []PersistedEvent) error
+	Load(ctx context.Context, aggregateType, id string) ([]PersistedEvent, error)
+	LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) ([]PersistedEvent, error)
+	LoadPage(ctx context.Context, aggregateType, id string, afterSequence, limit int) (events []PersistedEvent, nextCursor int, err error)
+	LoadMany(ctx context.Context, aggregateType string, ids []string) (map[string][]PersistedEvent, error)
+	LoadAll(ctx context.Context) ([]PersistedEvent, error)
+	LoadAllFrom(ctx context.Context, position int64, limit int) ([]PersistedEvent, error)
+	Stream(ctx context.Context, aggregateType, id string) (<-chan PersistedEvent, <-chan error)
+}
+
+// ConcurrencyError describes a save against a version of an order that is no
+// longer the latest. Callers may reload the order and retry.
+type ConcurrencyError struct {
+	AggregateID string
+	Expected    int
+	Actual      int
+}
+
+func (e *ConcurrencyError) Error() string {
+	return fmt.Sprintf("%v: order %s is at version %d, expected %d", ErrConcurrencyConflict, e.AggregateID, e.Actual, e.Expected)
+}
+
+// Is reports whether the target is ErrConcurrencyConflict.
+func (e *ConcurrencyError) Is(target error) bool {
+	return target == ErrConcurrencyConflict
+}
+
+// aggregateKey identifies the events of an aggregate in an event store.
+type aggregateKey struct {
+	aggregateType string
+	id            string
+}
+
+type eventStore struct {
+	mu            sync.RWMutex
+	events        []PersistedEvent
+	eventIDs      map[string]bool
+	subscriptions []*Subscription
+	redactions    []Redaction
+}
+
+// Save appends the events to the store, provided that the number of events
+// already stored for the aggregate matches the expected version.
+func (s *eventStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []PersistedEvent) (err error) {
+	ctx, span := startSpan(ctx, "EventStore.Save", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+
+	saved, err := s.append(ctx, aggregateType, id, expectedVersion, events)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	subs := s.notify(saved)
+
+	s.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.drain()
+	}
+
+	return nil
+}
+
+// SaveAll appends the events of every aggregate, checking the expected
+// versions of all of them before appending any.
+func (s *eventStore) SaveAll(ctx context.Context, changes []AggregateChanges) (err error) {
+	ctx, span := startSpan(ctx, "EventStore.SaveAll", trace.WithAttributes(
+		attribute.Int("aggregate.count", len(changes)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+
+	versions := make(map[aggregateKey]int)
+	for _, e := range s.events {
+		versions[aggregateKey{e.AggregateType, e.AggregateID}]++
+	}
+
+	for _, c := range changes {
+		if unsaved, _ := s.unsaved(c.Events); len(c.Events) > 0 && len(unsaved) == 0 {
+			continue
+		}
+
+		key := aggregateKey{c.AggregateType, c.AggregateID}
+		if versions[key] != c.ExpectedVersion {
+			s.mu.Unlock()
+			return &ConcurrencyError{AggregateID: c.AggregateID, Expected: c.ExpectedVersion, Actual: versions[key]}
+		}
+		versions[key] += len(c.Events)
+	}
+
+	var saved []PersistedEvent
+	for _, c := range changes {
+		events, err := s.append(ctx, c.AggregateType, c.AggregateID, c.ExpectedVersion, c.Events)
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		saved = append(saved, events...)
+	}
+
+	subs := s.notify(saved)
+
+	s.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.drain()
+	}
+
+	return nil
+}
+
+// notify queues the saved events for every subscription and returns the
+// subscriptions, to be drained once the lock has been released so that
+// handlers may save to the store themselves. The caller must hold the write
+// lock, so that subscriptions receive the events in the order they were
+// saved.
+func (s *eventStore) notify(events []PersistedEvent) []*Subscription {
+	if len(events) == 0 {
+		return nil
+	}
+
+	subs := make([]*Subscription, len(s.subscriptions))
+	copy(subs, s.subscriptions)
+
+	for _, sub := range subs {
+		sub.enqueue(events)
+	}
+
+	return subs
+}
+
+// unsubscribe stops queueing events for the subscription.
+func (s *eventStore) unsubscribe(sub *Subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, other := range s.subscriptions {
+		if other == sub {
+			s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+			return
+		}
+	}
+}
+
+// append stores the events and returns them with their assigned sequence
+// numbers. The caller must hold the write lock.
+func (s *eventStore) append(ctx context.Context, aggregateType, id string, expectedVersion int, events []PersistedEvent) ([]PersistedEvent, error) {
+	// Events that have already been saved, e.g. by a save being retried,
+	// are left out. Saving only such events succeeds without changes.
+	if len(events) > 0 {
+		unsaved, skipped := s.unsaved(events)
+		recordSkipped(ctx, skipped)
+
+		if events = unsaved; len(events) == 0 {
+			return nil, nil
+		}
+	}
+
+	var version int
+	for _, e := range s.events {
+		if e.AggregateType == aggregateType && e.AggregateID == id {
+			version++
+		}
+	}
+
+	if version != expectedVersion {
+		return nil, &ConcurrencyError{AggregateID: id, Expected: expectedVersion, Actual: version}
+	}
+
+	saved := make([]PersistedEvent, len(events))
+	for i, e := range events {
+		version++
+		e.Sequence = version
+		e.GlobalPosition = int64(len(s.events) + 1)
+		e.AggregateType = aggregateType
+		e.AggregateID = id
+		e.SchemaVersion = schemaVersion(e)
+		s.events = append(s.events, e)
+		saved[i] = e
+
+		if e.EventID != "" {
+			if s.eventIDs == nil {
+				s.eventIDs = make(map[string]bool)
+			}
+			s.eventIDs[e.EventID] = true
+		}
+	}
+
+	return saved, nil
+}
+
+// unsaved splits the events into those that haven't been saved and those
+// that have, judging by their event IDs. Events without an ID are never
+// considered saved. The caller must hold the lock.
+func (s *eventStore) unsaved(events []PersistedEvent) (unsaved, saved []PersistedEvent) {
+	for _, e := range events {
+		if e.EventID == "" || !s.eventIDs[e.EventID] {
+			unsaved = append(unsaved, e)
+		} else {
+			saved = append(saved, e)
+		}
+	}
+	return unsaved, saved
+}
+
+// Load returns the events for the aggregate in sequence order.
+func (s *eventStore) Load(ctx context.Context, aggregateType, id string) ([]PersistedEvent, error) {
+	result, err := s.LoadFrom(ctx, aggregateType, id, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, ErrOrderNotFound
+	}
+
+	return result, nil
+}
+
+// LoadFrom returns the events for the aggregate with a sequence number greater
+// than afterSequence, in sequence order.
+func (s *eventStore) LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.Load", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+		attribute.Int("sequence.after", afterSequence),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []PersistedEvent{}
+	for _, e := range s.events {
+		if e.AggregateType == aggregateType && e.AggregateID == id && e.Sequence > afterSequence {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+// LoadPage returns up to limit events for the aggregate with a sequence number
+// greater than afterSequence, in sequence order, along with the cursor to pass
+// as afterSequence for the next page. The cursor is zero on the last page.
+func (s *eventStore) LoadPage(ctx context.Context, aggregateType, id string, afterSequence, limit int) (_ []PersistedEvent, _ int, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadPage", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+		attribute.Int("sequence.after", afterSequence),
+		attribute.Int("page.limit", limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []PersistedEvent{}
+	for _, e := range s.events {
+		if e.AggregateType != aggregateType || e.AggregateID != id || e.Sequence <= afterSequence {
+			continue
+		}
+		result = append(result, e)
+		if limit > 0 && len(result) > limit {
+			break
+		}
+	}
+
+	events, next := page(result, limit)
+
+	return events, next, nil
+}
+
+// LoadMany returns the events for each of the aggregates in sequence order,
+// keyed by aggregate ID. Aggregates without events are absent from the result.
+func (s *eventStore) LoadMany(ctx context.Context, aggregateType string, ids []string) (_ map[string][]PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadMany", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.Int("aggregate.count", len(ids)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string][]PersistedEvent)
+	for _, e := range s.events {
+		if e.AggregateType == aggregateType && wanted[e.AggregateID] {
+			result[e.AggregateID] = append(result[e.AggregateID], e)
+		}
+	}
+
+	return result, nil
+}
+
+// LoadAll returns the events of all orders in the order they were saved.
+func (s *eventStore) LoadAll(ctx context.Context) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadAll")
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]PersistedEvent, len(s.events))
+	copy(result, s.events)
+
+	return result, nil
+}
+
+// LoadAllFrom returns up to limit events of all orders with a global position
+// greater than the given position, in the order they were saved. A limit of
+// zero or less returns all of them.
+func (s *eventStore) LoadAllFrom(ctx context.Context, position int64, limit int) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadAllFrom", trace.WithAttributes(
+		attribute.Int64("position.after", position),
+		attribute.Int("page.limit", limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// The global position of an event is its index in the store plus one.
+	start := min(max(int(position), 0), len(s.events))
+	end := len(s.events)
+	if limit > 0 {
+		end = min(start+limit, end)
+	}
+
+	result := make([]PersistedEvent, end-start)
+	copy(result, s.events[start:end])
+
+	return result, nil
+}
+
+// Stream sends the events for the aggregate in sequence order on the returned
+// channel, which is closed once all of them have been sent, the context is
+// cancelled or an error occurred. The error, if any, is sent on the error
+// channel.
+func (s *eventStore) Stream(ctx context.Context, aggregateType, id string) (<-chan PersistedEvent, <-chan error) {
+	return stream(ctx, s, aggregateType, id)
+}
+
+// NewEventStore returns a new instance of the default in-memory event store.
+// The store is safe for concurrent use by multiple goroutines.
+func NewEventStore() EventStore {
+	return &eventStore{}
+}
+
+// Repository ...
+type Repository interface {
+	Save(context.Context, *Order) error
+	Load(context.Context, string) (Order, error)
+	LoadMany(context.Context, []string) (map[string]Order, error)
+}
+
+type defaultRepository struct {
+	Store          EventStore
+	Snapshots      SnapshotStore
+	SnapshotPolicy SnapshotPolicy
+	LoadThreshold  int
+	Bus            EventBus
+	Outbox         Outbox
+	Metrics        Metrics
+	Logger         *slog.Logger
+}
+
+// Save ...
+func (r *defaultRepository) Save(ctx context.Context, order *Order) error {
+	if len(order.uncommitted) == 0 {
+		return nil
+	}
+
+	expectedVersion := order.version - len(order.uncommitted)
+
+	// Events the store skips because they have already been saved, e.g. by
+	// a retried save, are left out so that they aren't published or
+	// enqueued twice.
+	saveCtx, skipped := withSkippedEvents(ctx)
+	if err := r.Store.Save(saveCtx, AggregateTypeOrder, order.ID, expectedVersion, order.uncommitted); err != nil {
+		return err
+	}
+
+	events := skipped.appended(committed(AggregateTypeOrder, order.ID, expectedVersion, order.uncommitted))
+
+	order.MarkCommitted()
+
+	recordCommitted(ctx, events)
+
+	r.Metrics.CountEvents(AggregateTypeOrder, len(events))
+
+	// The events are already committed, so failing to enqueue them must not
+	// fail the save; retrying the command would only repeat it.
+	if r.Outbox != nil {
+		if err := r.Outbox.Enqueue(ctx, events); err != nil {
+			r.Logger.ErrorContext(ctx, "enqueue committed events",
+				slog.String("aggregate_id", order.ID),
+				slog.Int("events", len(events)),
+				slog.Any("error", err),
+			)
+		}
+	}
+
+	// Likewise, a closed bus doesn't fail the save.
+	if r.Bus != nil {
+		if err := r.Bus.Publish(events); err != nil {
+			r.Logger.ErrorContext(ctx, "publish committed events",
+				slog.String("aggregate_id", order.ID),
+				slog.Int("events", len(events)),
+				slog.Any("error", err),
+			)
+		}
+	}
+
+	if r.SnapshotPolicy != nil {
+		return r.snapshot(order)
+	}
+
+	return nil
+}
+
+// snapshot saves a snapshot of the order if the snapshot policy asks for one
+// given the number of events saved since the latest snapshot.
+func (r *defaultRepository) snapshot(order *Order) error {
+	latest, _, err := r.Snapshots.LoadSnapshot(order.ID)
+	if err != nil && !errors.Is(err, ErrSnapshotNotFound) {
+		return err
+	}
+
+	if !r.SnapshotPolicy.ShouldSnapshot(order.version, order.version-latest) {
+		return nil
+	}
+
+	state, err := marshalSnapshot(*order)
+	if err != nil {
+		return err
+	}
+
+	return r.Snapshots.SaveSnapshot(order.ID, order.version, state)
+}
+
+// Load ...
+func (r *defaultRepository) Load(ctx context.Context, id string) (Order, error) {
+	order, version, err := r.loadSnapshot(id)
+	if err != nil {
+		return Order{}, err
+	}
+
+	var events []PersistedEvent
+	if version == 0 {
+		events, err = r.Store.Load(ctx, AggregateTypeOrder, id)
+	} else {
+		// Only the events saved after the snapshot need to be replayed.
+		events, err = r.Store.LoadFrom(ctx, AggregateTypeOrder, id, version)
+	}
+	if err != nil {
+		return Order{}, err
+	}
+
+	if version == 0 {
+		order, err = loadFromHistory(events)
+	} else {
+		order, err = replay(order, events, version)
+	}
+	if err != nil {
+		return Order{}, err
+	}
+
+	r.snapshotLoaded(order, version)
+
+	return order, nil
+}
+
+// snapshotLoaded saves a snapshot of a loaded order if more events than the
+// load threshold were replayed since the snapshot at the given version. The
+// snapshot is only an optimization of later loads, so failing to save it
+// doesn't fail the load.
+func (r *defaultRepository) snapshotLoaded(order Order, version int) {
+	if r.LoadThreshold <= 0 || order.version-version <= r.LoadThreshold {
+		return
+	}
+
+	state, err := marshalSnapshot(order)
+	if err != nil {
+		return
+	}
+
+	r.Snapshots.SaveSnapshot(order.ID, order.version, state)
+}
+
+// LoadMany loads the orders with the given IDs using a single call to the
+// event store. Orders that don't exist are absent from the result.
+func (r *defaultRepository) LoadMany(ctx context.Context, ids []string) (map[string]Order, error) {
+	histories, err := r.Store.LoadMany(ctx, AggregateTypeOrder, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Order, len(histories))
+	for id, events := range histories {
+		order, version, err := r.loadSnapshot(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if version == 0 {
+			order, err = loadFromHistory(events)
+		} else {
+			order, err = replay(order, events, version)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r.snapshotLoaded(order, version)
+
+		result[id] = order
+	}
+
+	return result, nil
+}
+
+// loadSnapshot returns the latest snapshot of the order along with its
+// version. The version is zero if there is no snapshot.
+func (r *defaultRepository) loadSnapshot(id string) (Order, int, error) {
+	if r.Snapshots == nil {
+		return Order{}, 0, nil
+	}
+
+	version, state, err := r.Snapshots.LoadSnapshot(id)
+	if errors.Is(err, ErrSnapshotNotFound) {
+		return Order{}, 0, nil
+	}
+	if err != nil {
+		return Order{}, 0, err
+	}
+
+	// Snapshots of an incompatible version are ignored, and the order is
+	// replayed from the beginning instead.
+	order, err := unmarshalSnapshot(state)
+	if errors.Is(err, ErrSnapshotVersionMismatch) {
+		return Order{}, 0, nil
+	}
+	if err != nil {
+		return Order{}, 0, err
+	}
+
+	return order, version, nil
+}
+
+// replay applies the events with a sequence number after the given version to
+// the order.
+func replay(order Order, events []PersistedEvent, version int) (Order, error) {
+	for _, e := range events {
+		if e.Sequence <= version {
+			continue
+		}
+		if err := applyPersisted(&order, e); err != nil {
+			return Order{}, err
+		}
+	}
+
+	return order, nil
+}
+
+// committed returns the events with the sequence numbers assigned by the event
+// store when saved after the expected version.
+func committed(aggregateType, id string, expectedVersion int, events []PersistedEvent) []PersistedEvent {
+	result := make([]PersistedEvent, len(events))
+	for i, e := range events {
+		e.AggregateType = aggregateType
+		e.AggregateID = id
+		e.Sequence = expectedVersion + i + 1
+		result[i] = e
+	}
+	return result
+}
+
+// RepositoryOption configures the default repository.
+type RepositoryOption func(*defaultRepository)
+
+// WithSnapshotStore sets the store used for loading and saving snapshots.
+func WithSnapshotStore(s SnapshotStore) RepositoryOption {
+	return func(r *defaultRepository) {
+		r.Snapshots = s
+	}
+}
+
+// WithSnapshotEvery makes the repository snapshot an order after every n
+// saved events. Unless a snapshot store is given, snapshots are kept in memory.
+func WithSnapshotEvery(n int) RepositoryOption {
+	return func(r *defaultRepository) {
+		if n > 0 {
+			r.SnapshotPolicy = EveryN(n)
+		}
+	}
+}
+
+// WithSnapshotPolicy makes the repository consult the policy after each save
+// to decide whether to snapshot the order. Unless a snapshot store is given,
+// snapshots are kept in memory.
+func WithSnapshotPolicy(p SnapshotPolicy) RepositoryOption {
+	return func(r *defaultRepository) {
+		r.SnapshotPolicy = p
+	}
+}
+
+// SnapshotOnLoad makes the repository snapshot an order when loading it, if
+// more than threshold events had to be replayed since its latest snapshot.
+// This suits orders that are read far more often than they are written,
+// since the snapshot is saved by the first load needing it rather than after
+// a save. Unless a snapshot store is given, snapshots are kept in memory.
+func SnapshotOnLoad(threshold int) RepositoryOption {
+	return func(r *defaultRepository) {
+		r.LoadThreshold = threshold
+	}
+}
+
+// WithEventBus makes the repository publish events to the bus once they have
+// been saved.
+func WithEventBus(b EventBus) RepositoryOption {
+	return func(r *defaultRepository) {
+		r.Bus = b
+	}
+}
+
+// WithOutbox makes the repository enqueue events to the outbox once they have
+// been saved. Since the events are enqueued after they have been committed,
+// enqueueing is best effort: an error is logged rather than returned, and the
+// events won't be published through the outbox. For the events to be enqueued
+// atomically with saving them, use an event store writing to an outbox within
+// the save transaction instead, such as the SQLite event store created with
+// WithSQLiteOutbox or the PostgreSQL event store created with
+// WithPostgresOutbox.
+func WithOutbox(o Outbox) RepositoryOption {
+	return func(r *defaultRepository) {
+		r.Outbox = o
+	}
+}
+
+// WithRepositoryLogger sets the logger used to log failures that don't fail
+// a save. By default nothing is logged.
+func WithRepositoryLogger(l *slog.Logger) RepositoryOption {
+	return func(r *defaultRepository) {
+		r.Logger = l
+	}
+}
+
+// WithRepositoryMetrics sets the metrics used to count saved events.
+func WithRepositoryMetrics(m Metrics) RepositoryOption {
+	return func(r *defaultRepository) {
+		r.Metrics = m
+	}
+}
+
+// NewRepository returns a new instance of the default repository.
+func NewRepository(store EventStore, opts ...RepositoryOption) Repository {
+	r := &defaultRepository{
+		Store:   store,
+		Metrics: nopMetrics{},
+		Logger:  slog.New(slog.DiscardHandler),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if (r.SnapshotPolicy != nil || r.LoadThreshold > 0) && r.Snapshots == nil {
+		r.Snapshots = NewSnapshotStore()
+	}
+
+	return r
+}
+
+// CommandHandler defines an interface for handling order commands.
+type CommandHandler interface {
+	Handle(ctx context.Context, c interface{}) error
+}
+
+type commandHandler struct {
+	Repository Repository
+	Clock      Clock
+	Logger     *slog.Logger
+	Metrics    Metrics
+	Tracer     trace.Tracer
+}
+
+func (h *commandHandler) Handle(ctx context.Context, c interface{}) error {
+	if env, ok := c.(CommandEnvelope); ok {
+		return h.Handle(envelopeContext(ctx, env), env.Command)
+	}
+
+	name := fmt.Sprintf("%T", c)
+	id := commandOrderID(c)
+
+	logger := h.Logger.With(
+		slog.String("command_type", name),
+		slog.String("aggregate_id", id),
+	)
+
+	logger.DebugContext(ctx, "dispatching command")
+
+	ctx, span := h.Tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("aggregate.id", id),
+		attribute.String("command.type", name),
+	))
+
+	begin := time.Now()
+	err := h.handle(ctx, c)
+	h.Metrics.ObserveCommand(name, time.Since(begin), err)
+
+	endSpan(span, err)
+
+	if err != nil {
+		logger.ErrorContext(ctx, "command failed", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+func (h *commandHandler) handle(ctx context.Context, c interface{}) error {
+	switch cmd := c.(type) {
+	case Place:
+		order := NewOrder(cmd.OrderID)
+		order.clock = h.Clock
+		order.metadata = metadataFrom(ctx)
+
+		if err := order.PlaceForCustomer(cmd.CustomerID, cmd.Lines); err != nil {
+			return err
+		}
+
+		err := h.Repository.Save(ctx, &order)
+
+		// A conflict on the first save means that an order with the same ID
+		// has already been placed.
+		var cerr *ConcurrencyError
+		if errors.As(err, &cerr) && cerr.Expected == 0 {
+			return fmt.Errorf("%w: %w", ErrAlreadyPlaced, err)
+		}
+
+		return err
+	case Activate:
+		return h.update(ctx, cmd.OrderID, func(o *Order) error {
+			return o.Activate()
+		})
+	case Cancel:
+		return h.update(ctx, cmd.OrderID, func(o *Order) error {
+			return o.Cancel()
+		})
+	case AddLine:
+		return h.update(ctx, cmd.OrderID, func(o *Order) error {
+			return o.AddLine(cmd.Line)
+		})
+	case RemoveLine:
+		return h.update(ctx, cmd.OrderID, func(o *Order) error {
+			return o.RemoveLine(cmd.ProductID)
+		})
+	case ChangeLineQuantity:
+		return h.update(ctx, cmd.OrderID, func(o *Order) error {
+			return o.ChangeLineQuantity(cmd.ProductID, cmd.NewQuantity)
+		})
+	case ApplyDiscount:
+		return h.update(ctx, cmd.OrderID, func(o *Order) error {
+			return o.ApplyDiscount(cmd.Code, cmd.AmountCents)
+		})
+	case Ship:
+		return h.update(ctx, cmd.OrderID, func(o *Order) error {
+			return o.Ship()
+		})
+	case Deliver:
+		return h.update(ctx, cmd.OrderID, func(o *Order) error {
+			return o.Deliver()
+		})
+	case Expire:
+		return h.update(ctx, cmd.OrderID, func(o *Order) error {
+			return o.Expire()
+		})
+	case Hold:
+		return h.update(ctx, cmd.OrderID, func(o *Order) error {
+			return o.Hold()
+		})
+	case Reactivate:
+		return h.update(ctx, cmd.OrderID, func(o *Order) error {
+			return o.Reactivate()
+		})
+	default:
+		return fmt.Errorf("%w: %T", ErrUnknownCommand, c)
+	}
+}
+
+// update loads an existing order, applies fn to it, and saves the result.
+func (h *commandHandler) update(ctx context.Context, id OrderID, fn func(*Order) error) error {
+	order, err := h.Repository.Load(ctx, string(id))
+	if err != nil {
+		return err
+	}
+
+	h.Logger.DebugContext(ctx, "loaded order",
+		slog.String("aggregate_id", string(id)),
+		slog.Int("version", order.Version()),
+	)
+
+	// Work on a clone, so that a failed or retried command never changes an
+	// order the repository may still hold on to.
+	order = order.Clone()
+	order.clock = h.Clock
+	order.metadata = metadataFrom(ctx)
+
+	if err := fn(&order); err != nil {
+		return err
+	}
+
+	return h.Repository.Save(ctx, &order)
+}
+
+// CommandHandlerOption configures the default command handler.
+type CommandHandlerOption func(*commandHandler)
+
+// WithClock sets the clock used to timestamp new events.
+func WithClock(c Clock) CommandHandlerOption {
+	return func(h *commandHandler) {
+		h.Clock = c
+	}
+}
+
+// WithLogger sets the logger used to log the handled commands. By default
+// nothing is logged.
+func WithLogger(l *slog.Logger) CommandHandlerOption {
+	return func(h *commandHandler) {
+		h.Logger = l
+	}
+}
+
+// WithMetrics sets the metrics used to observe the handled commands.
+func WithMetrics(m Metrics) CommandHandlerOption {
+	return func(h *commandHandler) {
+		h.Metrics = m
+	}
+}
+
+// WithTracer sets the tracer used to start a span for every handled command.
+func WithTracer(t trace.Tracer) CommandHandlerOption {
+	return func(h *commandHandler) {
+		h.Tracer = t
+	}
+}
+
+// commandOrderID returns the value of the OrderID field of the command, if
+// it has one.
+func commandOrderID(c interface{}) string {
+	v := reflect.ValueOf(c)
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+
+	f := v.FieldByName("OrderID")
+	if !f.IsValid() || f.Kind() != reflect.String {
+		return ""
+	}
+
+	return f.String()
+}
+
+// NewCommandHandler returns a new instance of the default command handler.
+func NewCommandHandler(r Repository, opts ...CommandHandlerOption) CommandHandler {
+	h := &commandHandler{
+		Repository: r,
+		Clock:      systemClock{},
+		Logger:     slog.New(slog.DiscardHandler),
+		Metrics:    nopMetrics{},
+		Tracer:     noop.NewTracerProvider().Tracer(instrumentationName),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
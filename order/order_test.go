@@ -1,8 +1,21 @@
 package order_test
 
-import "github.com/marcusolsson/cqrs-example/order"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
 
-import "testing"
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+var testLines = []order.Line{
+	{ProductID: "P1", Quantity: 1, UnitPrice: 100},
+}
 
 func TestPlaceOrder(t *testing.T) {
 	repo := order.NewRepository(
@@ -10,9 +23,14 @@ func TestPlaceOrder(t *testing.T) {
 	)
 
 	handler := order.NewCommandHandler(repo)
-	handler.Handle(order.Place{OrderID: "ABC123", Lines: []order.Line{{}}})
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
-	o := repo.Load("ABC123")
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	if o.ID != "ABC123" {
 		t.Errorf("expected: %v, got: %v", "ABC123", o.ID)
@@ -29,10 +47,17 @@ func TestActivateOrder(t *testing.T) {
 	)
 
 	handler := order.NewCommandHandler(repo)
-	handler.Handle(order.Place{OrderID: "ABC123", Lines: []order.Line{{}}})
-	handler.Handle(order.Activate{OrderID: "ABC123"})
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
-	o := repo.Load("ABC123")
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	if o.ID != "ABC123" {
 		t.Errorf("expected: %v, got: %v", "ABC123", o.ID)
@@ -42,3 +67,827 @@ func TestActivateOrder(t *testing.T) {
 		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
 	}
 }
+
+func TestCancelOrder(t *testing.T) {
+	repo := order.NewRepository(
+		order.NewEventStore(),
+	)
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Cancel{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusCancelled {
+		t.Errorf("expected: %v, got: %v", order.StatusCancelled, o.Status)
+	}
+}
+
+func TestCancelOrderTwice(t *testing.T) {
+	repo := order.NewRepository(
+		order.NewEventStore(),
+	)
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Cancel{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := handler.Handle(context.Background(), order.Cancel{OrderID: "ABC123"})
+	if !errors.Is(err, order.ErrOrderAlreadyCancelled) {
+		t.Errorf("expected: %v, got: %v", order.ErrOrderAlreadyCancelled, err)
+	}
+}
+
+func TestActivateUnknownOrder(t *testing.T) {
+	repo := order.NewRepository(
+		order.NewEventStore(),
+	)
+
+	handler := order.NewCommandHandler(repo)
+
+	err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"})
+	if !errors.Is(err, order.ErrOrderNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrOrderNotFound, err)
+	}
+}
+
+type failingStore struct {
+	order.EventStore
+	err error
+}
+
+func (s failingStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []order.PersistedEvent) error {
+	return s.err
+}
+
+func TestPlaceOrderSaveError(t *testing.T) {
+	errSave := errors.New("disk full")
+
+	repo := order.NewRepository(
+		failingStore{EventStore: order.NewEventStore(), err: errSave},
+	)
+
+	handler := order.NewCommandHandler(repo)
+
+	err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines})
+	if !errors.Is(err, errSave) {
+		t.Errorf("expected: %v, got: %v", errSave, err)
+	}
+}
+
+func TestConcurrentSaveConflict(t *testing.T) {
+	repo := order.NewRepository(
+		order.NewEventStore(),
+	)
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	first, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := first.Activate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := second.Activate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := repo.Save(context.Background(), &first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := repo.Save(context.Background(), &second); !errors.Is(err, order.ErrConcurrencyConflict) {
+		t.Errorf("expected: %v, got: %v", order.ErrConcurrencyConflict, err)
+	}
+}
+
+func TestEventSequenceNumbers(t *testing.T) {
+	store := order.NewEventStore()
+	repo := order.NewRepository(store)
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(events))
+	}
+
+	for i, e := range events {
+		if e.Sequence != i+1 {
+			t.Errorf("expected: %v, got: %v", i+1, e.Sequence)
+		}
+		if e.AggregateID != "ABC123" {
+			t.Errorf("expected: %v, got: %v", "ABC123", e.AggregateID)
+		}
+	}
+}
+
+// duplicatingStore delivers every loaded event twice.
+type duplicatingStore struct {
+	order.EventStore
+}
+
+func (s duplicatingStore) Load(ctx context.Context, aggregateType, id string) ([]order.PersistedEvent, error) {
+	events, err := s.EventStore.Load(ctx, aggregateType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var duplicated []order.PersistedEvent
+	for _, e := range events {
+		duplicated = append(duplicated, e, e)
+	}
+
+	return duplicated, nil
+}
+
+func TestLoadIgnoresDuplicateEvents(t *testing.T) {
+	repo := order.NewRepository(duplicatingStore{order.NewEventStore()})
+	handler := order.NewCommandHandler(repo)
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.Activate{OrderID: "ABC123"},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error for %T: %v", cmd, err)
+		}
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(o.Lines) != 1 {
+		t.Errorf("expected: %v, got: %v", 1, len(o.Lines))
+	}
+	if total, _ := o.Total(); total != 100 {
+		t.Errorf("expected: %v, got: %v", 100, total)
+	}
+	if o.Version() != 2 {
+		t.Errorf("expected: %v, got: %v", 2, o.Version())
+	}
+	if o.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+	}
+}
+
+type stubClock struct {
+	now time.Time
+}
+
+func (c stubClock) Now() time.Time {
+	return c.now
+}
+
+func TestEventOccurredAt(t *testing.T) {
+	now := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	store := order.NewEventStore()
+	repo := order.NewRepository(store)
+
+	handler := order.NewCommandHandler(repo, order.WithClock(stubClock{now: now}))
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !events[0].OccurredAt.Equal(now) {
+		t.Errorf("expected: %v, got: %v", now, events[0].OccurredAt)
+	}
+}
+
+func TestConcurrentPlaceOrders(t *testing.T) {
+	repo := order.NewRepository(
+		order.NewEventStore(),
+	)
+
+	handler := order.NewCommandHandler(repo)
+
+	const n = 100
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			errs <- handler.Handle(context.Background(), order.Place{OrderID: order.OrderID(id), Lines: testLines})
+		}(fmt.Sprintf("ORDER%d", i))
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("ORDER%d", i)
+		if _, err := repo.Load(context.Background(), id); err != nil {
+			t.Errorf("unexpected error loading %v: %v", id, err)
+		}
+	}
+}
+
+func TestHandleCancelledContext(t *testing.T) {
+	store := order.NewEventStore()
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := handler.Handle(ctx, order.Place{OrderID: "ABC123", Lines: testLines})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected: %v, got: %v", context.Canceled, err)
+	}
+
+	if _, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123"); !errors.Is(err, order.ErrOrderNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrOrderNotFound, err)
+	}
+}
+
+// recordingStore records the events of every call to Save.
+type recordingStore struct {
+	order.EventStore
+	saved [][]order.PersistedEvent
+}
+
+func (s *recordingStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []order.PersistedEvent) error {
+	s.saved = append(s.saved, events)
+	return s.EventStore.Save(ctx, aggregateType, id, expectedVersion, events)
+}
+
+func TestSaveOnlyNewEvents(t *testing.T) {
+	store := &recordingStore{EventStore: order.NewEventStore()}
+	repo := order.NewRepository(store)
+
+	o := order.NewOrder("ABC123")
+	if err := o.Place(testLines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := o.UncommittedEvents(); len(got) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(got))
+	}
+
+	if err := repo.Save(context.Background(), &o); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := o.UncommittedEvents(); len(got) != 0 {
+		t.Errorf("expected: %v, got: %v", 0, len(got))
+	}
+
+	if err := o.Activate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := repo.Save(context.Background(), &o); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(store.saved) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(store.saved))
+	}
+
+	if len(store.saved[1]) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(store.saved[1]))
+	}
+
+	if _, ok := store.saved[1][0].Event.(order.Activated); !ok {
+		t.Errorf("expected: %T, got: %T", order.Activated{}, store.saved[1][0].Event)
+	}
+}
+
+func TestUncommittedEventsIsCopy(t *testing.T) {
+	o := order.NewOrder("ABC123")
+	if err := o.Place(testLines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := o.UncommittedEvents()
+	events[0] = order.Activated{OrderID: "ABC123"}
+
+	if _, ok := o.UncommittedEvents()[0].(order.Placed); !ok {
+		t.Errorf("expected: %T, got: %T", order.Placed{}, o.UncommittedEvents()[0])
+	}
+}
+
+func TestOrderClone(t *testing.T) {
+	o := order.NewOrder("ABC123")
+	if err := o.Place([]order.Line{
+		{ProductID: "P1", Quantity: 1, UnitPrice: 100},
+		{ProductID: "P2", Quantity: 2, UnitPrice: 200},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := o.Clone()
+	c.Lines[0].Quantity = 10
+	if err := c.RemoveLine("P1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.AddLine(order.Line{ProductID: "P3", Quantity: 3, UnitPrice: 300}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []order.Line{
+		{ProductID: "P1", Quantity: 1, UnitPrice: 100},
+		{ProductID: "P2", Quantity: 2, UnitPrice: 200},
+	}
+	if !reflect.DeepEqual(o.Lines, want) {
+		t.Errorf("expected: %v, got: %v", want, o.Lines)
+	}
+
+	if n := len(o.UncommittedEvents()); n != 1 {
+		t.Errorf("expected: %v, got: %v", 1, n)
+	}
+
+	if n := len(c.UncommittedEvents()); n != 3 {
+		t.Errorf("expected: %v, got: %v", 3, n)
+	}
+}
+
+func TestHandleUnknownCommand(t *testing.T) {
+	repo := order.NewRepository(
+		order.NewEventStore(),
+	)
+
+	handler := order.NewCommandHandler(repo)
+
+	type unsupported struct{}
+
+	err := handler.Handle(context.Background(), unsupported{})
+	if !errors.Is(err, order.ErrUnknownCommand) {
+		t.Errorf("expected: %v, got: %v", order.ErrUnknownCommand, err)
+	}
+}
+
+func TestOrderPlace(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		lines   []order.Line
+		wantErr error
+	}{
+		{name: "missing id", id: "", lines: testLines, wantErr: order.ErrMissingOrderID},
+		{name: "empty lines", id: "ABC123", lines: nil, wantErr: order.ErrEmptyOrderLine},
+		{name: "valid", id: "ABC123", lines: testLines},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := order.NewOrder(order.OrderID(tt.id))
+
+			err := o.Place(tt.lines)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestOrderPlaceInvalidLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  order.Line
+		field string
+	}{
+		{name: "empty product", line: order.Line{Quantity: 1, UnitPrice: 100}, field: "ProductID"},
+		{name: "zero quantity", line: order.Line{ProductID: "P1", UnitPrice: 100}, field: "Quantity"},
+		{name: "negative quantity", line: order.Line{ProductID: "P1", Quantity: -1, UnitPrice: 100}, field: "Quantity"},
+		{name: "negative price", line: order.Line{ProductID: "P1", Quantity: 1, UnitPrice: -1}, field: "UnitPrice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := order.NewOrder("ABC123")
+
+			err := o.Place([]order.Line{testLines[0], tt.line})
+			if !errors.Is(err, order.ErrInvalidOrderLine) {
+				t.Fatalf("expected: %v, got: %v", order.ErrInvalidOrderLine, err)
+			}
+
+			var lerr *order.LineError
+			if !errors.As(err, &lerr) {
+				t.Fatalf("expected: %T, got: %T", lerr, err)
+			}
+
+			if lerr.Field != tt.field {
+				t.Errorf("expected: %v, got: %v", tt.field, lerr.Field)
+			}
+		})
+	}
+}
+
+func TestPlaceMultiLineOrder(t *testing.T) {
+	store := order.NewEventStore()
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	lines := []order.Line{
+		{ProductID: "P1", Quantity: 2, UnitPrice: 250},
+		{ProductID: "P2", Quantity: 1, UnitPrice: 0},
+	}
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: lines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	placed, ok := events[0].Event.(order.Placed)
+	if !ok {
+		t.Fatalf("expected: %T, got: %T", order.Placed{}, events[0].Event)
+	}
+
+	if !reflect.DeepEqual(placed.Lines, lines) {
+		t.Errorf("expected: %v, got: %v", lines, placed.Lines)
+	}
+}
+
+func TestOrderTotal(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []order.Line
+		want  int64
+	}{
+		{name: "empty", lines: nil, want: 0},
+		{name: "single", lines: []order.Line{{ProductID: "P1", Quantity: 3, UnitPrice: 199}}, want: 597},
+		{
+			name: "mixed",
+			lines: []order.Line{
+				{ProductID: "P1", Quantity: 2, UnitPrice: 250},
+				{ProductID: "P2", Quantity: 5, UnitPrice: 1},
+				{ProductID: "P3", Quantity: 1, UnitPrice: 0},
+			},
+			want: 505,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := order.NewOrder("ABC123")
+			o.Lines = tt.lines
+
+			if got, _ := o.Total(); got != tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOrderTotalAfterReplay(t *testing.T) {
+	repo := order.NewRepository(order.NewEventStore())
+	handler := order.NewCommandHandler(repo)
+
+	lines := []order.Line{
+		{ProductID: "P1", Quantity: 2, UnitPrice: 250},
+		{ProductID: "P2", Quantity: 3, UnitPrice: 100},
+	}
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: lines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, _ := o.Total(); got != 800 {
+		t.Errorf("expected: %v, got: %v", 800, got)
+	}
+}
+
+func TestOrderPlaceTwice(t *testing.T) {
+	o := order.NewOrder("ABC123")
+
+	if err := o.Place(testLines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := o.Place(testLines); err == nil {
+		t.Errorf("expected error when placing an order twice")
+	}
+}
+
+func TestRepositoryLoadMany(t *testing.T) {
+	repo := order.NewRepository(order.NewEventStore())
+	handler := order.NewCommandHandler(repo)
+
+	for _, id := range []string{"ABC123", "XYZ789"} {
+		if err := handler.Handle(context.Background(), order.Place{OrderID: order.OrderID(id), Lines: testLines}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "XYZ789"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	orders, err := repo.LoadMany(context.Background(), []string{"ABC123", "XYZ789", "MISSING"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(orders) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(orders))
+	}
+
+	if _, ok := orders["MISSING"]; ok {
+		t.Errorf("expected missing order to be absent")
+	}
+
+	for id, want := range map[string]order.Status{
+		"ABC123": order.StatusPlaced,
+		"XYZ789": order.StatusActivated,
+	} {
+		o := orders[id]
+		if o.ID != id {
+			t.Errorf("expected: %v, got: %v", id, o.ID)
+		}
+		if o.Status != want {
+			t.Errorf("expected: %v, got: %v", want, o.Status)
+		}
+	}
+}
+
+func TestEventStoreLoadFrom(t *testing.T) {
+	store := order.NewEventStore()
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	for _, c := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.Activate{OrderID: "ABC123"},
+		order.Ship{OrderID: "ABC123"},
+		order.Deliver{OrderID: "ABC123"},
+	} {
+		if err := handler.Handle(context.Background(), c); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var tests = []struct {
+		afterSequence int
+		want          []int
+	}{
+		{afterSequence: 0, want: []int{1, 2, 3, 4}},
+		{afterSequence: 2, want: []int{3, 4}},
+		{afterSequence: 3, want: []int{4}},
+		{afterSequence: 4, want: []int{}},
+		{afterSequence: 10, want: []int{}},
+	}
+
+	for _, tt := range tests {
+		events, err := store.LoadFrom(context.Background(), order.AggregateTypeOrder, "ABC123", tt.afterSequence)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if events == nil {
+			t.Errorf("expected empty slice, got nil")
+		}
+
+		got := make([]int, len(events))
+		for i, e := range events {
+			got[i] = e.Sequence
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expected: %v, got: %v", tt.want, got)
+		}
+	}
+}
+
+func TestPlaceForCustomer(t *testing.T) {
+	repo := order.NewRepository(order.NewEventStore())
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", CustomerID: "alice", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.CustomerID != "alice" {
+		t.Errorf("expected: %v, got: %v", "alice", o.CustomerID)
+	}
+}
+
+func TestConcurrencyError(t *testing.T) {
+	fileStore, err := order.NewFileEventStore(filepath.Join(t.TempDir(), "events.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sqliteStore, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	stores := map[string]order.EventStore{
+		"memory": order.NewEventStore(),
+		"file":   fileStore,
+		"sqlite": sqliteStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			saveEvents(t, store, "ABC123", 2)
+
+			err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 1, []order.PersistedEvent{
+				{Event: order.Activated{OrderID: "ABC123"}},
+			})
+
+			if !errors.Is(err, order.ErrConcurrencyConflict) {
+				t.Errorf("expected: %v, got: %v", order.ErrConcurrencyConflict, err)
+			}
+
+			var cerr *order.ConcurrencyError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("expected: %T, got: %T", cerr, err)
+			}
+
+			want := order.ConcurrencyError{AggregateID: "ABC123", Expected: 1, Actual: 2}
+			if *cerr != want {
+				t.Errorf("expected: %+v, got: %+v", want, *cerr)
+			}
+		})
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore()))
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "PLACED", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var tests = []struct {
+		name    string
+		command interface{}
+		want    error
+	}{
+		{name: "missing id", command: order.Place{Lines: testLines}, want: order.ErrMissingOrderID},
+		{name: "empty lines", command: order.Place{OrderID: "ABC123"}, want: order.ErrEmptyOrderLine},
+		{name: "already placed", command: order.Place{OrderID: "PLACED", Lines: testLines}, want: order.ErrAlreadyPlaced},
+		{name: "not found", command: order.Activate{OrderID: "MISSING"}, want: order.ErrOrderNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := handler.Handle(context.Background(), tt.command)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("expected: %v, got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadHasNoUncommittedEvents(t *testing.T) {
+	tests := map[string][]order.RepositoryOption{
+		"events":   nil,
+		"snapshot": {order.WithSnapshotEvery(2)},
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			repo := order.NewRepository(order.NewEventStore(), opts...)
+			handler := order.NewCommandHandler(repo)
+
+			for _, cmd := range []interface{}{
+				order.Place{OrderID: "ABC123", Lines: testLines},
+				order.AddLine{OrderID: "ABC123", Line: order.Line{ProductID: "P2", Quantity: 2, UnitPrice: 50}},
+				order.Activate{OrderID: "ABC123"},
+			} {
+				if err := handler.Handle(context.Background(), cmd); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			o, err := repo.Load(context.Background(), "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if n := len(o.UncommittedEvents()); n != 0 {
+				t.Errorf("expected: %v, got: %v", 0, n)
+			}
+			if len(o.Lines) != 2 {
+				t.Errorf("expected: %v, got: %v", 2, len(o.Lines))
+			}
+
+			orders, err := repo.LoadMany(context.Background(), []string{"ABC123"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			loaded := orders["ABC123"]
+			if n := len(loaded.UncommittedEvents()); n != 0 {
+				t.Errorf("expected: %v, got: %v", 0, n)
+			}
+		})
+	}
+}
+
+func TestOrderCurrency(t *testing.T) {
+	repo := order.NewRepository(order.NewEventStore())
+	handler := order.NewCommandHandler(repo)
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: []order.Line{
+			{ProductID: "P1", Quantity: 2, UnitPrice: 250, Currency: "EUR"},
+			{ProductID: "P2", Quantity: 1, UnitPrice: 100, Currency: "EUR"},
+		}},
+		order.AddLine{OrderID: "ABC123", Line: order.Line{ProductID: "P3", Quantity: 1, UnitPrice: 50, Currency: "EUR"}},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error for %T: %v", cmd, err)
+		}
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if total, currency := o.Total(); total != 650 || currency != "EUR" {
+		t.Errorf("expected: %v %v, got: %v %v", 650, "EUR", total, currency)
+	}
+}
+
+func TestOrderCurrencyMismatch(t *testing.T) {
+	handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore()))
+
+	err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: []order.Line{
+		{ProductID: "P1", Quantity: 1, UnitPrice: 100, Currency: "EUR"},
+		{ProductID: "P2", Quantity: 1, UnitPrice: 100, Currency: "USD"},
+	}})
+	if !errors.Is(err, order.ErrCurrencyMismatch) {
+		t.Errorf("expected: %v, got: %v", order.ErrCurrencyMismatch, err)
+	}
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "XYZ789", Lines: []order.Line{
+		{ProductID: "P1", Quantity: 1, UnitPrice: 100, Currency: "EUR"},
+	}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = handler.Handle(context.Background(), order.AddLine{OrderID: "XYZ789", Line: order.Line{ProductID: "P2", Quantity: 1, UnitPrice: 100, Currency: "SEK"}})
+	if !errors.Is(err, order.ErrCurrencyMismatch) {
+		t.Errorf("expected: %v, got: %v", order.ErrCurrencyMismatch, err)
+	}
+}
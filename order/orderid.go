@@ -0,0 +1,48 @@
+package order
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// OrderID identifies an order. Using a distinct type rather than a plain
+// string keeps order IDs from being mixed up with other IDs, such as product
+// or customer IDs. Order IDs are stored and serialized as strings.
+type OrderID string
+
+// NewOrderID returns a new, random order ID in the form of a version 4 UUID.
+func NewOrderID() OrderID {
+	var b [16]byte
+	rand.Read(b[:])
+
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return OrderID(formatUUID(b))
+}
+
+// formatUUID returns the canonical text form of the UUID.
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// ParseOrderID returns the order ID in s, or ErrMissingOrderID if s is empty.
+func ParseOrderID(s string) (OrderID, error) {
+	id := OrderID(s)
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Validate returns ErrMissingOrderID if the ID is empty.
+func (id OrderID) Validate() error {
+	if id == "" {
+		return ErrMissingOrderID
+	}
+	return nil
+}
+
+func (id OrderID) String() string {
+	return string(id)
+}
@@ -0,0 +1,77 @@
+package order_test
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewOrderID(t *testing.T) {
+	seen := make(map[order.OrderID]bool)
+
+	for i := 0; i < 100; i++ {
+		id := order.NewOrderID()
+
+		if !uuidV4.MatchString(id.String()) {
+			t.Fatalf("expected a version 4 UUID, got: %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate order ID: %q", id)
+		}
+		seen[id] = true
+
+		if err := id.Validate(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestParseOrderID(t *testing.T) {
+	id, err := order.ParseOrderID("ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "ABC123" {
+		t.Errorf("expected: %v, got: %v", "ABC123", id)
+	}
+
+	if _, err := order.ParseOrderID(""); !errors.Is(err, order.ErrMissingOrderID) {
+		t.Errorf("expected: %v, got: %v", order.ErrMissingOrderID, err)
+	}
+	if err := order.OrderID("").Validate(); !errors.Is(err, order.ErrMissingOrderID) {
+		t.Errorf("expected: %v, got: %v", order.ErrMissingOrderID, err)
+	}
+}
+
+func TestOrderIDJSONRoundTrip(t *testing.T) {
+	e := order.Placed{OrderID: order.NewOrderID(), Lines: testLines}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Order IDs are serialized as plain strings.
+	var raw struct {
+		OrderID string
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if raw.OrderID != e.OrderID.String() {
+		t.Errorf("expected: %v, got: %v", e.OrderID, raw.OrderID)
+	}
+
+	var got order.Placed
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.OrderID != e.OrderID {
+		t.Errorf("expected: %v, got: %v", e.OrderID, got.OrderID)
+	}
+}
@@ -0,0 +1,218 @@
+package order
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OutboxMessage is an event waiting in the outbox to be published.
+type OutboxMessage struct {
+	ID    int64
+	Event PersistedEvent
+}
+
+// Outbox holds committed events until they have been published. Events are
+// enqueued when they are saved, and removed from the pending messages once
+// they are marked as published.
+type Outbox interface {
+	Enqueue(ctx context.Context, events []PersistedEvent) error
+	Pending(ctx context.Context, limit int) ([]OutboxMessage, error)
+	MarkPublished(ctx context.Context, ids []int64) error
+}
+
+type outbox struct {
+	mu       sync.Mutex
+	messages []OutboxMessage
+	nextID   int64
+}
+
+// Enqueue adds the events to the outbox in order.
+func (o *outbox) Enqueue(ctx context.Context, events []PersistedEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, e := range events {
+		o.nextID++
+		o.messages = append(o.messages, OutboxMessage{ID: o.nextID, Event: e})
+	}
+
+	return nil
+}
+
+// Pending returns up to limit unpublished messages in the order they were
+// enqueued. A limit of zero or less returns all of them.
+func (o *outbox) Pending(ctx context.Context, limit int) ([]OutboxMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	n := len(o.messages)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+
+	result := make([]OutboxMessage, n)
+	copy(result, o.messages)
+
+	return result, nil
+}
+
+// MarkPublished removes the messages from the outbox.
+func (o *outbox) MarkPublished(ctx context.Context, ids []int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	published := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		published[id] = true
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	pending := o.messages[:0]
+	for _, m := range o.messages {
+		if !published[m.ID] {
+			pending = append(pending, m)
+		}
+	}
+	o.messages = pending
+
+	return nil
+}
+
+// NewOutbox returns a new instance of the default in-memory outbox. The outbox
+// is safe for concurrent use by multiple goroutines.
+func NewOutbox() Outbox {
+	return &outbox{}
+}
+
+// OutboxPublisher polls an outbox and publishes the pending events to an
+// event bus. Events are published at least once; an event is only published
+// again if the publisher stops before it has been marked as published.
+type OutboxPublisher struct {
+	Outbox Outbox
+	Bus    EventBus
+
+	// Interval is the time to wait between polls. The outbox is polled every
+	// second if it isn't positive.
+	Interval time.Duration
+
+	// BatchSize is the maximum number of events published per poll.
+	BatchSize int
+
+	life lifecycle
+}
+
+// Publish publishes the pending events once and returns the number of events
+// that were published. If the bus doesn't deliver the events, e.g. because it
+// has been closed, they are left pending.
+func (p *OutboxPublisher) Publish(ctx context.Context) (int, error) {
+	messages, err := p.Outbox.Pending(ctx, p.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	events := make([]PersistedEvent, len(messages))
+	ids := make([]int64, len(messages))
+	for i, m := range messages {
+		events[i] = m.Event
+		ids[i] = m.ID
+	}
+
+	if err := p.Bus.Publish(events); err != nil {
+		return 0, err
+	}
+
+	if err := p.Outbox.MarkPublished(ctx, ids); err != nil {
+		return 0, err
+	}
+
+	return len(messages), nil
+}
+
+// Run polls the outbox until the context is cancelled or the publisher is
+// closed. Full batches are followed by another poll without waiting for the
+// interval.
+func (p *OutboxPublisher) Run(ctx context.Context) error {
+	if err := p.life.enter(); err != nil {
+		return err
+	}
+	defer p.life.exit()
+
+	return p.run(ctx)
+}
+
+// Start polls the outbox in a new goroutine until the publisher is closed.
+func (p *OutboxPublisher) Start() error {
+	return p.life.start(func() error {
+		return p.run(context.Background())
+	})
+}
+
+// Close stops polling the outbox, and waits for the events being published
+// to be marked as published, or for the context to be done. It returns the
+// error that stopped the polling started by Start, if any.
+func (p *OutboxPublisher) Close(ctx context.Context) error {
+	return p.life.close(ctx)
+}
+
+func (p *OutboxPublisher) run(ctx context.Context) error {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	stop := p.life.stopping()
+
+	for {
+		n, err := p.Publish(ctx)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+
+		if n > 0 && n == p.BatchSize {
+			select {
+			case <-stop:
+				return nil
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NewOutboxPublisher returns a new publisher of the events in the outbox,
+// polling every second in batches of 100 events.
+func NewOutboxPublisher(o Outbox, b EventBus) *OutboxPublisher {
+	return &OutboxPublisher{
+		Outbox:    o,
+		Bus:       b,
+		Interval:  time.Second,
+		BatchSize: 100,
+	}
+}
@@ -0,0 +1,263 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestOutboxPublishesOnce(t *testing.T) {
+	outbox := order.NewOutbox()
+
+	repo := order.NewRepository(order.NewEventStore(), order.WithOutbox(outbox))
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pending, err := outbox.Pending(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(pending) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(pending))
+	}
+
+	bus := order.NewEventBus()
+
+	var received []order.PersistedEvent
+	bus.Subscribe(func(e order.PersistedEvent) {
+		received = append(received, e)
+	})
+
+	publisher := order.NewOutboxPublisher(outbox, bus)
+
+	for i, want := range []int{2, 0} {
+		n, err := publisher.Publish(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != want {
+			t.Errorf("poll %d: expected: %v, got: %v", i, want, n)
+		}
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(received))
+	}
+
+	for i, e := range received {
+		if e.Sequence != i+1 {
+			t.Errorf("expected: %v, got: %v", i+1, e.Sequence)
+		}
+	}
+}
+
+func TestOutboxSaveFailure(t *testing.T) {
+	outbox := order.NewOutbox()
+
+	repo := order.NewRepository(
+		failingStore{EventStore: order.NewEventStore(), err: errors.New("disk full")},
+		order.WithOutbox(outbox),
+	)
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	pending, err := outbox.Pending(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(pending) != 0 {
+		t.Errorf("expected: %v, got: %v", 0, len(pending))
+	}
+}
+
+// failingOutbox is an outbox that fails to enqueue events.
+type failingOutbox struct {
+	order.Outbox
+	err error
+}
+
+func (o failingOutbox) Enqueue(ctx context.Context, events []order.PersistedEvent) error {
+	return o.err
+}
+
+func TestOutboxEnqueueFailure(t *testing.T) {
+	capture := newCapturingHandler()
+	store := order.NewEventStore()
+
+	repo := order.NewRepository(store,
+		order.WithOutbox(failingOutbox{Outbox: order.NewOutbox(), err: errors.New("outbox full")}),
+		order.WithRepositoryLogger(slog.New(capture)),
+	)
+
+	// The events are committed, so the command succeeds.
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var found bool
+	for _, rec := range *capture.records {
+		if rec.level == slog.LevelError && rec.attrs["aggregate_id"] == "ABC123" && rec.attrs["error"] == "outbox full" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("expected the enqueue failure to be logged")
+	}
+}
+
+func TestOutboxPublisherRun(t *testing.T) {
+	outbox := order.NewOutbox()
+
+	events := []order.PersistedEvent{
+		{Event: order.Placed{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 1},
+		{Event: order.Activated{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 2},
+		{Event: order.Placed{OrderID: "XYZ789"}, AggregateID: "XYZ789", Sequence: 1},
+	}
+	if err := outbox.Enqueue(context.Background(), events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bus := order.NewEventBus()
+
+	var (
+		mu       sync.Mutex
+		received int
+		done     = make(chan struct{})
+	)
+	bus.Subscribe(func(e order.PersistedEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		received++
+		if received == len(events) {
+			close(done)
+		}
+	})
+
+	publisher := order.NewOutboxPublisher(outbox, bus)
+	publisher.Interval = time.Millisecond
+	publisher.BatchSize = 2
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errc := make(chan error, 1)
+	go func() { errc <- publisher.Run(ctx) }()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for events")
+	}
+
+	cancel()
+
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected: %v, got: %v", context.Canceled, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if received != len(events) {
+		t.Errorf("expected: %v, got: %v", len(events), received)
+	}
+}
+
+func TestOutboxPublisherClose(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	outbox := order.NewOutbox()
+	if err := outbox.Enqueue(context.Background(), []order.PersistedEvent{
+		{Event: order.Placed{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 1},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bus := order.NewEventBus()
+
+	published := make(chan struct{})
+	bus.Subscribe(func(e order.PersistedEvent) {
+		close(published)
+	})
+
+	publisher := order.NewOutboxPublisher(outbox, bus)
+	publisher.Interval = time.Millisecond
+
+	if err := publisher.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for events")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := publisher.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := bus.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := publisher.Run(context.Background()); !errors.Is(err, order.ErrClosed) {
+		t.Errorf("expected: %v, got: %v", order.ErrClosed, err)
+	}
+}
+
+func TestOutboxPublisherClosedBus(t *testing.T) {
+	outbox := order.NewOutbox()
+	if err := outbox.Enqueue(context.Background(), []order.PersistedEvent{
+		{Event: order.Placed{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 1},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bus := order.NewEventBus()
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The interval is left unset, so the default applies.
+	publisher := &order.OutboxPublisher{Outbox: outbox, Bus: bus}
+
+	if err := publisher.Run(context.Background()); !errors.Is(err, order.ErrClosed) {
+		t.Errorf("expected: %v, got: %v", order.ErrClosed, err)
+	}
+
+	pending, err := outbox.Pending(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(pending) != 1 {
+		t.Errorf("expected: %v, got: %v", 1, len(pending))
+	}
+}
@@ -0,0 +1,156 @@
+package order
+
+import "context"
+
+// page trims events, holding up to limit+1 events in sequence order, to a page
+// of at most limit events. The cursor is the sequence number of the last event
+// in the page if more events follow, and zero otherwise. A limit of zero or
+// less returns all events.
+func page(events []PersistedEvent, limit int) ([]PersistedEvent, int) {
+	if limit <= 0 || len(events) <= limit {
+		return events, 0
+	}
+
+	events = events[:limit]
+
+	return events, events[limit-1].Sequence
+}
+
+// EventIterator reads the events of an aggregate from an event store one page at
+// a time, so that the whole history doesn't have to be kept in memory.
+//
+//	it := order.NewEventIterator(ctx, store, order.AggregateTypeOrder, "ABC123", 100)
+//	for it.Next() {
+//		e := it.Event()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type EventIterator struct {
+	ctx           context.Context
+	store         EventStore
+	aggregateType string
+	id            string
+	pageSize      int
+
+	page   []PersistedEvent
+	cursor int
+	event  PersistedEvent
+	done   bool
+	err    error
+}
+
+// Next advances the iterator to the next event, loading the next page when
+// needed. It returns false when there are no more events or an error occurred.
+func (it *EventIterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		events, next, err := it.store.LoadPage(it.ctx, it.aggregateType, it.id, it.cursor, it.pageSize)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.page = events
+		it.cursor = next
+		it.done = next == 0
+	}
+
+	it.event, it.page = it.page[0], it.page[1:]
+
+	return true
+}
+
+// Event returns the current event.
+func (it *EventIterator) Event() PersistedEvent {
+	return it.event
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (it *EventIterator) Err() error {
+	return it.err
+}
+
+// NewEventIterator returns an iterator over the events of the aggregate,
+// loading pageSize events at a time.
+func NewEventIterator(ctx context.Context, store EventStore, aggregateType, id string, pageSize int) *EventIterator {
+	return &EventIterator{
+		ctx:           ctx,
+		store:         store,
+		aggregateType: aggregateType,
+		id:            id,
+		pageSize:      pageSize,
+	}
+}
+
+// streamPageSize is the number of events loaded at a time by stream.
+const streamPageSize = 256
+
+// stream sends the events of the aggregate on the returned channel in sequence
+// order, loading them one page at a time. Both channels are closed once all
+// events have been sent; before that, the error channel receives the error
+// that stopped the stream, if any. Streaming an aggregate without events fails
+// with ErrOrderNotFound, and cancelling the context stops the stream with the
+// error of the context.
+func stream(ctx context.Context, store EventStore, aggregateType, id string) (<-chan PersistedEvent, <-chan error) {
+	events := make(chan PersistedEvent)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		defer close(errc)
+
+		it := NewEventIterator(ctx, store, aggregateType, id, streamPageSize)
+
+		var sent int
+		for it.Next() {
+			if err := ctx.Err(); err != nil {
+				errc <- err
+				return
+			}
+
+			select {
+			case events <- it.Event():
+				sent++
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+
+		switch {
+		case it.Err() != nil:
+			errc <- it.Err()
+		case sent == 0:
+			errc <- ErrOrderNotFound
+		}
+	}()
+
+	return events, errc
+}
+
+// LoadStreaming rebuilds the order from the event store, applying the events
+// as they are streamed rather than loading the whole history first.
+func LoadStreaming(ctx context.Context, store EventStore, id string) (Order, error) {
+	events, errc := store.Stream(ctx, AggregateTypeOrder, id)
+
+	var o Order
+	for e := range events {
+		if err := applyPersisted(&o, e); err != nil {
+			// Drain the stream to let it finish.
+			for range events {
+			}
+			return Order{}, err
+		}
+	}
+
+	if err := <-errc; err != nil {
+		return Order{}, err
+	}
+
+	return o, nil
+}
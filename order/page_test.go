@@ -0,0 +1,296 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// saveEvents stores n events for the order.
+func saveEvents(t *testing.T, store order.EventStore, id string, n int) {
+	t.Helper()
+
+	events := make([]order.PersistedEvent, n)
+	for i := range events {
+		events[i] = order.PersistedEvent{Event: order.LineAdded{OrderID: order.OrderID(id), Line: testLines[0]}}
+	}
+
+	if err := store.Save(context.Background(), order.AggregateTypeOrder, id, 0, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestEventStoreLoadPage(t *testing.T) {
+	fileStore, err := order.NewFileEventStore(filepath.Join(t.TempDir(), "events.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sqliteStore, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	stores := map[string]order.EventStore{
+		"memory": order.NewEventStore(),
+		"file":   fileStore,
+		"sqlite": sqliteStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			saveEvents(t, store, "ABC123", 7)
+			saveEvents(t, store, "XYZ789", 2)
+
+			var (
+				pages  [][]int
+				cursor int
+			)
+			for {
+				events, next, err := store.LoadPage(context.Background(), order.AggregateTypeOrder, "ABC123", cursor, 3)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				page := make([]int, len(events))
+				for i, e := range events {
+					page[i] = e.Sequence
+				}
+				pages = append(pages, page)
+
+				if next == 0 {
+					break
+				}
+				cursor = next
+			}
+
+			want := [][]int{{1, 2, 3}, {4, 5, 6}, {7}}
+			if !reflect.DeepEqual(pages, want) {
+				t.Errorf("expected: %v, got: %v", want, pages)
+			}
+
+			events, next, err := store.LoadPage(context.Background(), order.AggregateTypeOrder, "ABC123", 7, 3)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(events) != 0 || next != 0 {
+				t.Errorf("expected empty last page, got: %v events, cursor %v", len(events), next)
+			}
+		})
+	}
+}
+
+func TestEventStoreLoadPageExactFit(t *testing.T) {
+	store := order.NewEventStore()
+	saveEvents(t, store, "ABC123", 4)
+
+	events, next, err := store.LoadPage(context.Background(), order.AggregateTypeOrder, "ABC123", 0, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 4 {
+		t.Errorf("expected: %v, got: %v", 4, len(events))
+	}
+	if next != 0 {
+		t.Errorf("expected: %v, got: %v", 0, next)
+	}
+}
+
+func TestEventIterator(t *testing.T) {
+	store := order.NewEventStore()
+	saveEvents(t, store, "ABC123", 10)
+
+	it := order.NewEventIterator(context.Background(), store, order.AggregateTypeOrder, "ABC123", 4)
+
+	var got []int
+	for it.Next() {
+		got = append(got, it.Event().Sequence)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected: %v, got: %v", want, got)
+	}
+}
+
+func TestEventStoreStream(t *testing.T) {
+	fileStore, err := order.NewFileEventStore(filepath.Join(t.TempDir(), "events.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sqliteStore, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	stores := map[string]order.EventStore{
+		"memory": order.NewEventStore(),
+		"file":   fileStore,
+		"sqlite": sqliteStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			handler := order.NewCommandHandler(order.NewRepository(store))
+
+			for _, cmd := range []interface{}{
+				order.Place{OrderID: "ABC123", Lines: testLines},
+				order.Place{OrderID: "XYZ789", Lines: testLines},
+				order.Activate{OrderID: "ABC123"},
+			} {
+				if err := handler.Handle(context.Background(), cmd); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			events, errc := store.Stream(context.Background(), order.AggregateTypeOrder, "ABC123")
+
+			var got []interface{}
+			for e := range events {
+				if e.Sequence != len(got)+1 {
+					t.Errorf("expected: %v, got: %v", len(got)+1, e.Sequence)
+				}
+				got = append(got, e.Event)
+			}
+
+			if err := <-errc; err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := []interface{}{
+				order.Placed{OrderID: "ABC123", Lines: testLines},
+				order.Activated{OrderID: "ABC123"},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected: %v, got: %v", want, got)
+			}
+
+			o, err := order.LoadStreaming(context.Background(), store, "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if o.Status != order.StatusActivated || o.Version() != 2 {
+				t.Errorf("unexpected order: %+v", o)
+			}
+		})
+	}
+}
+
+func TestEventStoreStreamNotFound(t *testing.T) {
+	events, errc := order.NewEventStore().Stream(context.Background(), order.AggregateTypeOrder, "ABC123")
+
+	for range events {
+		t.Error("unexpected event")
+	}
+
+	if err := <-errc; !errors.Is(err, order.ErrOrderNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrOrderNotFound, err)
+	}
+}
+
+func TestEventStoreStreamCancelled(t *testing.T) {
+	store := order.NewEventStore()
+	saveEvents(t, store, "ABC123", 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	events, errc := store.Stream(ctx, order.AggregateTypeOrder, "ABC123")
+
+	<-events
+	cancel()
+
+	// The stream may send one more event before noticing the cancellation.
+	for range events {
+	}
+
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected: %v, got: %v", context.Canceled, err)
+	}
+}
+
+func TestEventStoreLoadAllFrom(t *testing.T) {
+	fileStore, err := order.NewFileEventStore(filepath.Join(t.TempDir(), "events.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sqliteStore, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	stores := map[string]order.EventStore{
+		"memory": order.NewEventStore(),
+		"file":   fileStore,
+		"sqlite": sqliteStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			handler := order.NewCommandHandler(order.NewRepository(store))
+
+			for _, cmd := range []interface{}{
+				order.Place{OrderID: "ABC123", Lines: testLines},
+				order.Place{OrderID: "XYZ789", Lines: testLines},
+				order.Activate{OrderID: "ABC123"},
+				order.Place{OrderID: "DEF456", Lines: testLines},
+				order.Activate{OrderID: "XYZ789"},
+			} {
+				if err := handler.Handle(context.Background(), cmd); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			all, err := store.LoadAllFrom(context.Background(), 0, 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(all) != 5 {
+				t.Fatalf("expected: %v, got: %v", 5, len(all))
+			}
+
+			for i := 1; i < len(all); i++ {
+				if all[i].GlobalPosition <= all[i-1].GlobalPosition {
+					t.Errorf("expected increasing positions, got: %v after %v", all[i].GlobalPosition, all[i-1].GlobalPosition)
+				}
+			}
+
+			var (
+				paged    []order.PersistedEvent
+				position int64
+			)
+			for {
+				events, err := store.LoadAllFrom(context.Background(), position, 2)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(events) == 0 {
+					break
+				}
+				if len(events) > 2 {
+					t.Fatalf("expected at most %v events, got: %v", 2, len(events))
+				}
+
+				paged = append(paged, events...)
+				position = events[len(events)-1].GlobalPosition
+			}
+
+			if !reflect.DeepEqual(paged, all) {
+				t.Errorf("expected: %v, got: %v", all, paged)
+			}
+		})
+	}
+}
@@ -0,0 +1,617 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// postgresSchema creates the append-only events table and the outbox. The
+// primary key on (aggregate_type, aggregate_id, sequence) guards against
+// concurrent writers, and the unique index on event_id keeps an event from
+// being saved twice.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS events (
+	global_sequence BIGSERIAL   NOT NULL UNIQUE,
+	event_id        TEXT        NOT NULL DEFAULT '',
+	aggregate_type  TEXT        NOT NULL DEFAULT 'order',
+	aggregate_id    TEXT        NOT NULL,
+	sequence        INTEGER     NOT NULL,
+	event_type      TEXT        NOT NULL,
+	payload         JSONB       NOT NULL,
+	occurred_at     TIMESTAMPTZ NOT NULL,
+	correlation_id  TEXT        NOT NULL DEFAULT '',
+	causation_id    TEXT        NOT NULL DEFAULT '',
+	metadata        JSONB       NOT NULL DEFAULT '{}',
+	schema_version  INTEGER     NOT NULL DEFAULT 1,
+	PRIMARY KEY (aggregate_type, aggregate_id, sequence)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS events_event_id ON events (event_id) WHERE event_id <> '';
+
+CREATE TABLE IF NOT EXISTS outbox (
+	id             BIGSERIAL   PRIMARY KEY,
+	event_id       TEXT        NOT NULL DEFAULT '',
+	aggregate_type TEXT        NOT NULL DEFAULT 'order',
+	aggregate_id   TEXT        NOT NULL,
+	sequence       INTEGER     NOT NULL,
+	event_type     TEXT        NOT NULL,
+	payload        JSONB       NOT NULL,
+	occurred_at    TIMESTAMPTZ NOT NULL,
+	correlation_id TEXT        NOT NULL DEFAULT '',
+	causation_id   TEXT        NOT NULL DEFAULT '',
+	metadata       JSONB       NOT NULL DEFAULT '{}',
+	schema_version INTEGER     NOT NULL DEFAULT 1,
+	published_at   TIMESTAMPTZ
+)`
+
+// pgUniqueViolation is the SQLSTATE reported by PostgreSQL for a violated
+// unique constraint.
+const pgUniqueViolation = "23505"
+
+// MigratePostgres creates the tables needed by the PostgreSQL event store.
+func MigratePostgres(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, postgresSchema)
+	return err
+}
+
+type postgresEventStore struct {
+	db     *sql.DB
+	outbox bool
+}
+
+// Save inserts the events within a single transaction. A concurrent writer
+// that has already stored an event with the same sequence causes the
+// transaction to be rolled back with a *ConcurrencyError.
+func (s *postgresEventStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []PersistedEvent) (err error) {
+	ctx, span := startSpan(ctx, "EventStore.Save", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+	))
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.save(ctx, tx, aggregateType, id, expectedVersion, events); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		if isUniqueViolation(err) {
+			return s.conflict(ctx, aggregateType, id, expectedVersion)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// SaveAll inserts the events of every aggregate within a single transaction,
+// so that a conflict on any aggregate rolls back all of them.
+func (s *postgresEventStore) SaveAll(ctx context.Context, changes []AggregateChanges) (err error) {
+	ctx, span := startSpan(ctx, "EventStore.SaveAll", trace.WithAttributes(
+		attribute.Int("aggregate.count", len(changes)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, c := range changes {
+		if err := s.save(ctx, tx, c.AggregateType, c.AggregateID, c.ExpectedVersion, c.Events); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		if isUniqueViolation(err) {
+			return s.conflictAll(ctx, changes)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// save inserts the events of the aggregate within the transaction, checking
+// that the aggregate is at the expected version.
+func (s *postgresEventStore) save(ctx context.Context, tx *sql.Tx, aggregateType, id string, expectedVersion int, events []PersistedEvent) error {
+	// Saving only events that have already been saved, e.g. when retrying a
+	// save, succeeds without changes.
+	if len(events) > 0 {
+		unsaved, skipped, err := s.unsaved(ctx, tx, events)
+		if err != nil {
+			return err
+		}
+		recordSkipped(ctx, skipped)
+
+		if events = unsaved; len(events) == 0 {
+			return nil
+		}
+	}
+
+	var version int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(sequence), 0) FROM events WHERE aggregate_type = $1 AND aggregate_id = $2`, aggregateType, id,
+	).Scan(&version); err != nil {
+		return err
+	}
+
+	if version != expectedVersion {
+		return &ConcurrencyError{AggregateID: id, Expected: expectedVersion, Actual: version}
+	}
+
+	for _, e := range events {
+		payload, name, err := MarshalEvent(e.Event)
+		if err != nil {
+			return err
+		}
+
+		metadata, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return err
+		}
+
+		version++
+
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO events (event_id, aggregate_type, aggregate_id, sequence, event_type, payload, occurred_at, correlation_id, causation_id, metadata, schema_version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			e.EventID, aggregateType, id, version, name, payload, e.OccurredAt, e.Metadata.CorrelationID, e.Metadata.CausationID, metadata, schemaVersion(e),
+		); err != nil {
+			if isUniqueViolation(err) {
+				return s.conflict(ctx, aggregateType, id, expectedVersion)
+			}
+			return err
+		}
+
+		if s.outbox {
+			if err := insertOutbox(ctx, tx, aggregateType, id, version, name, payload, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// unsaved splits the events into those that haven't been saved and those
+// that have, judging by their event IDs. Events without an ID are never
+// considered saved.
+func (s *postgresEventStore) unsaved(ctx context.Context, tx *sql.Tx, events []PersistedEvent) (unsaved, saved []PersistedEvent, err error) {
+	for _, e := range events {
+		if e.EventID == "" {
+			unsaved = append(unsaved, e)
+			continue
+		}
+
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM events WHERE event_id = $1)`, e.EventID).Scan(&exists); err != nil {
+			return nil, nil, err
+		}
+
+		if exists {
+			saved = append(saved, e)
+		} else {
+			unsaved = append(unsaved, e)
+		}
+	}
+	return unsaved, saved, nil
+}
+
+// conflict returns the error for a save against the expected version that was
+// rejected by the unique constraint, reading the version written by the
+// concurrent writer.
+func (s *postgresEventStore) conflict(ctx context.Context, aggregateType, id string, expectedVersion int) error {
+	var version int
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(sequence), 0) FROM events WHERE aggregate_type = $1 AND aggregate_id = $2`, aggregateType, id,
+	).Scan(&version); err != nil {
+		return err
+	}
+
+	return &ConcurrencyError{AggregateID: id, Expected: expectedVersion, Actual: version}
+}
+
+// conflictAll returns the error for a transaction saving the changes that
+// was rejected by the unique constraint, for the first aggregate written by a
+// concurrent writer.
+func (s *postgresEventStore) conflictAll(ctx context.Context, changes []AggregateChanges) error {
+	var err error
+	for _, c := range changes {
+		err = s.conflict(ctx, c.AggregateType, c.AggregateID, c.ExpectedVersion)
+
+		var cerr *ConcurrencyError
+		if !errors.As(err, &cerr) || cerr.Actual != cerr.Expected {
+			return err
+		}
+	}
+	return err
+}
+
+// Load returns the events for the aggregate in sequence order.
+func (s *postgresEventStore) Load(ctx context.Context, aggregateType, id string) ([]PersistedEvent, error) {
+	result, err := s.LoadFrom(ctx, aggregateType, id, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, ErrOrderNotFound
+	}
+
+	return result, nil
+}
+
+// LoadFrom returns the events for the aggregate with a sequence number greater
+// than afterSequence, in sequence order.
+func (s *postgresEventStore) LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.Load", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+		attribute.Int("sequence.after", afterSequence),
+	))
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+postgresColumns+` FROM events WHERE aggregate_type = $1 AND aggregate_id = $2 AND sequence > $3 ORDER BY sequence`, aggregateType, id, afterSequence,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		result = []PersistedEvent{}
+	}
+
+	return result, nil
+}
+
+// LoadPage returns up to limit events for the aggregate with a sequence number
+// greater than afterSequence, in sequence order, along with the cursor to pass
+// as afterSequence for the next page. The cursor is zero on the last page.
+func (s *postgresEventStore) LoadPage(ctx context.Context, aggregateType, id string, afterSequence, limit int) (_ []PersistedEvent, _ int, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadPage", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+		attribute.Int("sequence.after", afterSequence),
+		attribute.Int("page.limit", limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	// Read one more event than requested to tell whether more events follow.
+	query := `SELECT ` + postgresColumns + ` FROM events WHERE aggregate_type = $1 AND aggregate_id = $2 AND sequence > $3 ORDER BY sequence`
+	args := []interface{}{aggregateType, id, afterSequence}
+	if limit > 0 {
+		query += ` LIMIT $4`
+		args = append(args, limit+1)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, err := scanEvents(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if result == nil {
+		result = []PersistedEvent{}
+	}
+
+	events, next := page(result, limit)
+
+	return events, next, nil
+}
+
+// LoadMany returns the events for each of the aggregates in sequence order,
+// keyed by aggregate ID, using a single query. Aggregates without events are
+// absent from the result.
+func (s *postgresEventStore) LoadMany(ctx context.Context, aggregateType string, ids []string) (_ map[string][]PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadMany", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.Int("aggregate.count", len(ids)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	result := make(map[string][]PersistedEvent)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, aggregateType)
+	for i, id := range ids {
+		placeholders[i] = "$" + strconv.Itoa(i+2)
+		args = append(args, id)
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+postgresColumns+` FROM events WHERE aggregate_type = $1 AND aggregate_id IN (`+strings.Join(placeholders, ", ")+`) ORDER BY aggregate_id, sequence`, args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range events {
+		result[e.AggregateID] = append(result[e.AggregateID], e)
+	}
+
+	return result, nil
+}
+
+// LoadAll returns the events of all orders in the order they were saved.
+func (s *postgresEventStore) LoadAll(ctx context.Context) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadAll")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+postgresColumns+` FROM events ORDER BY global_sequence`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		result = []PersistedEvent{}
+	}
+
+	return result, nil
+}
+
+// LoadAllFrom returns up to limit events of all orders with a global position
+// greater than the given position, in the order they were saved. A limit of
+// zero or less returns all of them.
+func (s *postgresEventStore) LoadAllFrom(ctx context.Context, position int64, limit int) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadAllFrom", trace.WithAttributes(
+		attribute.Int64("position.after", position),
+		attribute.Int("page.limit", limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	query := `SELECT ` + postgresColumns + ` FROM events WHERE global_sequence > $1 ORDER BY global_sequence`
+	args := []interface{}{position}
+	if limit > 0 {
+		query += ` LIMIT $2`
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		result = []PersistedEvent{}
+	}
+
+	return result, nil
+}
+
+// Stream sends the events for the aggregate in sequence order on the returned
+// channel, which is closed once all of them have been sent, the context is
+// cancelled or an error occurred. The error, if any, is sent on the error
+// channel.
+func (s *postgresEventStore) Stream(ctx context.Context, aggregateType, id string) (<-chan PersistedEvent, <-chan error) {
+	return stream(ctx, s, aggregateType, id)
+}
+
+// postgresColumns lists the columns read by scanEvents, in order.
+const postgresColumns = `event_id, aggregate_type, aggregate_id, sequence, global_sequence, event_type, payload, occurred_at, correlation_id, causation_id, metadata, schema_version`
+
+// scanEvents reads and closes the rows of a query selecting postgresColumns.
+func scanEvents(rows *sql.Rows) ([]PersistedEvent, error) {
+	defer rows.Close()
+
+	var result []PersistedEvent
+	for rows.Next() {
+		var (
+			e        PersistedEvent
+			name     string
+			payload  []byte
+			metadata []byte
+			err      error
+		)
+		if err := rows.Scan(&e.EventID, &e.AggregateType, &e.AggregateID, &e.Sequence, &e.GlobalPosition, &name, &payload, &e.OccurredAt, &e.Metadata.CorrelationID, &e.Metadata.CausationID, &metadata, &e.SchemaVersion); err != nil {
+			return nil, err
+		}
+
+		if err := unmarshalMetadata(metadata, &e.Metadata); err != nil {
+			return nil, err
+		}
+
+		e.Event, e.SchemaVersion, err = decodeEvent(name, e.SchemaVersion, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// isUniqueViolation reports whether the driver error is a unique constraint
+// violation. Drivers such as lib/pq and pgx expose the SQLSTATE code through a
+// SQLState method.
+func isUniqueViolation(err error) bool {
+	var e interface{ SQLState() string }
+	return errors.As(err, &e) && e.SQLState() == pgUniqueViolation
+}
+
+// PostgresOption configures the PostgreSQL event store.
+type PostgresOption func(*postgresEventStore)
+
+// WithPostgresOutbox makes the event store enqueue the saved events to the
+// outbox table within the same transaction. Use NewPostgresOutbox to publish
+// them.
+func WithPostgresOutbox() PostgresOption {
+	return func(s *postgresEventStore) {
+		s.outbox = true
+	}
+}
+
+// NewPostgresEventStore returns a new event store persisting events to
+// PostgreSQL. The events table can be created using MigratePostgres.
+func NewPostgresEventStore(db *sql.DB, opts ...PostgresOption) EventStore {
+	s := &postgresEventStore{db: db}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertOutbox adds an encoded event to the outbox table.
+func insertOutbox(ctx context.Context, db execer, aggregateType, id string, sequence int, name string, payload []byte, e PersistedEvent) error {
+	metadata, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO outbox (event_id, aggregate_type, aggregate_id, sequence, event_type, payload, occurred_at, correlation_id, causation_id, metadata, schema_version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		e.EventID, aggregateType, id, sequence, name, payload, e.OccurredAt, e.Metadata.CorrelationID, e.Metadata.CausationID, metadata, schemaVersion(e),
+	)
+	return err
+}
+
+type postgresOutbox struct {
+	db *sql.DB
+}
+
+// Enqueue inserts the events into the outbox table within a single
+// transaction.
+func (o *postgresOutbox) Enqueue(ctx context.Context, events []PersistedEvent) error {
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, e := range events {
+		payload, name, err := MarshalEvent(e.Event)
+		if err != nil {
+			return err
+		}
+		if err := insertOutbox(ctx, tx, e.AggregateType, e.AggregateID, e.Sequence, name, payload, e); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Pending returns up to limit unpublished messages in the order they were
+// enqueued. A limit of zero or less returns all of them.
+func (o *postgresOutbox) Pending(ctx context.Context, limit int) ([]OutboxMessage, error) {
+	query := `SELECT id, event_id, aggregate_type, aggregate_id, sequence, event_type, payload, occurred_at, correlation_id, causation_id, metadata, schema_version FROM outbox WHERE published_at IS NULL ORDER BY id`
+
+	var args []interface{}
+	if limit > 0 {
+		query += ` LIMIT $1`
+		args = append(args, limit)
+	}
+
+	rows, err := o.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []OutboxMessage
+	for rows.Next() {
+		var (
+			m        OutboxMessage
+			name     string
+			payload  []byte
+			metadata []byte
+		)
+		if err := rows.Scan(&m.ID, &m.Event.EventID, &m.Event.AggregateType, &m.Event.AggregateID, &m.Event.Sequence, &name, &payload, &m.Event.OccurredAt, &m.Event.Metadata.CorrelationID, &m.Event.Metadata.CausationID, &metadata, &m.Event.SchemaVersion); err != nil {
+			return nil, err
+		}
+
+		if err := unmarshalMetadata(metadata, &m.Event.Metadata); err != nil {
+			return nil, err
+		}
+
+		m.Event.Event, m.Event.SchemaVersion, err = decodeEvent(name, m.Event.SchemaVersion, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, m)
+	}
+
+	return result, rows.Err()
+}
+
+// MarkPublished sets the time the messages were published.
+func (o *postgresOutbox) MarkPublished(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = id
+	}
+
+	_, err := o.db.ExecContext(ctx,
+		`UPDATE outbox SET published_at = now() WHERE id IN (`+strings.Join(placeholders, ", ")+`)`, args...,
+	)
+	return err
+}
+
+// NewPostgresOutbox returns a new outbox backed by the outbox table created by
+// MigratePostgres.
+func NewPostgresOutbox(db *sql.DB) Outbox {
+	return &postgresOutbox{db: db}
+}
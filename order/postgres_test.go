@@ -0,0 +1,151 @@
+//go:build postgres
+
+package order_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// openPostgres connects to the database given by POSTGRES_DSN, e.g. a
+// container started with
+//
+//	docker run --rm -p 5432:5432 -e POSTGRES_PASSWORD=postgres postgres
+func openPostgres(t *testing.T) *sql.DB {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := order.MigratePostgres(context.Background(), db); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := db.Exec(`TRUNCATE events`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return db
+}
+
+func TestPostgresEventStore(t *testing.T) {
+	repo := order.NewRepository(
+		order.NewPostgresEventStore(openPostgres(t)),
+	)
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+	}
+}
+
+func TestPostgresEventStoreConflict(t *testing.T) {
+	store := order.NewPostgresEventStore(openPostgres(t))
+
+	events := []order.PersistedEvent{{Event: order.Placed{OrderID: "ABC123"}}}
+
+	if err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, events)
+	if !errors.Is(err, order.ErrConcurrencyConflict) {
+		t.Errorf("expected: %v, got: %v", order.ErrConcurrencyConflict, err)
+	}
+
+	var cerr *order.ConcurrencyError
+	if !errors.As(err, &cerr) || cerr.Expected != 0 || cerr.Actual != 1 {
+		t.Errorf("unexpected error: %#v", err)
+	}
+}
+
+func TestPostgresEventStoreLoadMany(t *testing.T) {
+	store := order.NewPostgresEventStore(openPostgres(t))
+
+	for _, id := range []string{"ABC123", "XYZ789"} {
+		events := []order.PersistedEvent{{Event: order.Placed{OrderID: order.OrderID(id), Lines: testLines}}}
+		if err := store.Save(context.Background(), order.AggregateTypeOrder, id, 0, events); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	events, err := store.LoadMany(context.Background(), order.AggregateTypeOrder, []string{"ABC123", "XYZ789", "MISSING"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(events))
+	}
+
+	if got := events["XYZ789"][0].AggregateID; got != "XYZ789" {
+		t.Errorf("expected: %v, got: %v", "XYZ789", got)
+	}
+}
+
+func TestPostgresOutbox(t *testing.T) {
+	db := openPostgres(t)
+	if _, err := db.Exec(`TRUNCATE outbox`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	outbox := order.NewPostgresOutbox(db)
+
+	repo := order.NewRepository(order.NewPostgresEventStore(db, order.WithPostgresOutbox()))
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bus := order.NewEventBus()
+
+	var received []order.PersistedEvent
+	bus.Subscribe(func(e order.PersistedEvent) {
+		received = append(received, e)
+	})
+
+	publisher := order.NewOutboxPublisher(outbox, bus)
+
+	for i, want := range []int{1, 0} {
+		n, err := publisher.Publish(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != want {
+			t.Errorf("poll %d: expected: %v, got: %v", i, want, n)
+		}
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(received))
+	}
+
+	if _, ok := received[0].Event.(order.Placed); !ok {
+		t.Errorf("expected: %T, got: %T", order.Placed{}, received[0].Event)
+	}
+}
@@ -0,0 +1,589 @@
+package order
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// OrderSummary is the read model of an order.
+type OrderSummary struct {
+	ID          string
+	CustomerID  string
+	Status      Status
+	LineCount   int
+	TotalCents  int64
+	LastUpdated time.Time
+}
+
+// SummaryProjection maintains order summaries from the events of all orders.
+// It is safe for concurrent use by multiple goroutines.
+type SummaryProjection struct {
+	summaries *ViewStore[string, OrderSummary]
+	placedAt  *ViewStore[string, time.Time]
+
+	// mu serializes Apply and Rebuild, and guards the state used to update
+	// the summaries. The view stores guard themselves for readers.
+	mu        sync.Mutex
+	lines     map[string][]Line
+	discounts map[string]map[string]int64
+	sequences map[string]int
+}
+
+// NewSummaryProjection returns a new, empty summary projection.
+func NewSummaryProjection() *SummaryProjection {
+	return &SummaryProjection{
+		summaries: NewViewStore[string, OrderSummary](),
+		placedAt:  NewViewStore[string, time.Time](),
+		lines:     make(map[string][]Line),
+		discounts: make(map[string]map[string]int64),
+		sequences: make(map[string]int),
+	}
+}
+
+// Apply updates the summary of the order the event belongs to. Events that
+// are older than the last applied event of the order, such as events
+// delivered twice, are ignored.
+func (p *SummaryProjection) Apply(e PersistedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.apply(e)
+}
+
+func (p *SummaryProjection) apply(e PersistedEvent) {
+	if !isOrderEvent(e) {
+		return
+	}
+
+	if e.Sequence > 0 {
+		if e.Sequence <= p.sequences[e.AggregateID] {
+			return
+		}
+		p.sequences[e.AggregateID] = e.Sequence
+	}
+
+	s, _ := p.summaries.Get(e.AggregateID)
+	s.ID = e.AggregateID
+	s.LastUpdated = e.OccurredAt
+
+	switch evt := e.Event.(type) {
+	case Placed:
+		s.CustomerID = evt.CustomerID
+		s.Status = StatusPlaced
+		p.lines[e.AggregateID] = append([]Line(nil), evt.Lines...)
+		p.placedAt.Set(e.AggregateID, e.OccurredAt)
+	case LineAdded:
+		p.lines[e.AggregateID] = append(p.lines[e.AggregateID], evt.Line)
+	case LineRemoved:
+		p.lines[e.AggregateID] = removeProduct(p.lines[e.AggregateID], evt.ProductID)
+	case LineQuantityChanged:
+		p.lines[e.AggregateID] = changeQuantity(p.lines[e.AggregateID], evt.ProductID, evt.Quantity)
+	case DiscountApplied:
+		p.discounts[e.AggregateID] = addDiscount(p.discounts[e.AggregateID], evt)
+	case Activated:
+		s.Status = StatusActivated
+	case Cancelled:
+		s.Status = StatusCancelled
+	case Shipped:
+		s.Status = StatusShipped
+	case Delivered:
+		s.Status = StatusDelivered
+	case Held:
+		s.Status = StatusHeld
+	case Expired:
+		s.Status = StatusExpired
+	case Reactivated:
+		s.Status = StatusActivated
+	}
+
+	s.LineCount = len(p.lines[e.AggregateID])
+	s.TotalCents = discountedTotal(p.lines[e.AggregateID], p.discounts[e.AggregateID])
+
+	p.summaries.Set(e.AggregateID, s)
+}
+
+// Rebuild discards all summaries and recreates them from the events. The
+// summaries are recreated aside and then replaced at once, so readers don't
+// see them half rebuilt.
+func (p *SummaryProjection) Rebuild(events []PersistedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rebuilt := NewSummaryProjection()
+	for _, e := range events {
+		rebuilt.apply(e)
+	}
+
+	p.lines = rebuilt.lines
+	p.discounts = rebuilt.discounts
+	p.sequences = rebuilt.sequences
+
+	p.summaries.Replace(rebuilt.summaries.All())
+	p.placedAt.Replace(rebuilt.placedAt.All())
+}
+
+// Get returns the summary of an order.
+func (p *SummaryProjection) Get(id string) (OrderSummary, bool) {
+	return p.summaries.Get(id)
+}
+
+// List returns the summaries of all orders, ordered by ID.
+func (p *SummaryProjection) List() []OrderSummary {
+	all := p.summaries.All()
+
+	result := make([]OrderSummary, 0, len(all))
+	for _, s := range all {
+		result = append(result, s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result
+}
+
+// Page returns a page of the summaries of the orders matching the query,
+// ordered by the time they were placed and then by ID, along with the total
+// number of matching orders. A limit of zero or less returns all orders after
+// the offset.
+func (p *SummaryProjection) Page(q ListOrders) OrderList {
+	// The summaries are read first, since an order is placed before its
+	// summary is stored.
+	summaries := p.summaries.All()
+	placedAt := p.placedAt.All()
+
+	var matching []OrderSummary
+	for _, s := range summaries {
+		if q.Status == nil || s.Status == *q.Status {
+			matching = append(matching, s)
+		}
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		a, b := placedAt[matching[i].ID], placedAt[matching[j].ID]
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return matching[i].ID < matching[j].ID
+	})
+
+	start := q.Offset
+	if start < 0 {
+		start = 0
+	}
+	if start > len(matching) {
+		start = len(matching)
+	}
+
+	end := len(matching)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+
+	page := make([]OrderSummary, end-start)
+	copy(page, matching[start:end])
+
+	return OrderList{Orders: page, Total: len(matching)}
+}
+
+// isOrderEvent reports whether the event belongs to an order, since the
+// projections are keyed by aggregate ID alone and aggregates of other types
+// may share IDs with orders. Events without an aggregate type, such as events
+// saved before aggregate types were recorded, belong to orders.
+func isOrderEvent(e PersistedEvent) bool {
+	return e.AggregateType == "" || e.AggregateType == AggregateTypeOrder
+}
+
+// addDiscount returns the discounts of an order, keyed by code, with the
+// applied discount added. Applying the same code again has no effect.
+func addDiscount(discounts map[string]int64, e DiscountApplied) map[string]int64 {
+	if discounts == nil {
+		discounts = make(map[string]int64)
+	}
+	discounts[e.Code] = e.AmountCents
+	return discounts
+}
+
+// statusOf returns the status an order has after the event, if the event
+// changes the status.
+func statusOf(e Event) (Status, bool) {
+	switch e.(type) {
+	case Placed:
+		return StatusPlaced, true
+	case Activated:
+		return StatusActivated, true
+	case Cancelled:
+		return StatusCancelled, true
+	case Shipped:
+		return StatusShipped, true
+	case Delivered:
+		return StatusDelivered, true
+	case Held:
+		return StatusHeld, true
+	case Expired:
+		return StatusExpired, true
+	case Reactivated:
+		return StatusActivated, true
+	}
+	return 0, false
+}
+
+// StatusCountProjection maintains the number of orders in each status from
+// the events of all orders. It is safe for concurrent use by multiple
+// goroutines.
+type StatusCountProjection struct {
+	mu       sync.RWMutex
+	counts   map[Status]int
+	statuses map[string]countedStatus
+}
+
+// countedStatus is the status an order is counted in, along with the sequence
+// number of the event that put it there.
+type countedStatus struct {
+	Status   Status
+	Sequence int
+}
+
+// NewStatusCountProjection returns a new, empty status count projection.
+func NewStatusCountProjection() *StatusCountProjection {
+	return &StatusCountProjection{
+		counts:   make(map[Status]int),
+		statuses: make(map[string]countedStatus),
+	}
+}
+
+// Apply moves the order the event belongs to from its previous status to the
+// new one. Events that don't change the status of the order, or that are older
+// than the last counted event of the order, are ignored.
+func (p *StatusCountProjection) Apply(e PersistedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.apply(e)
+}
+
+func (p *StatusCountProjection) apply(e PersistedEvent) {
+	status, ok := statusOf(e.Event)
+	if !ok || !isOrderEvent(e) {
+		return
+	}
+
+	prev, known := p.statuses[e.AggregateID]
+	if known && e.Sequence <= prev.Sequence {
+		return
+	}
+
+	// An order is only counted once it has been placed.
+	if !known && status != StatusPlaced {
+		return
+	}
+
+	if known {
+		p.counts[prev.Status]--
+		if p.counts[prev.Status] == 0 {
+			delete(p.counts, prev.Status)
+		}
+	}
+
+	p.counts[status]++
+	p.statuses[e.AggregateID] = countedStatus{Status: status, Sequence: e.Sequence}
+}
+
+// Rebuild discards all counts and recreates them from the events.
+func (p *StatusCountProjection) Rebuild(events []PersistedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.counts = make(map[Status]int)
+	p.statuses = make(map[string]countedStatus)
+
+	for _, e := range events {
+		p.apply(e)
+	}
+}
+
+// Counts returns the number of orders in each status. Statuses without any
+// orders are absent.
+func (p *StatusCountProjection) Counts() map[Status]int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make(map[Status]int, len(p.counts))
+	for s, n := range p.counts {
+		result[s] = n
+	}
+
+	return result
+}
+
+// RevenueProjection maintains the total revenue of the orders that have been
+// activated, less the orders that were cancelled afterwards. It is safe for
+// concurrent use by multiple goroutines.
+type RevenueProjection struct {
+	mu        sync.RWMutex
+	total     int64
+	lines     map[string][]Line
+	discounts map[string]map[string]int64
+	counted   map[string]int64
+	voided    map[string]bool
+}
+
+// NewRevenueProjection returns a new, empty revenue projection.
+func NewRevenueProjection() *RevenueProjection {
+	return &RevenueProjection{
+		lines:     make(map[string][]Line),
+		discounts: make(map[string]map[string]int64),
+		counted:   make(map[string]int64),
+		voided:    make(map[string]bool),
+	}
+}
+
+// Apply updates the revenue from the event. An order is counted at most once,
+// with the discounted total of its lines when it was activated, and
+// subtracted at most once if cancelled after being counted.
+func (p *RevenueProjection) Apply(e PersistedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.apply(e)
+}
+
+func (p *RevenueProjection) apply(e PersistedEvent) {
+	if !isOrderEvent(e) {
+		return
+	}
+
+	id := e.AggregateID
+
+	switch evt := e.Event.(type) {
+	case Placed:
+		if _, ok := p.lines[id]; !ok {
+			p.lines[id] = append([]Line(nil), evt.Lines...)
+		}
+	case LineAdded:
+		if _, ok := p.counted[id]; !ok {
+			p.lines[id] = append(p.lines[id], evt.Line)
+		}
+	case LineRemoved:
+		if _, ok := p.counted[id]; !ok {
+			p.lines[id] = removeProduct(p.lines[id], evt.ProductID)
+		}
+	case LineQuantityChanged:
+		if _, ok := p.counted[id]; !ok {
+			p.lines[id] = changeQuantity(p.lines[id], evt.ProductID, evt.Quantity)
+		}
+	case DiscountApplied:
+		if _, ok := p.counted[id]; !ok {
+			p.discounts[id] = addDiscount(p.discounts[id], evt)
+		}
+	case Activated:
+		if _, ok := p.counted[id]; ok {
+			return
+		}
+		amount := discountedTotal(p.lines[id], p.discounts[id])
+		p.counted[id] = amount
+		p.total += amount
+	case Cancelled:
+		amount, ok := p.counted[id]
+		if !ok || p.voided[id] {
+			return
+		}
+		p.voided[id] = true
+		p.total -= amount
+	}
+}
+
+// Rebuild discards the revenue and recreates it from the events.
+func (p *RevenueProjection) Rebuild(events []PersistedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.total = 0
+	p.lines = make(map[string][]Line)
+	p.discounts = make(map[string]map[string]int64)
+	p.counted = make(map[string]int64)
+	p.voided = make(map[string]bool)
+
+	for _, e := range events {
+		p.apply(e)
+	}
+}
+
+// TotalRevenue returns the total revenue in cents.
+func (p *RevenueProjection) TotalRevenue() int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.total
+}
+
+// CustomerOrdersProjection maintains the order summaries of each customer
+// from the events of all orders. It is safe for concurrent use by multiple
+// goroutines.
+type CustomerOrdersProjection struct {
+	mu        sync.RWMutex
+	summaries *SummaryProjection
+	customers map[string][]string
+	placedAt  map[string]time.Time
+}
+
+// NewCustomerOrdersProjection returns a new, empty customer orders projection.
+func NewCustomerOrdersProjection() *CustomerOrdersProjection {
+	return &CustomerOrdersProjection{
+		summaries: NewSummaryProjection(),
+		customers: make(map[string][]string),
+		placedAt:  make(map[string]time.Time),
+	}
+}
+
+// Apply updates the summary of the order the event belongs to. Orders are
+// assigned to the customer they were placed for.
+func (p *CustomerOrdersProjection) Apply(e PersistedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.apply(e)
+}
+
+func (p *CustomerOrdersProjection) apply(e PersistedEvent) {
+	if !isOrderEvent(e) {
+		return
+	}
+
+	_, known := p.summaries.Get(e.AggregateID)
+
+	p.summaries.Apply(e)
+
+	evt, ok := e.Event.(Placed)
+	if !ok || known {
+		return
+	}
+
+	p.placedAt[e.AggregateID] = e.OccurredAt
+
+	// Keep the orders of the customer sorted by placement time, with orders
+	// placed at the same time in the order they were applied.
+	ids := p.customers[evt.CustomerID]
+	i := sort.Search(len(ids), func(i int) bool {
+		return p.placedAt[ids[i]].After(e.OccurredAt)
+	})
+
+	ids = append(ids, "")
+	copy(ids[i+1:], ids[i:])
+	ids[i] = e.AggregateID
+
+	p.customers[evt.CustomerID] = ids
+}
+
+// Rebuild discards all summaries and recreates them from the events.
+func (p *CustomerOrdersProjection) Rebuild(events []PersistedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.summaries = NewSummaryProjection()
+	p.customers = make(map[string][]string)
+	p.placedAt = make(map[string]time.Time)
+
+	for _, e := range events {
+		p.apply(e)
+	}
+}
+
+// OrdersFor returns the summaries of the orders placed for the customer, in
+// the order they were placed.
+func (p *CustomerOrdersProjection) OrdersFor(customerID string) []OrderSummary {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ids := p.customers[customerID]
+
+	result := make([]OrderSummary, 0, len(ids))
+	for _, id := range ids {
+		s, _ := p.summaries.Get(id)
+		result = append(result, s)
+	}
+
+	return result
+}
+
+// StatusChange is a transition of an order to a status, at the time the event
+// causing it occurred.
+type StatusChange struct {
+	Status Status
+	At     time.Time
+}
+
+// StatusHistoryProjection maintains the chronological status changes of each
+// order from the events of all orders. It is safe for concurrent use by
+// multiple goroutines.
+type StatusHistoryProjection struct {
+	mu        sync.RWMutex
+	histories map[string][]StatusChange
+	sequences map[string]int
+}
+
+// NewStatusHistoryProjection returns a new, empty status history projection.
+func NewStatusHistoryProjection() *StatusHistoryProjection {
+	return &StatusHistoryProjection{
+		histories: make(map[string][]StatusChange),
+		sequences: make(map[string]int),
+	}
+}
+
+// Apply appends the status change caused by the event to the history of the
+// order. Events that don't change the status of the order, or that are older
+// than the last applied event of the order, are ignored.
+func (p *StatusHistoryProjection) Apply(e PersistedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.apply(e)
+}
+
+func (p *StatusHistoryProjection) apply(e PersistedEvent) {
+	status, ok := statusOf(e.Event)
+	if !ok || !isOrderEvent(e) {
+		return
+	}
+
+	if e.Sequence <= p.sequences[e.AggregateID] && e.Sequence > 0 {
+		return
+	}
+	p.sequences[e.AggregateID] = e.Sequence
+
+	p.histories[e.AggregateID] = append(p.histories[e.AggregateID], StatusChange{
+		Status: status,
+		At:     e.OccurredAt,
+	})
+}
+
+// Rebuild discards all histories and recreates them from the events.
+func (p *StatusHistoryProjection) Rebuild(events []PersistedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.histories = make(map[string][]StatusChange)
+	p.sequences = make(map[string]int)
+
+	for _, e := range events {
+		p.apply(e)
+	}
+}
+
+// History returns the status changes of an order, oldest first.
+func (p *StatusHistoryProjection) History(id string) ([]StatusChange, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	history, ok := p.histories[id]
+	if !ok {
+		return nil, false
+	}
+
+	result := make([]StatusChange, len(history))
+	copy(result, history)
+
+	return result, true
+}
@@ -0,0 +1,358 @@
+package order_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestSummaryProjection(t *testing.T) {
+	placedAt := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+	activatedAt := placedAt.Add(time.Hour)
+
+	p := order.NewSummaryProjection()
+	p.Apply(order.PersistedEvent{
+		Event: order.Placed{OrderID: "ABC123", Lines: []order.Line{
+			{ProductID: "P1", Quantity: 2, UnitPrice: 250},
+			{ProductID: "P2", Quantity: 1, UnitPrice: 100},
+		}},
+		Sequence:    1,
+		AggregateID: "ABC123",
+		OccurredAt:  placedAt,
+	})
+	p.Apply(order.PersistedEvent{
+		Event:       order.Activated{OrderID: "ABC123"},
+		Sequence:    2,
+		AggregateID: "ABC123",
+		OccurredAt:  activatedAt,
+	})
+
+	s, ok := p.Get("ABC123")
+	if !ok {
+		t.Fatalf("expected summary for %v", "ABC123")
+	}
+
+	want := order.OrderSummary{
+		ID:          "ABC123",
+		Status:      order.StatusActivated,
+		LineCount:   2,
+		TotalCents:  600,
+		LastUpdated: activatedAt,
+	}
+
+	if s != want {
+		t.Errorf("expected: %+v, got: %+v", want, s)
+	}
+
+	if _, ok := p.Get("XYZ789"); ok {
+		t.Errorf("expected no summary for %v", "XYZ789")
+	}
+}
+
+func TestSummaryProjectionRebuild(t *testing.T) {
+	store := order.NewEventStore()
+	repo := order.NewRepository(store)
+
+	handler := order.NewCommandHandler(repo)
+	for _, id := range []string{"XYZ789", "ABC123"} {
+		if err := handler.Handle(context.Background(), order.Place{OrderID: order.OrderID(id), Lines: testLines}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var events []order.PersistedEvent
+	for _, id := range []string{"ABC123", "XYZ789"} {
+		stream, err := store.Load(context.Background(), order.AggregateTypeOrder, id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		events = append(events, stream...)
+	}
+
+	p := order.NewSummaryProjection()
+	p.Apply(order.PersistedEvent{Event: order.Placed{OrderID: "STALE1"}, AggregateID: "STALE1"})
+	p.Rebuild(events)
+
+	list := p.List()
+	if len(list) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(list))
+	}
+
+	if list[0].ID != "ABC123" || list[0].Status != order.StatusActivated {
+		t.Errorf("unexpected summary: %+v", list[0])
+	}
+	if list[1].ID != "XYZ789" || list[1].Status != order.StatusPlaced {
+		t.Errorf("unexpected summary: %+v", list[1])
+	}
+}
+
+func TestSummaryProjectionRedelivery(t *testing.T) {
+	events := []order.PersistedEvent{
+		{Event: order.Placed{OrderID: "ABC123", Lines: testLines}, AggregateID: "ABC123", Sequence: 1},
+		{Event: order.LineAdded{OrderID: "ABC123", Line: order.Line{ProductID: "P2", Quantity: 1, UnitPrice: 100}}, AggregateID: "ABC123", Sequence: 2},
+	}
+
+	p := order.NewSummaryProjection()
+	for _, e := range append(events, events...) {
+		p.Apply(e)
+	}
+
+	s, ok := p.Get("ABC123")
+	if !ok {
+		t.Fatalf("expected summary for %v", "ABC123")
+	}
+
+	if s.LineCount != 2 {
+		t.Errorf("expected: %v, got: %v", 2, s.LineCount)
+	}
+}
+
+func TestStatusCountProjection(t *testing.T) {
+	bus := order.NewEventBus()
+
+	p := order.NewStatusCountProjection()
+	bus.Subscribe(p.Apply)
+
+	handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore(), order.WithEventBus(bus)))
+
+	commands := []interface{}{
+		order.Place{OrderID: "A", Lines: testLines},
+		order.Place{OrderID: "B", Lines: testLines},
+		order.Place{OrderID: "C", Lines: testLines},
+		order.Place{OrderID: "D", Lines: testLines},
+		order.Place{OrderID: "E", Lines: testLines},
+		order.Activate{OrderID: "B"},
+		order.Activate{OrderID: "C"},
+		order.Activate{OrderID: "D"},
+		order.Activate{OrderID: "E"},
+		order.Cancel{OrderID: "C"},
+		order.Ship{OrderID: "D"},
+		order.Ship{OrderID: "E"},
+		order.Deliver{OrderID: "E"},
+	}
+
+	for _, c := range commands {
+		if err := handler.Handle(context.Background(), c); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	want := map[order.Status]int{
+		order.StatusPlaced:    1,
+		order.StatusActivated: 1,
+		order.StatusCancelled: 1,
+		order.StatusShipped:   1,
+		order.StatusDelivered: 1,
+	}
+
+	if got := p.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected: %v, got: %v", want, got)
+	}
+}
+
+func TestStatusCountProjectionRedelivery(t *testing.T) {
+	events := []order.PersistedEvent{
+		{Event: order.Placed{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 1},
+		{Event: order.Activated{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 2},
+	}
+
+	p := order.NewStatusCountProjection()
+	for _, e := range append(events, events...) {
+		p.Apply(e)
+	}
+
+	want := map[order.Status]int{order.StatusActivated: 1}
+
+	if got := p.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected: %v, got: %v", want, got)
+	}
+
+	p.Rebuild(events[:1])
+
+	want = map[order.Status]int{order.StatusPlaced: 1}
+
+	if got := p.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected: %v, got: %v", want, got)
+	}
+}
+
+func TestRevenueProjection(t *testing.T) {
+	lines := []order.Line{
+		{ProductID: "P1", Quantity: 2, UnitPrice: 250},
+		{ProductID: "P2", Quantity: 1, UnitPrice: 100},
+	}
+
+	bus := order.NewEventBus()
+
+	p := order.NewRevenueProjection()
+	bus.Subscribe(p.Apply)
+
+	handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore(), order.WithEventBus(bus)))
+
+	var tests = []struct {
+		command interface{}
+		want    int64
+	}{
+		{command: order.Place{OrderID: "ABC123", Lines: lines}, want: 0},
+		{command: order.Activate{OrderID: "ABC123"}, want: 600},
+		{command: order.Place{OrderID: "XYZ789", Lines: testLines}, want: 600},
+		{command: order.Activate{OrderID: "XYZ789"}, want: 700},
+		{command: order.Cancel{OrderID: "ABC123"}, want: 100},
+		{command: order.Place{OrderID: "DEF456", Lines: lines}, want: 100},
+		{command: order.Cancel{OrderID: "DEF456"}, want: 100},
+	}
+
+	for _, tt := range tests {
+		if err := handler.Handle(context.Background(), tt.command); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := p.TotalRevenue(); got != tt.want {
+			t.Errorf("%T: expected: %v, got: %v", tt.command, tt.want, got)
+		}
+	}
+}
+
+func TestRevenueProjectionReplay(t *testing.T) {
+	events := []order.PersistedEvent{
+		{Event: order.Placed{OrderID: "ABC123", Lines: testLines}, AggregateID: "ABC123", Sequence: 1},
+		{Event: order.Activated{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 2},
+		{Event: order.Placed{OrderID: "XYZ789", Lines: testLines}, AggregateID: "XYZ789", Sequence: 1},
+		{Event: order.Activated{OrderID: "XYZ789"}, AggregateID: "XYZ789", Sequence: 2},
+		{Event: order.Cancelled{OrderID: "XYZ789"}, AggregateID: "XYZ789", Sequence: 3},
+	}
+
+	p := order.NewRevenueProjection()
+	for _, e := range append(events, events...) {
+		p.Apply(e)
+	}
+
+	if got := p.TotalRevenue(); got != 100 {
+		t.Errorf("expected: %v, got: %v", 100, got)
+	}
+
+	p.Rebuild(events)
+	p.Rebuild(events)
+
+	if got := p.TotalRevenue(); got != 100 {
+		t.Errorf("expected: %v, got: %v", 100, got)
+	}
+}
+
+func TestCustomerOrdersProjection(t *testing.T) {
+	placedAt := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	p := order.NewCustomerOrdersProjection()
+
+	placed := []struct {
+		orderID    string
+		customerID string
+		placedAt   time.Time
+	}{
+		{orderID: "O1", customerID: "alice", placedAt: placedAt},
+		{orderID: "O2", customerID: "bob", placedAt: placedAt.Add(time.Minute)},
+		{orderID: "O3", customerID: "alice", placedAt: placedAt.Add(3 * time.Minute)},
+		{orderID: "O4", customerID: "bob", placedAt: placedAt.Add(4 * time.Minute)},
+		// Applied out of order, but placed before O3.
+		{orderID: "O5", customerID: "alice", placedAt: placedAt.Add(2 * time.Minute)},
+	}
+
+	for _, o := range placed {
+		p.Apply(order.PersistedEvent{
+			Event:       order.Placed{OrderID: order.OrderID(o.orderID), CustomerID: o.customerID, Lines: testLines},
+			Sequence:    1,
+			AggregateID: o.orderID,
+			OccurredAt:  o.placedAt,
+		})
+	}
+
+	p.Apply(order.PersistedEvent{
+		Event:       order.Activated{OrderID: "O3"},
+		Sequence:    2,
+		AggregateID: "O3",
+		OccurredAt:  placedAt.Add(time.Hour),
+	})
+
+	var tests = []struct {
+		customerID string
+		want       []string
+	}{
+		{customerID: "alice", want: []string{"O1", "O5", "O3"}},
+		{customerID: "bob", want: []string{"O2", "O4"}},
+		{customerID: "carol", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		orders := p.OrdersFor(tt.customerID)
+
+		got := make([]string, len(orders))
+		for i, s := range orders {
+			got[i] = s.ID
+			if s.CustomerID != tt.customerID {
+				t.Errorf("expected: %v, got: %v", tt.customerID, s.CustomerID)
+			}
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expected: %v, got: %v", tt.want, got)
+		}
+	}
+
+	if got := p.OrdersFor("alice")[2].Status; got != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, got)
+	}
+}
+
+func TestProjectionsIgnoreOtherAggregateTypes(t *testing.T) {
+	placedAt := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	events := []order.PersistedEvent{
+		{Event: registered{CustomerID: "ABC123", Name: "Alice"}, AggregateType: "customer", AggregateID: "ABC123", Sequence: 1, OccurredAt: placedAt},
+		{Event: order.Placed{OrderID: "ABC123", Lines: testLines}, AggregateType: order.AggregateTypeOrder, AggregateID: "ABC123", Sequence: 1, OccurredAt: placedAt},
+		{Event: order.Activated{OrderID: "ABC123"}, AggregateType: order.AggregateTypeOrder, AggregateID: "ABC123", Sequence: 2, OccurredAt: placedAt},
+		{Event: registered{CustomerID: "XYZ789", Name: "Bob"}, AggregateType: "customer", AggregateID: "XYZ789", Sequence: 1, OccurredAt: placedAt},
+		{Event: order.Cancelled{OrderID: "ABC123"}, AggregateType: "customer", AggregateID: "ABC123", Sequence: 3, OccurredAt: placedAt.Add(time.Hour)},
+	}
+
+	summaries := order.NewSummaryProjection()
+	counts := order.NewStatusCountProjection()
+	revenue := order.NewRevenueProjection()
+	history := order.NewStatusHistoryProjection()
+
+	for _, e := range events {
+		summaries.Apply(e)
+		counts.Apply(e)
+		revenue.Apply(e)
+		history.Apply(e)
+	}
+
+	want := order.OrderSummary{
+		ID:          "ABC123",
+		Status:      order.StatusActivated,
+		LineCount:   1,
+		TotalCents:  testLines[0].UnitPrice * int64(testLines[0].Quantity),
+		LastUpdated: placedAt,
+	}
+
+	if got := summaries.List(); len(got) != 1 || got[0] != want {
+		t.Errorf("expected: %+v, got: %+v", []order.OrderSummary{want}, got)
+	}
+
+	if got := counts.Counts(); !reflect.DeepEqual(got, map[order.Status]int{order.StatusActivated: 1}) {
+		t.Errorf("expected: %v, got: %v", map[order.Status]int{order.StatusActivated: 1}, got)
+	}
+
+	if got := revenue.TotalRevenue(); got != want.TotalCents {
+		t.Errorf("expected: %v, got: %v", want.TotalCents, got)
+	}
+
+	if got, _ := history.History("ABC123"); len(got) != 2 || got[1].Status != order.StatusActivated {
+		t.Errorf("expected placed and activated, got: %v", got)
+	}
+}
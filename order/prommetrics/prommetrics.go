@@ -0,0 +1,63 @@
+// Package prommetrics provides a Prometheus implementation of order.Metrics.
+package prommetrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// Metrics exposes the handled commands and saved events as Prometheus
+// metrics.
+type Metrics struct {
+	commands *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	events   *prometheus.CounterVec
+}
+
+var _ order.Metrics = (*Metrics)(nil)
+
+// New returns metrics registered with the given registerer.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cqrs_commands_total",
+			Help: "Number of handled commands.",
+		}, []string{"command", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cqrs_command_duration_seconds",
+			Help:    "Time spent handling commands.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"command"}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cqrs_events_saved_total",
+			Help: "Number of saved events.",
+		}, []string{"aggregate_type"}),
+	}
+
+	for _, c := range []prometheus.Collector{m.commands, m.duration, m.events} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// ObserveCommand counts the command and records the time it took to handle.
+func (m *Metrics) ObserveCommand(name string, d time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+
+	m.commands.WithLabelValues(name, status).Inc()
+	m.duration.WithLabelValues(name).Observe(d.Seconds())
+}
+
+// CountEvents adds the number of saved events for the aggregate type.
+func (m *Metrics) CountEvents(aggregateType string, n int) {
+	m.events.WithLabelValues(aggregateType).Add(float64(n))
+}
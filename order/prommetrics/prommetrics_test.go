@@ -0,0 +1,54 @@
+package prommetrics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/marcusolsson/cqrs-example/order"
+	"github.com/marcusolsson/cqrs-example/order/prommetrics"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	metrics, err := prommetrics.New(reg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	repo := order.NewRepository(order.NewEventStore(), order.WithRepositoryMetrics(metrics))
+	handler := order.NewCommandHandler(repo, order.WithMetrics(metrics))
+
+	lines := []order.Line{{ProductID: "P1", Quantity: 1, UnitPrice: 100}}
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: lines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := testutil.CollectAndCount(reg, "cqrs_command_duration_seconds"); got != 1 {
+		t.Errorf("expected: %v, got: %v", 1, got)
+	}
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			if c := m.GetCounter(); c != nil {
+				values[mf.GetName()] += c.GetValue()
+			}
+		}
+	}
+
+	if got := values["cqrs_commands_total"]; got != 1 {
+		t.Errorf("expected: %v, got: %v", 1, got)
+	}
+	if got := values["cqrs_events_saved_total"]; got != 1 {
+		t.Errorf("expected: %v, got: %v", 1, got)
+	}
+}
@@ -0,0 +1,103 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownQuery is returned when a query handler receives a query it does
+// not know how to handle.
+var ErrUnknownQuery = errors.New("unknown query")
+
+// Query is the type of all queries handled by a QueryHandler.
+type Query interface{}
+
+// GetOrder represents a query for the summary of an order.
+type GetOrder struct {
+	OrderID string
+}
+
+// GetOrderHistory represents a query for the status changes of an order, in
+// the order they happened.
+type GetOrderHistory struct {
+	OrderID string
+}
+
+// ListOrders represents a query for a page of order summaries, ordered by the
+// time the orders were placed and then by ID. A nil status matches orders in
+// any status, and a limit of zero or less returns all orders after the offset.
+type ListOrders struct {
+	Status *Status
+	Limit  int
+	Offset int
+}
+
+// OrderList is the result of a ListOrders query. Total is the number of orders
+// matching the query, across all pages.
+type OrderList struct {
+	Orders []OrderSummary
+	Total  int
+}
+
+// QueryHandler defines an interface for handling order queries.
+type QueryHandler interface {
+	Handle(ctx context.Context, q interface{}) (interface{}, error)
+}
+
+type queryHandler struct {
+	Projection *SummaryProjection
+	History    *StatusHistoryProjection
+}
+
+func (h *queryHandler) Handle(ctx context.Context, q interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	switch qry := q.(type) {
+	case GetOrder:
+		s, ok := h.Projection.Get(qry.OrderID)
+		if !ok {
+			return nil, ErrOrderNotFound
+		}
+		return s, nil
+	case GetOrderHistory:
+		if h.History == nil {
+			return nil, fmt.Errorf("%w: %T", ErrUnknownQuery, q)
+		}
+		history, ok := h.History.History(qry.OrderID)
+		if !ok {
+			return nil, ErrOrderNotFound
+		}
+		return history, nil
+	case ListOrders:
+		return h.Projection.Page(qry), nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnknownQuery, q)
+	}
+}
+
+// QueryHandlerOption configures the default query handler.
+type QueryHandlerOption func(*queryHandler)
+
+// WithStatusHistory makes the query handler answer GetOrderHistory queries
+// from the projection.
+func WithStatusHistory(p *StatusHistoryProjection) QueryHandlerOption {
+	return func(h *queryHandler) {
+		h.History = p
+	}
+}
+
+// NewQueryHandler returns a new instance of the default query handler.
+func NewQueryHandler(projection *SummaryProjection, opts ...QueryHandlerOption) QueryHandler {
+	h := &queryHandler{
+		Projection: projection,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
@@ -0,0 +1,206 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestGetOrder(t *testing.T) {
+	p := order.NewSummaryProjection()
+	p.Apply(order.PersistedEvent{
+		Event:       order.Placed{OrderID: "ABC123", Lines: testLines},
+		Sequence:    1,
+		AggregateID: "ABC123",
+	})
+
+	handler := order.NewQueryHandler(p)
+
+	res, err := handler.Handle(context.Background(), order.GetOrder{OrderID: "ABC123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s, ok := res.(order.OrderSummary)
+	if !ok {
+		t.Fatalf("expected: %T, got: %T", order.OrderSummary{}, res)
+	}
+
+	if s.ID != "ABC123" {
+		t.Errorf("expected: %v, got: %v", "ABC123", s.ID)
+	}
+}
+
+func TestGetMissingOrder(t *testing.T) {
+	handler := order.NewQueryHandler(order.NewSummaryProjection())
+
+	_, err := handler.Handle(context.Background(), order.GetOrder{OrderID: "ABC123"})
+	if !errors.Is(err, order.ErrOrderNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrOrderNotFound, err)
+	}
+}
+
+func TestHandleUnknownQuery(t *testing.T) {
+	handler := order.NewQueryHandler(order.NewSummaryProjection())
+
+	type unsupported struct{}
+
+	_, err := handler.Handle(context.Background(), unsupported{})
+	if !errors.Is(err, order.ErrUnknownQuery) {
+		t.Errorf("expected: %v, got: %v", order.ErrUnknownQuery, err)
+	}
+}
+
+// tickingClock advances by a minute every time it is read.
+type tickingClock struct {
+	now time.Time
+}
+
+func (c *tickingClock) Now() time.Time {
+	c.now = c.now.Add(time.Minute)
+	return c.now
+}
+
+func TestGetOrderHistory(t *testing.T) {
+	start := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	bus := order.NewEventBus()
+	history := order.NewStatusHistoryProjection()
+	bus.Subscribe(history.Apply)
+
+	repo := order.NewRepository(order.NewEventStore(), order.WithEventBus(bus))
+	commands := order.NewCommandHandler(repo, order.WithClock(&tickingClock{now: start}))
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.AddLine{OrderID: "ABC123", Line: order.Line{ProductID: "P2", Quantity: 1, UnitPrice: 100}},
+		order.Activate{OrderID: "ABC123"},
+		order.Ship{OrderID: "ABC123"},
+	} {
+		if err := commands.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	handler := order.NewQueryHandler(order.NewSummaryProjection(), order.WithStatusHistory(history))
+
+	res, err := handler.Handle(context.Background(), order.GetOrderHistory{OrderID: "ABC123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []order.StatusChange{
+		{Status: order.StatusPlaced, At: start.Add(1 * time.Minute)},
+		{Status: order.StatusActivated, At: start.Add(3 * time.Minute)},
+		{Status: order.StatusShipped, At: start.Add(4 * time.Minute)},
+	}
+
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("expected: %v, got: %v", want, res)
+	}
+
+	if _, err := handler.Handle(context.Background(), order.GetOrderHistory{OrderID: "XYZ789"}); !errors.Is(err, order.ErrOrderNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrOrderNotFound, err)
+	}
+}
+
+func TestListOrders(t *testing.T) {
+	start := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	bus := order.NewEventBus()
+	projection := order.NewSummaryProjection()
+	bus.Subscribe(projection.Apply)
+
+	repo := order.NewRepository(order.NewEventStore(), order.WithEventBus(bus))
+	commands := order.NewCommandHandler(repo, order.WithClock(&tickingClock{now: start}))
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "XYZ789", Lines: testLines},
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.Place{OrderID: "MNO456", Lines: testLines},
+		order.Place{OrderID: "DEF012", Lines: testLines},
+		order.Activate{OrderID: "DEF012"},
+		order.Activate{OrderID: "XYZ789"},
+	} {
+		if err := commands.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	activated := order.StatusActivated
+	placed := order.StatusPlaced
+
+	handler := order.NewQueryHandler(projection)
+
+	for _, tt := range []struct {
+		name      string
+		query     order.ListOrders
+		want      []string
+		wantTotal int
+	}{
+		{
+			name:      "all",
+			query:     order.ListOrders{},
+			want:      []string{"XYZ789", "ABC123", "MNO456", "DEF012"},
+			wantTotal: 4,
+		},
+		{
+			name:      "activated",
+			query:     order.ListOrders{Status: &activated},
+			want:      []string{"XYZ789", "DEF012"},
+			wantTotal: 2,
+		},
+		{
+			name:      "placed",
+			query:     order.ListOrders{Status: &placed, Limit: 1},
+			want:      []string{"ABC123"},
+			wantTotal: 2,
+		},
+		{
+			name:      "window",
+			query:     order.ListOrders{Limit: 2, Offset: 1},
+			want:      []string{"ABC123", "MNO456"},
+			wantTotal: 4,
+		},
+		{
+			name:      "last page",
+			query:     order.ListOrders{Limit: 3, Offset: 3},
+			want:      []string{"DEF012"},
+			wantTotal: 4,
+		},
+		{
+			name:      "out of range",
+			query:     order.ListOrders{Limit: 2, Offset: 10},
+			want:      []string{},
+			wantTotal: 4,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := handler.Handle(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			list, ok := res.(order.OrderList)
+			if !ok {
+				t.Fatalf("expected: %T, got: %T", order.OrderList{}, res)
+			}
+
+			got := make([]string, len(list.Orders))
+			for i, s := range list.Orders {
+				got[i] = s.ID
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+			if list.Total != tt.wantTotal {
+				t.Errorf("expected: %v, got: %v", tt.wantTotal, list.Total)
+			}
+		})
+	}
+}
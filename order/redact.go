@@ -0,0 +1,155 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrRedactionNotSupported is returned when redacting events in an event
+// store that can't erase data from stored events.
+var ErrRedactionNotSupported = errors.New("event store does not support redaction")
+
+// RedactedValue replaces the string fields erased by a redaction. Fields of
+// other types are set to null, i.e. their zero value.
+const RedactedValue = "[redacted]"
+
+// Redaction records the erasure of fields from the events of an aggregate.
+type Redaction struct {
+	AggregateType string
+	AggregateID   string
+	Fields        []string
+	RedactedAt    time.Time
+}
+
+// RedactableEventStore is implemented by event stores that can erase data from
+// stored events, e.g. to honor a request to be forgotten.
+type RedactableEventStore interface {
+	EventStore
+
+	// Redact overwrites the named top-level fields in the payloads of every
+	// event of the aggregate, leaving the sequence and type of the events
+	// intact. Redacting fields that are already redacted has no effect.
+	Redact(ctx context.Context, aggregateType, id string, fields []string) error
+
+	// Redactions returns the redactions that changed any event, in the order
+	// they were made.
+	Redactions(ctx context.Context) ([]Redaction, error)
+}
+
+// redactPayload returns the payload with the fields redacted, and whether any
+// of them changed.
+func redactPayload(payload []byte, fields []string) ([]byte, bool, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil, false, err
+	}
+
+	tombstone, err := json.Marshal(RedactedValue)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var changed bool
+	for _, f := range fields {
+		v, ok := m[f]
+		if !ok {
+			continue
+		}
+
+		redacted := json.RawMessage("null")
+		if len(v) > 0 && v[0] == '"' {
+			redacted = tombstone
+		}
+
+		if string(v) != string(redacted) {
+			m[f] = redacted
+			changed = true
+		}
+	}
+
+	if !changed {
+		return payload, false, nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// Redact overwrites the fields in the events of the aggregate.
+func (s *eventStore) Redact(ctx context.Context, aggregateType, id string, fields []string) (err error) {
+	_, span := startSpan(ctx, "EventStore.Redact", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+	))
+	defer func() { endSpan(span, err) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found, changed bool
+	for i, e := range s.events {
+		if e.AggregateType != aggregateType || e.AggregateID != id {
+			continue
+		}
+		found = true
+
+		payload, name, err := MarshalEvent(e.Event)
+		if err != nil {
+			return err
+		}
+
+		redacted, ok, err := redactPayload(payload, fields)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		event, err := UnmarshalEvent(name, redacted)
+		if err != nil {
+			return err
+		}
+
+		s.events[i].Event = event
+		changed = true
+	}
+
+	if !found {
+		return ErrOrderNotFound
+	}
+
+	if changed {
+		redactedFields := make([]string, len(fields))
+		copy(redactedFields, fields)
+
+		s.redactions = append(s.redactions, Redaction{
+			AggregateType: aggregateType,
+			AggregateID:   id,
+			Fields:        redactedFields,
+			RedactedAt:    time.Now(),
+		})
+	}
+
+	return nil
+}
+
+// Redactions returns the redactions made to the store.
+func (s *eventStore) Redactions(ctx context.Context) ([]Redaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Redaction, len(s.redactions))
+	copy(result, s.redactions)
+
+	return result, nil
+}
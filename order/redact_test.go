@@ -0,0 +1,96 @@
+package order_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestRedact(t *testing.T) {
+	sqliteStore, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	stores := map[string]order.EventStore{
+		"memory": order.NewEventStore(),
+		"sqlite": sqliteStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			repo := order.NewRepository(store)
+			handler := order.NewCommandHandler(repo)
+
+			for _, cmd := range []interface{}{
+				order.Place{OrderID: "ABC123", CustomerID: "C42", Lines: testLines},
+				order.Activate{OrderID: "ABC123"},
+			} {
+				if err := handler.Handle(ctx, cmd); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			redactable := store.(order.RedactableEventStore)
+
+			// Redacting twice has the same effect as redacting once.
+			for i := 0; i < 2; i++ {
+				if err := redactable.Redact(ctx, order.AggregateTypeOrder, "ABC123", []string{"CustomerID"}); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			events, err := store.Load(ctx, order.AggregateTypeOrder, "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(events) != 2 {
+				t.Fatalf("expected: %v, got: %v", 2, len(events))
+			}
+
+			placed, ok := events[0].Event.(order.Placed)
+			if !ok {
+				t.Fatalf("expected: %T, got: %T", order.Placed{}, events[0].Event)
+			}
+			if placed.CustomerID != order.RedactedValue {
+				t.Errorf("expected: %v, got: %v", order.RedactedValue, placed.CustomerID)
+			}
+			if !reflect.DeepEqual(placed.Lines, testLines) {
+				t.Errorf("expected: %v, got: %v", testLines, placed.Lines)
+			}
+
+			for i, e := range events {
+				if e.Sequence != i+1 {
+					t.Errorf("expected: %v, got: %v", i+1, e.Sequence)
+				}
+			}
+
+			o, err := repo.Load(ctx, "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if o.Status != order.StatusActivated {
+				t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+			}
+
+			redactions, err := redactable.Redactions(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(redactions) != 1 {
+				t.Fatalf("expected: %v, got: %v", 1, len(redactions))
+			}
+			if r := redactions[0]; r.AggregateID != "ABC123" || !reflect.DeepEqual(r.Fields, []string{"CustomerID"}) {
+				t.Errorf("expected: %v, got: %v", "ABC123 [CustomerID]", r)
+			}
+		})
+	}
+}
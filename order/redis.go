@@ -0,0 +1,369 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redisGlobalKey holds the last global position assigned.
+const redisGlobalKey = "events:global"
+
+// redisSaveScript appends the events to the list of the aggregate, provided
+// that the version of the aggregate matches the expected version. Events
+// whose IDs are in the set of saved event IDs of the aggregate are left out,
+// and saving only such events succeeds without checking the version. The
+// global position and the sequence number are prepended to each of the JSON
+// encoded records. It returns the new version followed by the IDs of the
+// events left out, or only the current version plus one, negated, if the
+// version didn't match.
+//
+// KEYS: events list, version, global position, event IDs set
+// ARGV: expected version, pairs of event ID and record...
+var redisSaveScript = redis.NewScript(`
+local version = tonumber(redis.call('GET', KEYS[2]) or '0')
+local unsaved, skipped = {}, {}
+for i = 2, #ARGV, 2 do
+	if ARGV[i] ~= '' and redis.call('SISMEMBER', KEYS[4], ARGV[i]) == 1 then
+		skipped[#skipped + 1] = ARGV[i]
+	else
+		unsaved[#unsaved + 1] = i
+	end
+end
+if #skipped > 0 and #unsaved == 0 then
+	return {version, unpack(skipped)}
+end
+if version ~= tonumber(ARGV[1]) then
+	return {-(version + 1)}
+end
+for _, i in ipairs(unsaved) do
+	version = version + 1
+	local global = redis.call('INCR', KEYS[3])
+	redis.call('RPUSH', KEYS[1], '{"global_sequence":' .. global .. ',"sequence":' .. version .. ',' .. string.sub(ARGV[i + 1], 2))
+	if ARGV[i] ~= '' then
+		redis.call('SADD', KEYS[4], ARGV[i])
+	end
+end
+redis.call('SET', KEYS[2], version)
+return {version, unpack(skipped)}
+`)
+
+// redisRecord is the JSON encoding of a persisted event in Redis. The global
+// and aggregate sequence numbers are added by redisSaveScript.
+type redisRecord struct {
+	GlobalPosition int64           `json:"global_sequence,omitempty"`
+	Sequence       int             `json:"sequence,omitempty"`
+	EventID        string          `json:"event_id,omitempty"`
+	Type           string          `json:"type"`
+	Payload        json.RawMessage `json:"payload"`
+	OccurredAt     time.Time       `json:"occurred_at"`
+	Metadata       Metadata        `json:"metadata"`
+	SchemaVersion  int             `json:"schema_version"`
+}
+
+type redisEventStore struct {
+	client *redis.Client
+}
+
+// redisEventsKey returns the key of the list holding the events of the
+// aggregate. The ID is the hash tag, keeping the keys of an aggregate together.
+func redisEventsKey(aggregateType, id string) string {
+	return "events:" + aggregateType + ":{" + id + "}"
+}
+
+func redisVersionKey(aggregateType, id string) string {
+	return "version:" + aggregateType + ":{" + id + "}"
+}
+
+// redisEventIDsKey returns the key of the set holding the IDs of the saved
+// events of the aggregate.
+func redisEventIDsKey(aggregateType, id string) string {
+	return "event-ids:" + aggregateType + ":{" + id + "}"
+}
+
+// parseRedisEventsKey returns the aggregate type and ID of a key returned by
+// redisEventsKey.
+func parseRedisEventsKey(key string) (aggregateType, id string) {
+	aggregateType, id, _ = strings.Cut(strings.TrimPrefix(key, "events:"), ":{")
+	return aggregateType, strings.TrimSuffix(id, "}")
+}
+
+// Save appends the events to the list of the aggregate using a Lua script,
+// which makes the version check and the append atomic.
+func (s *redisEventStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []PersistedEvent) (err error) {
+	ctx, span := startSpan(ctx, "EventStore.Save", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+	))
+	defer func() { endSpan(span, err) }()
+
+	args := make([]interface{}, 0, 2*len(events)+1)
+	args = append(args, expectedVersion)
+
+	for _, e := range events {
+		payload, name, err := MarshalEvent(e.Event)
+		if err != nil {
+			return err
+		}
+
+		rec, err := json.Marshal(redisRecord{
+			EventID:       e.EventID,
+			Type:          name,
+			Payload:       payload,
+			OccurredAt:    e.OccurredAt,
+			Metadata:      e.Metadata,
+			SchemaVersion: schemaVersion(e),
+		})
+		if err != nil {
+			return err
+		}
+
+		args = append(args, e.EventID, rec)
+	}
+
+	keys := []string{redisEventsKey(aggregateType, id), redisVersionKey(aggregateType, id), redisGlobalKey, redisEventIDsKey(aggregateType, id)}
+
+	result, err := redisSaveScript.Run(ctx, s.client, keys, args...).Slice()
+	if err != nil {
+		return err
+	}
+
+	if version, _ := result[0].(int64); version < 0 {
+		return &ConcurrencyError{AggregateID: id, Expected: expectedVersion, Actual: int(-version - 1)}
+	}
+
+	skipped := make(map[string]bool, len(result)-1)
+	for _, v := range result[1:] {
+		if eventID, ok := v.(string); ok {
+			skipped[eventID] = true
+		}
+	}
+
+	var saved []PersistedEvent
+	for _, e := range events {
+		if e.EventID != "" && skipped[e.EventID] {
+			saved = append(saved, e)
+		}
+	}
+	recordSkipped(ctx, saved)
+
+	return nil
+}
+
+// Load returns the events for the aggregate in sequence order.
+func (s *redisEventStore) Load(ctx context.Context, aggregateType, id string) ([]PersistedEvent, error) {
+	result, err := s.LoadFrom(ctx, aggregateType, id, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, ErrOrderNotFound
+	}
+
+	return result, nil
+}
+
+// LoadFrom returns the events for the aggregate with a sequence number greater
+// than afterSequence, in sequence order. Since the list of an aggregate holds
+// one event per sequence number, only the requested range is read.
+func (s *redisEventStore) LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.Load", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+		attribute.Int("sequence.after", afterSequence),
+	))
+	defer func() { endSpan(span, err) }()
+
+	recs, err := s.client.LRange(ctx, redisEventsKey(aggregateType, id), int64(afterSequence), -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeRedisRecords(aggregateType, id, recs)
+}
+
+// LoadPage returns up to limit events for the aggregate with a sequence number
+// greater than afterSequence, in sequence order, along with the cursor to pass
+// as afterSequence for the next page. The cursor is zero on the last page.
+func (s *redisEventStore) LoadPage(ctx context.Context, aggregateType, id string, afterSequence, limit int) (_ []PersistedEvent, _ int, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadPage", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+		attribute.Int("sequence.after", afterSequence),
+		attribute.Int("page.limit", limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	// Read one more event than requested to tell whether more events follow.
+	stop := int64(-1)
+	if limit > 0 {
+		stop = int64(afterSequence + limit)
+	}
+
+	recs, err := s.client.LRange(ctx, redisEventsKey(aggregateType, id), int64(afterSequence), stop).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, err := decodeRedisRecords(aggregateType, id, recs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events, next := page(result, limit)
+
+	return events, next, nil
+}
+
+// LoadMany returns the events for each of the aggregates in sequence order,
+// keyed by aggregate ID, using a single pipeline. Aggregates without events
+// are absent from the result.
+func (s *redisEventStore) LoadMany(ctx context.Context, aggregateType string, ids []string) (_ map[string][]PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadMany", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.Int("aggregate.count", len(ids)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	cmds := make([]*redis.StringSliceCmd, len(ids))
+
+	_, err = s.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = p.LRange(ctx, redisEventsKey(aggregateType, id), 0, -1)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]PersistedEvent)
+	for i, id := range ids {
+		events, err := decodeRedisRecords(aggregateType, id, cmds[i].Val())
+		if err != nil {
+			return nil, err
+		}
+		if len(events) > 0 {
+			result[id] = events
+		}
+	}
+
+	return result, nil
+}
+
+// LoadAll returns the events of all aggregates in the order they were saved.
+// The lists of all aggregates are read and merged by global position.
+func (s *redisEventStore) LoadAll(ctx context.Context) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadAll")
+	defer func() { endSpan(span, err) }()
+
+	ids := make(map[string][]string)
+
+	iter := s.client.Scan(ctx, 0, redisEventsKey("*", "*"), 0).Iterator()
+	for iter.Next(ctx) {
+		aggregateType, id := parseRedisEventsKey(iter.Val())
+		ids[aggregateType] = append(ids[aggregateType], id)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	result := []PersistedEvent{}
+	for aggregateType, ids := range ids {
+		histories, err := s.LoadMany(ctx, aggregateType, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, events := range histories {
+			result = append(result, events...)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GlobalPosition < result[j].GlobalPosition
+	})
+
+	return result, nil
+}
+
+// LoadAllFrom returns up to limit events of all orders with a global position
+// greater than the given position, in the order they were saved. A limit of
+// zero or less returns all of them. Since the events are kept per order, the
+// events of all orders are read.
+func (s *redisEventStore) LoadAllFrom(ctx context.Context, position int64, limit int) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadAllFrom", trace.WithAttributes(
+		attribute.Int64("position.after", position),
+		attribute.Int("page.limit", limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	events, err := s.LoadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start := sort.Search(len(events), func(i int) bool {
+		return events[i].GlobalPosition > position
+	})
+	events = events[start:]
+
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+
+	return events, nil
+}
+
+// Stream sends the events for the aggregate in sequence order on the returned
+// channel, which is closed once all of them have been sent, the context is
+// cancelled or an error occurred. The error, if any, is sent on the error
+// channel.
+func (s *redisEventStore) Stream(ctx context.Context, aggregateType, id string) (<-chan PersistedEvent, <-chan error) {
+	return stream(ctx, s, aggregateType, id)
+}
+
+// decodeRedisRecords decodes the JSON encoded records of the aggregate.
+func decodeRedisRecords(aggregateType, id string, recs []string) ([]PersistedEvent, error) {
+	result := make([]PersistedEvent, len(recs))
+	for i, data := range recs {
+		var rec redisRecord
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return nil, err
+		}
+
+		e, version, err := decodeEvent(rec.Type, rec.SchemaVersion, rec.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		result[i] = PersistedEvent{
+			Event:          e,
+			EventID:        rec.EventID,
+			Sequence:       rec.Sequence,
+			GlobalPosition: rec.GlobalPosition,
+			AggregateType:  aggregateType,
+			AggregateID:    id,
+			OccurredAt:     rec.OccurredAt,
+			Metadata:       rec.Metadata,
+			SchemaVersion:  version,
+		}
+	}
+
+	return result, nil
+}
+
+// NewRedisEventStore returns an event store keeping the events of each
+// aggregate in a Redis list keyed by events:type:{id}. Since the global
+// position is shared by all aggregates, the store doesn't support Redis
+// Cluster.
+func NewRedisEventStore(client *redis.Client) EventStore {
+	return &redisEventStore{client: client}
+}
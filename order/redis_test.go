@@ -0,0 +1,98 @@
+//go:build redis
+
+package order_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// openRedis connects to the Redis server given by REDIS_ADDR, e.g. a
+// container started with
+//
+//	docker run --rm -p 6379:6379 redis
+func openRedis(t *testing.T) *redis.Client {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+
+	if err := client.FlushDB(context.Background()).Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return client
+}
+
+func TestRedisEventStore(t *testing.T) {
+	store := order.NewRedisEventStore(openRedis(t))
+	repo := order.NewRepository(store)
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+	}
+
+	events, err := store.LoadFrom(context.Background(), order.AggregateTypeOrder, "ABC123", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 1 || events[0].Sequence != 2 || events[0].GlobalPosition != 2 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	if _, err := store.Load(context.Background(), order.AggregateTypeOrder, "XYZ789"); !errors.Is(err, order.ErrOrderNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrOrderNotFound, err)
+	}
+}
+
+func TestRedisEventStoreConflict(t *testing.T) {
+	store := order.NewRedisEventStore(openRedis(t))
+
+	events := []order.PersistedEvent{{Event: order.Placed{OrderID: "ABC123"}}}
+
+	if err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, events)
+	if !errors.Is(err, order.ErrConcurrencyConflict) {
+		t.Errorf("expected: %v, got: %v", order.ErrConcurrencyConflict, err)
+	}
+
+	var cerr *order.ConcurrencyError
+	if !errors.As(err, &cerr) || cerr.Expected != 0 || cerr.Actual != 1 {
+		t.Errorf("unexpected error: %#v", err)
+	}
+
+	loaded, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(loaded) != 1 {
+		t.Errorf("expected: %v, got: %v", 1, len(loaded))
+	}
+}
@@ -0,0 +1,199 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrDuplicateEventType is returned when registering an event type under a
+// name that is already in use.
+var ErrDuplicateEventType = errors.New("event type already registered")
+
+// ErrUnsupportedSchemaVersion is returned when decoding an event stored with a
+// schema version newer than the one registered for its type.
+var ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+
+// Registry maps stored event type names to the concrete event types, allowing
+// events to be rebuilt from a persistent store.
+//
+// Events defined outside of this package can be registered with the default
+// registry, typically from an init function:
+//
+//	func init() {
+//		order.DefaultRegistry.RegisterFor("shipping", "Dispatched", func() order.Event {
+//			return Dispatched{}
+//		})
+//	}
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]func() Event
+	names     map[reflect.Type]string
+	versions  map[string]int
+	upcasters UpcasterChain
+}
+
+// NewRegistry returns a new, empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[string]func() Event),
+		names:     make(map[reflect.Type]string),
+		versions:  make(map[string]int),
+	}
+}
+
+// DefaultRegistry is the registry used when encoding and decoding events. It
+// comes populated with the events provided by this package.
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "Placed", func() Event { return Placed{} })
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "Activated", func() Event { return Activated{} })
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "Cancelled", func() Event { return Cancelled{} })
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "LineAdded", func() Event { return LineAdded{} })
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "LineRemoved", func() Event { return LineRemoved{} })
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "LineQuantityChanged", func() Event { return LineQuantityChanged{} })
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "DiscountApplied", func() Event { return DiscountApplied{} })
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "Shipped", func() Event { return Shipped{} })
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "Delivered", func() Event { return Delivered{} })
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "Held", func() Event { return Held{} })
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "Reactivated", func() Event { return Reactivated{} })
+	DefaultRegistry.RegisterFor(AggregateTypeOrder, "Expired", func() Event { return Expired{} })
+}
+
+// EventTypeName returns the name of an event type of the aggregate type,
+// namespaced by the aggregate type, e.g. "order.Placed".
+func EventTypeName(aggregateType, name string) string {
+	return aggregateType + "." + name
+}
+
+// RegisterFor adds an event type of the aggregate type at schema version 1,
+// under its name namespaced by the aggregate type, so that aggregates of
+// different types may have events of the same name.
+func (r *Registry) RegisterFor(aggregateType, name string, factory func() Event) error {
+	return r.Register(EventTypeName(aggregateType, name), factory)
+}
+
+// Register adds an event type under the given name at schema version 1. The
+// factory must return the zero value of the event.
+func (r *Registry) Register(name string, factory func() Event) error {
+	return r.RegisterVersion(name, 1, factory)
+}
+
+// RegisterVersion adds an event type under the given name, with the schema
+// version stamped on new events of the type. The version should be increased
+// whenever the encoding of the event changes.
+func (r *Registry) RegisterVersion(name string, version int, factory func() Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.factories[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateEventType, name)
+	}
+
+	r.factories[name] = factory
+	r.names[reflect.TypeOf(factory())] = name
+	r.versions[name] = version
+
+	return nil
+}
+
+// New returns a new event of the type registered under the given name.
+func (r *Registry) New(name string) (Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEventType, name)
+	}
+
+	return factory(), nil
+}
+
+// RegisterUpcaster adds an upcaster to the chain applied to events stored
+// with an older schema version before they are decoded.
+func (r *Registry) RegisterUpcaster(u Upcaster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.upcasters = append(r.upcasters, u)
+}
+
+// Unmarshal decodes the JSON encoded data, stored with the given schema
+// version, into a new event of the type registered under the given name. Data
+// of an older version is upcast to the current version first. Versions that
+// no upcaster applies to are assumed to share the current encoding.
+func (r *Registry) Unmarshal(name string, version int, data []byte) (Event, error) {
+	current, err := r.version(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if version > current {
+		return nil, fmt.Errorf("%w: %s version %d", ErrUnsupportedSchemaVersion, name, version)
+	}
+
+	r.mu.RLock()
+	upcasters := r.upcasters
+	r.mu.RUnlock()
+
+	for version < current {
+		newData, newVersion, err := upcasters.Upcast(name, version, data)
+		if err != nil {
+			return nil, err
+		}
+		if newVersion == version {
+			break
+		}
+		data, version = newData, newVersion
+	}
+
+	e, err := r.New(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ptr := reflect.New(reflect.TypeOf(e))
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return nil, err
+	}
+
+	return ptr.Elem().Interface().(Event), nil
+}
+
+// SchemaVersion returns the current schema version of the type of the event.
+func (r *Registry) SchemaVersion(e Event) (int, error) {
+	name, err := r.Name(e)
+	if err != nil {
+		return 0, err
+	}
+	return r.version(name)
+}
+
+func (r *Registry) version(name string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	version, ok := r.versions[name]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownEventType, name)
+	}
+
+	return version, nil
+}
+
+// Name returns the name the type of the event was registered under.
+func (r *Registry) Name(e Event) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name, ok := r.names[reflect.TypeOf(e)]
+	if !ok {
+		return "", fmt.Errorf("%w: %T", ErrUnknownEventType, e)
+	}
+
+	return name, nil
+}
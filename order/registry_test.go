@@ -0,0 +1,84 @@
+package order_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+type dispatched struct {
+	OrderID string
+}
+
+func (e dispatched) ID() string {
+	return e.OrderID
+}
+
+func TestRegistry(t *testing.T) {
+	r := order.NewRegistry()
+
+	if err := r.Register("test.Dispatched", func() order.Event { return dispatched{} }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e, err := r.New("test.Dispatched")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := e.(dispatched); !ok {
+		t.Errorf("expected: %T, got: %T", dispatched{}, e)
+	}
+
+	name, err := r.Name(dispatched{OrderID: "ABC123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if name != "test.Dispatched" {
+		t.Errorf("expected: %v, got: %v", "test.Dispatched", name)
+	}
+}
+
+func TestRegistryUnknownName(t *testing.T) {
+	r := order.NewRegistry()
+
+	if _, err := r.New("test.Dispatched"); !errors.Is(err, order.ErrUnknownEventType) {
+		t.Errorf("expected: %v, got: %v", order.ErrUnknownEventType, err)
+	}
+}
+
+func TestRegistryDuplicateName(t *testing.T) {
+	r := order.NewRegistry()
+
+	if err := r.Register("test.Dispatched", func() order.Event { return dispatched{} }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := r.Register("test.Dispatched", func() order.Event { return dispatched{} })
+	if !errors.Is(err, order.ErrDuplicateEventType) {
+		t.Errorf("expected: %v, got: %v", order.ErrDuplicateEventType, err)
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	for _, name := range []string{
+		"order.Placed",
+		"order.Activated",
+		"order.Cancelled",
+		"order.LineAdded",
+		"order.LineRemoved",
+		"order.LineQuantityChanged",
+		"order.DiscountApplied",
+		"order.Shipped",
+		"order.Delivered",
+		"order.Held",
+		"order.Reactivated",
+		"order.Expired",
+	} {
+		if _, err := order.DefaultRegistry.New(name); err != nil {
+			t.Errorf("unexpected error for %v: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package order
+
+import (
+	"context"
+	"sync"
+)
+
+// Projection is a read model built from the events of all orders.
+type Projection interface {
+	// Apply updates the read model from the event.
+	Apply(e PersistedEvent)
+
+	// Rebuild discards the read model and recreates it from the events.
+	Rebuild(events []PersistedEvent)
+}
+
+// Replayer rebuilds projections from the full history of an event store, e.g.
+// to cold-start read models or to recover after fixing a projection.
+type Replayer struct {
+	mu          sync.Mutex
+	store       EventStore
+	projections []Projection
+}
+
+// NewReplayer returns a new replayer of the events in the store.
+func NewReplayer(store EventStore) *Replayer {
+	return &Replayer{store: store}
+}
+
+// Register adds a projection to be rebuilt by Replay.
+func (r *Replayer) Register(p Projection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.projections = append(r.projections, p)
+}
+
+// Replay resets every registered projection and applies all events in the
+// store to them, in the order the events were saved.
+func (r *Replayer) Replay(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events, err := r.store.LoadAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range r.projections {
+		p.Rebuild(nil)
+	}
+
+	for _, e := range events {
+		for _, p := range r.projections {
+			p.Apply(e)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package order_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestReplayer(t *testing.T) {
+	store := order.NewEventStore()
+
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	for _, c := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.Place{OrderID: "XYZ789", Lines: testLines},
+		order.Activate{OrderID: "ABC123"},
+		order.Cancel{OrderID: "XYZ789"},
+	} {
+		if err := handler.Handle(context.Background(), c); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	summaries := order.NewSummaryProjection()
+	counts := order.NewStatusCountProjection()
+
+	// Stale state from before the replay, which should be discarded.
+	stale := order.PersistedEvent{Event: order.Placed{OrderID: "STALE"}, AggregateID: "STALE", Sequence: 1}
+	summaries.Apply(stale)
+	counts.Apply(stale)
+
+	r := order.NewReplayer(store)
+	r.Register(summaries)
+	r.Register(counts)
+
+	if err := r.Replay(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var ids []string
+	for _, s := range summaries.List() {
+		ids = append(ids, s.ID)
+	}
+
+	if want := []string{"ABC123", "XYZ789"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected: %v, got: %v", want, ids)
+	}
+
+	if s, _ := summaries.Get("XYZ789"); s.Status != order.StatusCancelled {
+		t.Errorf("expected: %v, got: %v", order.StatusCancelled, s.Status)
+	}
+
+	want := map[order.Status]int{
+		order.StatusActivated: 1,
+		order.StatusCancelled: 1,
+	}
+	if got := counts.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected: %v, got: %v", want, got)
+	}
+}
+
+func TestEventStoreLoadAll(t *testing.T) {
+	store := order.NewEventStore()
+
+	saveEvents(t, store, "ABC123", 2)
+	saveEvents(t, store, "XYZ789", 1)
+
+	events, err := store.LoadAll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("expected: %v, got: %v", 3, len(events))
+	}
+
+	for i, e := range events {
+		if want := int64(i + 1); e.GlobalPosition != want {
+			t.Errorf("expected: %v, got: %v", want, e.GlobalPosition)
+		}
+	}
+}
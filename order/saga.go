@@ -0,0 +1,397 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrSagaNotFound is returned when no state exists for a saga.
+var ErrSagaNotFound = errors.New("saga was not found")
+
+// ProcessManager reacts to events by returning the commands to dispatch.
+type ProcessManager interface {
+	Handle(ctx context.Context, e PersistedEvent) ([]interface{}, error)
+}
+
+// SagaStore defines the operations of a store for saga state.
+type SagaStore interface {
+	SaveSaga(id string, state []byte) error
+	LoadSaga(id string) ([]byte, error)
+	ListSagas() ([]string, error)
+}
+
+type sagaStore struct {
+	mu     sync.RWMutex
+	states map[string][]byte
+}
+
+// SaveSaga replaces the state of the saga.
+func (s *sagaStore) SaveSaga(id string, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[id] = state
+
+	return nil
+}
+
+// LoadSaga returns the state of the saga.
+func (s *sagaStore) LoadSaga(id string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.states[id]
+	if !ok {
+		return nil, ErrSagaNotFound
+	}
+
+	return state, nil
+}
+
+// ListSagas returns the IDs of all sagas, sorted by ID.
+func (s *sagaStore) ListSagas() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.states))
+	for id := range s.states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// NewSagaStore returns a new instance of the default in-memory saga store.
+// The store is safe for concurrent use by multiple goroutines.
+func NewSagaStore() SagaStore {
+	return &sagaStore{
+		states: make(map[string][]byte),
+	}
+}
+
+// fulfillmentState is the persisted state of a fulfillment saga for a single
+// order.
+type fulfillmentState struct {
+	OrderID string
+	ShipAt  time.Time
+	Done    bool
+}
+
+// FulfillmentSaga ships orders once they have been activated for a while.
+// The saga keeps its state in a saga store, so that orders awaiting shipment
+// are shipped even after a restart.
+type FulfillmentSaga struct {
+	Store SagaStore
+	Clock Clock
+
+	// Delay is the time between the activation of an order and the command
+	// to ship it.
+	Delay time.Duration
+
+	mu sync.Mutex
+}
+
+// Handle updates the saga of the order. The Ship command is returned once the
+// delay after activation has passed, which for a zero delay is right away.
+func (s *FulfillmentSaga) Handle(ctx context.Context, e PersistedEvent) ([]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load(e.Event.ID())
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Event.(type) {
+	case Activated:
+		if state.Done || !state.ShipAt.IsZero() {
+			return nil, nil
+		}
+		activatedAt := e.OccurredAt
+		if activatedAt.IsZero() {
+			activatedAt = now(s.Clock)
+		}
+		state.ShipAt = activatedAt.Add(s.Delay)
+	case Shipped, Cancelled:
+		state.Done = true
+	default:
+		return nil, nil
+	}
+
+	if err := s.save(state); err != nil {
+		return nil, err
+	}
+
+	cmd, err := s.due(state)
+	if err != nil || cmd == nil {
+		return nil, err
+	}
+
+	return []interface{}{cmd}, nil
+}
+
+// Poll returns the Ship commands of the orders whose delay has passed since
+// the last call to Handle or Poll.
+func (s *FulfillmentSaga) Poll(ctx context.Context) ([]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, err := s.Store.ListSagas()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []interface{}
+	for _, id := range ids {
+		state, err := s.load(id)
+		if err != nil {
+			return nil, err
+		}
+
+		cmd, err := s.due(state)
+		if err != nil {
+			return nil, err
+		}
+		if cmd != nil {
+			result = append(result, cmd)
+		}
+	}
+
+	return result, nil
+}
+
+// due returns the Ship command for the order if the delay has passed, and
+// marks the saga as done.
+func (s *FulfillmentSaga) due(state fulfillmentState) (interface{}, error) {
+	if state.Done || state.ShipAt.IsZero() || now(s.Clock).Before(state.ShipAt) {
+		return nil, nil
+	}
+
+	state.Done = true
+	if err := s.save(state); err != nil {
+		return nil, err
+	}
+
+	return Ship{OrderID: OrderID(state.OrderID)}, nil
+}
+
+func (s *FulfillmentSaga) load(id string) (fulfillmentState, error) {
+	data, err := s.Store.LoadSaga(id)
+	if errors.Is(err, ErrSagaNotFound) {
+		return fulfillmentState{OrderID: id}, nil
+	}
+	if err != nil {
+		return fulfillmentState{}, err
+	}
+
+	var state fulfillmentState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fulfillmentState{}, err
+	}
+
+	return state, nil
+}
+
+func (s *FulfillmentSaga) save(state fulfillmentState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.Store.SaveSaga(state.OrderID, data)
+}
+
+// NewFulfillmentSaga returns a new fulfillment saga that ships orders the
+// given delay after they have been activated.
+func NewFulfillmentSaga(store SagaStore, delay time.Duration) *FulfillmentSaga {
+	return &FulfillmentSaga{
+		Store: store,
+		Clock: systemClock{},
+		Delay: delay,
+	}
+}
+
+// InventoryPort reserves stock for orders. Implementations live outside this
+// package, next to the inventory system they talk to.
+type InventoryPort interface {
+	// Reserve reserves the quantity of the product, returning an error if
+	// it can't be reserved.
+	Reserve(productID string, qty int) error
+}
+
+// reservationState is the persisted state of a reservation saga for a single
+// order.
+type reservationState struct {
+	OrderID string
+	Lines   []Line
+
+	// Reserved holds the products that have been reserved, so that they
+	// aren't reserved again when the saga is handed the same events again.
+	Reserved []string
+
+	// Sequence is the sequence number of the latest handled event.
+	Sequence int
+	Done     bool
+}
+
+// reserved reports whether the product has been reserved.
+func (s reservationState) reserved(productID string) bool {
+	for _, id := range s.Reserved {
+		if id == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// ReservationSaga reserves the stock of the lines of orders once they have
+// been activated, and cancels orders for which the stock can't be reserved.
+// Stock reserved before a failing line isn't released. The saga keeps track
+// of the lines of each order and of the reserved products in a saga store,
+// which must not be shared with other sagas, so that stock is reserved once
+// even if events are handled again, for example after a restart.
+type ReservationSaga struct {
+	Store     SagaStore
+	Inventory InventoryPort
+
+	mu sync.Mutex
+}
+
+// Handle updates the saga of the order. When the order is activated, the
+// stock of each of its products is reserved, and the Cancel command is
+// returned if any of them can't be reserved.
+func (s *ReservationSaga) Handle(ctx context.Context, e PersistedEvent) ([]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.load(e.Event.ID())
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Done || (e.Sequence > 0 && e.Sequence <= state.Sequence) {
+		return nil, nil
+	}
+
+	var cmds []interface{}
+
+	switch evt := e.Event.(type) {
+	case Placed:
+		state.Lines = append([]Line(nil), evt.Lines...)
+	case LineAdded:
+		state.Lines = append(state.Lines, evt.Line)
+	case LineRemoved:
+		state.Lines = removeProduct(state.Lines, evt.ProductID)
+	case LineQuantityChanged:
+		state.Lines = changeQuantity(state.Lines, evt.ProductID, evt.Quantity)
+	case Activated:
+		cmds, err = s.reserve(&state)
+		if err != nil {
+			return nil, err
+		}
+	case Cancelled:
+		state.Done = true
+	default:
+		return nil, nil
+	}
+
+	// The event is only marked as handled once it has been handled
+	// completely.
+	if e.Sequence > 0 {
+		state.Sequence = e.Sequence
+	}
+
+	if err := s.save(state); err != nil {
+		return nil, err
+	}
+
+	return cmds, nil
+}
+
+// reserve reserves the stock of the products of the order that haven't been
+// reserved yet, and returns the Cancel command if one of them can't be
+// reserved. The saga is done either way.
+func (s *ReservationSaga) reserve(state *reservationState) ([]interface{}, error) {
+	state.Done = true
+
+	quantities := make(map[string]int)
+	var products []string
+	for _, l := range state.Lines {
+		if _, ok := quantities[l.ProductID]; !ok {
+			products = append(products, l.ProductID)
+		}
+		quantities[l.ProductID] += l.Quantity
+	}
+
+	for _, id := range products {
+		if state.reserved(id) {
+			continue
+		}
+
+		if err := s.Inventory.Reserve(id, quantities[id]); err != nil {
+			return []interface{}{Cancel{OrderID: OrderID(state.OrderID)}}, nil
+		}
+
+		// Save every reservation right away, so that it isn't made again
+		// if the saga stops before it is done.
+		state.Reserved = append(state.Reserved, id)
+
+		pending := *state
+		pending.Done = false
+		if err := s.save(pending); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *ReservationSaga) load(id string) (reservationState, error) {
+	data, err := s.Store.LoadSaga(id)
+	if errors.Is(err, ErrSagaNotFound) {
+		return reservationState{OrderID: id}, nil
+	}
+	if err != nil {
+		return reservationState{}, err
+	}
+
+	var state reservationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return reservationState{}, err
+	}
+
+	return state, nil
+}
+
+func (s *ReservationSaga) save(state reservationState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.Store.SaveSaga(state.OrderID, data)
+}
+
+// NewReservationSaga returns a new reservation saga reserving stock in the
+// inventory.
+func NewReservationSaga(store SagaStore, inventory InventoryPort) *ReservationSaga {
+	return &ReservationSaga{
+		Store:     store,
+		Inventory: inventory,
+	}
+}
@@ -0,0 +1,177 @@
+package order_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestFulfillmentSagaShipsAfterDelay(t *testing.T) {
+	activatedAt := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	store := order.NewSagaStore()
+
+	saga := order.NewFulfillmentSaga(store, time.Hour)
+	saga.Clock = stubClock{now: activatedAt}
+
+	events := []order.PersistedEvent{
+		{Event: order.Placed{OrderID: "ABC123", Lines: testLines}, AggregateID: "ABC123", Sequence: 1, OccurredAt: activatedAt},
+		{Event: order.Activated{OrderID: "ABC123"}, AggregateID: "ABC123", Sequence: 2, OccurredAt: activatedAt},
+	}
+
+	for _, e := range events {
+		cmds, err := saga.Handle(context.Background(), e)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cmds) != 0 {
+			t.Errorf("expected no commands, got: %v", cmds)
+		}
+	}
+
+	// Restart the saga to make sure that the state was persisted.
+	saga = order.NewFulfillmentSaga(store, time.Hour)
+	saga.Clock = stubClock{now: activatedAt.Add(time.Hour)}
+
+	cmds, err := saga.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []interface{}{order.Ship{OrderID: "ABC123"}}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("expected: %v, got: %v", want, cmds)
+	}
+
+	cmds, err = saga.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cmds) != 0 {
+		t.Errorf("expected no commands, got: %v", cmds)
+	}
+}
+
+func TestFulfillmentSagaWithoutDelay(t *testing.T) {
+	saga := order.NewFulfillmentSaga(order.NewSagaStore(), 0)
+
+	cmds, err := saga.Handle(context.Background(), order.PersistedEvent{Event: order.Activated{OrderID: "ABC123"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []interface{}{order.Ship{OrderID: "ABC123"}}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("expected: %v, got: %v", want, cmds)
+	}
+}
+
+func TestFulfillmentSagaCancelled(t *testing.T) {
+	now := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	saga := order.NewFulfillmentSaga(order.NewSagaStore(), time.Hour)
+	saga.Clock = stubClock{now: now}
+
+	for _, e := range []order.Event{
+		order.Activated{OrderID: "ABC123"},
+		order.Cancelled{OrderID: "ABC123"},
+	} {
+		if _, err := saga.Handle(context.Background(), order.PersistedEvent{Event: e, OccurredAt: now}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	saga.Clock = stubClock{now: now.Add(time.Hour)}
+
+	cmds, err := saga.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cmds) != 0 {
+		t.Errorf("expected no commands, got: %v", cmds)
+	}
+}
+
+// fakeInventory reserves any product except the out of stock ones.
+type fakeInventory struct {
+	outOfStock map[string]bool
+	reserved   map[string]int
+}
+
+func (i *fakeInventory) Reserve(productID string, qty int) error {
+	if i.outOfStock[productID] {
+		return fmt.Errorf("%s is out of stock", productID)
+	}
+	i.reserved[productID] += qty
+	return nil
+}
+
+func TestReservationSagaCancelsOrder(t *testing.T) {
+	store := order.NewEventStore()
+	repo := order.NewRepository(store)
+	handler := order.NewCommandHandler(repo)
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: []order.Line{{ProductID: "P1", Quantity: 2, UnitPrice: 100}}},
+		order.Place{OrderID: "XYZ789", Lines: []order.Line{{ProductID: "P1", Quantity: 1, UnitPrice: 100}}},
+		order.AddLine{OrderID: "XYZ789", Line: order.Line{ProductID: "P2", Quantity: 1, UnitPrice: 100}},
+		order.Activate{OrderID: "ABC123"},
+		order.Activate{OrderID: "XYZ789"},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	events, err := store.LoadAll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	inventory := &fakeInventory{outOfStock: map[string]bool{"P2": true}, reserved: make(map[string]int)}
+	saga := order.NewReservationSaga(order.NewSagaStore(), inventory)
+
+	// Handle the events twice, as if they were delivered again.
+	var cmds []interface{}
+	for i := 0; i < 2; i++ {
+		for _, e := range events {
+			c, err := saga.Handle(context.Background(), e)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cmds = append(cmds, c...)
+		}
+	}
+
+	want := []interface{}{order.Cancel{OrderID: "XYZ789"}}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Fatalf("expected: %v, got: %v", want, cmds)
+	}
+
+	if inventory.reserved["P1"] != 3 {
+		t.Errorf("expected: %v, got: %v", 3, inventory.reserved["P1"])
+	}
+
+	for _, cmd := range cmds {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for id, want := range map[string]order.Status{
+		"ABC123": order.StatusActivated,
+		"XYZ789": order.StatusCancelled,
+	} {
+		o, err := repo.Load(context.Background(), id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if o.Status != want {
+			t.Errorf("%s: expected: %v, got: %v", id, want, o.Status)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrScheduleNotFound is returned when cancelling a command that isn't
+// scheduled, either because it was never scheduled or because it has already
+// been dispatched.
+var ErrScheduleNotFound = errors.New("scheduled command was not found")
+
+// scheduledCommand is a command waiting in the scheduler to become due.
+type scheduledCommand struct {
+	id    int64
+	dueAt time.Time
+	cmd   interface{}
+}
+
+// Scheduler dispatches commands through a command bus once they become due,
+// such as expiring orders that haven't been activated in time. The scheduler
+// is safe for concurrent use by multiple goroutines.
+type Scheduler struct {
+	Bus *CommandBus
+
+	// Clock decides when commands are due. The system clock is used if nil.
+	Clock Clock
+
+	// Interval is the time to wait between ticks. The scheduler ticks every
+	// second if it isn't positive.
+	Interval time.Duration
+
+	mu      sync.Mutex
+	pending []scheduledCommand
+	nextID  int64
+
+	life lifecycle
+}
+
+// NewScheduler returns a new scheduler dispatching commands through the bus,
+// ticking every second.
+func NewScheduler(bus *CommandBus) *Scheduler {
+	return &Scheduler{
+		Bus:      bus,
+		Interval: time.Second,
+	}
+}
+
+// Schedule queues the command to be dispatched once dueAt has passed, and
+// returns the ID used to cancel it. Commands can't be scheduled once the
+// scheduler has been closed.
+func (s *Scheduler) Schedule(dueAt time.Time, cmd interface{}) (int64, error) {
+	if err := s.life.check(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+
+	// Keep the pending commands ordered by due time, and commands due at
+	// the same time in the order they were scheduled.
+	i := sort.Search(len(s.pending), func(i int) bool {
+		return s.pending[i].dueAt.After(dueAt)
+	})
+
+	s.pending = append(s.pending, scheduledCommand{})
+	copy(s.pending[i+1:], s.pending[i:])
+	s.pending[i] = scheduledCommand{id: s.nextID, dueAt: dueAt, cmd: cmd}
+
+	return s.nextID, nil
+}
+
+// Cancel removes a scheduled command before it is dispatched.
+func (s *Scheduler) Cancel(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range s.pending {
+		if c.id == id {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %d", ErrScheduleNotFound, id)
+}
+
+// Len returns the number of commands waiting to be dispatched.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.pending)
+}
+
+// Tick dispatches the commands that are due and returns the number of
+// commands that were dispatched. Commands are removed from the scheduler
+// before they are dispatched, so that each is dispatched at most once; a
+// command failing to dispatch isn't retried, and the commands due after it
+// are left for the next tick. No commands are dispatched once the scheduler
+// has been closed.
+func (s *Scheduler) Tick(ctx context.Context) (int, error) {
+	var n int
+	for {
+		if s.life.check() != nil {
+			return n, nil
+		}
+
+		c, ok := s.next()
+		if !ok {
+			return n, nil
+		}
+
+		if _, err := s.Bus.Dispatch(ctx, c.cmd); err != nil {
+			return n, fmt.Errorf("dispatch scheduled command %d: %w", c.id, err)
+		}
+
+		n++
+	}
+}
+
+// next removes and returns the earliest command, if it is due.
+func (s *Scheduler) next() (scheduledCommand, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) == 0 || s.pending[0].dueAt.After(now(s.Clock)) {
+		return scheduledCommand{}, false
+	}
+
+	c := s.pending[0]
+	s.pending = s.pending[1:]
+
+	return c, true
+}
+
+// Run ticks until the context is cancelled or the scheduler is closed.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if err := s.life.enter(); err != nil {
+		return err
+	}
+	defer s.life.exit()
+
+	return s.run(ctx)
+}
+
+// Start ticks in a new goroutine until the scheduler is closed.
+func (s *Scheduler) Start() error {
+	return s.life.start(func() error {
+		return s.run(context.Background())
+	})
+}
+
+// Close stops the scheduler from accepting and dispatching commands, and
+// waits for the commands being dispatched, or for the context to be done. It
+// returns the error that stopped the ticking started by Start, if any.
+func (s *Scheduler) Close(ctx context.Context) error {
+	return s.life.close(ctx)
+}
+
+func (s *Scheduler) run(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	stop := s.life.stopping()
+
+	for {
+		if _, err := s.Tick(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
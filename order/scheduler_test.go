@@ -0,0 +1,164 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// manualClock is a clock that only moves when advanced.
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestSchedulerDispatchesDueCommands(t *testing.T) {
+	clock := &manualClock{now: time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)}
+
+	repo := order.NewRepository(order.NewEventStore())
+	handler := order.NewCommandHandler(repo, order.WithClock(clock))
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var expired int
+	bus := order.NewCommandBus()
+	if err := bus.Register(order.Expire{}, func(ctx context.Context, cmd interface{}) error {
+		expired++
+		return handler.Handle(ctx, cmd)
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	scheduler := order.NewScheduler(bus)
+	scheduler.Clock = clock
+	if _, err := scheduler.Schedule(clock.Now().Add(time.Hour), order.Expire{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n, err := scheduler.Tick(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected no commands to be due, got: %d (%v)", n, err)
+	}
+
+	clock.Advance(2 * time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if _, err := scheduler.Tick(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if expired != 1 {
+		t.Errorf("expected: %v, got: %v", 1, expired)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusExpired {
+		t.Errorf("expected: %v, got: %v", order.StatusExpired, o.Status)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	clock := &manualClock{now: time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)}
+
+	var dispatched []string
+	bus := order.NewCommandBus()
+	if err := bus.Register(order.Expire{}, func(ctx context.Context, cmd interface{}) error {
+		dispatched = append(dispatched, string(cmd.(order.Expire).OrderID))
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	scheduler := order.NewScheduler(bus)
+	scheduler.Clock = clock
+
+	// Schedule concurrently, out of due order.
+	ids := make([]int64, 3)
+	var wg sync.WaitGroup
+	for i, id := range []string{"C", "A", "B"} {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			ids[i], _ = scheduler.Schedule(clock.Now().Add(time.Duration(id[0]-'A')*time.Minute), order.Expire{OrderID: order.OrderID(id)})
+		}(i, id)
+	}
+	wg.Wait()
+
+	if err := scheduler.Cancel(ids[2]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := scheduler.Cancel(ids[2]); !errors.Is(err, order.ErrScheduleNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrScheduleNotFound, err)
+	}
+
+	clock.Advance(time.Hour)
+
+	n, err := scheduler.Tick(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n != 2 || len(dispatched) != 2 || dispatched[0] != "A" || dispatched[1] != "C" {
+		t.Errorf("expected: %v, got: %v", []string{"A", "C"}, dispatched)
+	}
+	if scheduler.Len() != 0 {
+		t.Errorf("expected: %v, got: %v", 0, scheduler.Len())
+	}
+}
+
+func TestSchedulerClose(t *testing.T) {
+	scheduler := order.NewScheduler(order.NewCommandBus())
+	scheduler.Interval = time.Millisecond
+
+	if err := scheduler.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := scheduler.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := scheduler.Schedule(time.Now(), order.Expire{OrderID: "ABC123"}); !errors.Is(err, order.ErrClosed) {
+		t.Errorf("expected: %v, got: %v", order.ErrClosed, err)
+	}
+}
+
+func TestSchedulerDefaultInterval(t *testing.T) {
+	scheduler := &order.Scheduler{Bus: order.NewCommandBus()}
+
+	if err := scheduler.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := scheduler.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
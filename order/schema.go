@@ -0,0 +1,354 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// ErrSchemaViolation is returned when an event payload doesn't conform to the
+// JSON Schema registered for its type.
+var ErrSchemaViolation = errors.New("event does not match schema")
+
+// ErrUnsupportedSchemaKeyword is returned when registering a schema using a
+// JSON Schema keyword that Schemas doesn't support.
+var ErrUnsupportedSchemaKeyword = errors.New("unsupported schema keyword")
+
+// SchemaError describes the part of an event payload violating the schema of
+// the event type.
+type SchemaError struct {
+	EventType string
+
+	// Path locates the offending value, e.g. Lines[0].Quantity. It is empty
+	// for the payload itself.
+	Path   string
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%v: %s: %s", ErrSchemaViolation, e.EventType, e.Reason)
+	}
+	return fmt.Sprintf("%v: %s: %s %s", ErrSchemaViolation, e.EventType, e.Path, e.Reason)
+}
+
+// Is reports whether the target is ErrSchemaViolation.
+func (e *SchemaError) Is(target error) bool {
+	return target == ErrSchemaViolation
+}
+
+// jsonSchema is the subset of JSON Schema supported by Schemas.
+type jsonSchema struct {
+	Type       string                 `json:"type"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	Enum       []interface{}          `json:"enum"`
+	MinLength  *int                   `json:"minLength"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+}
+
+// supportedKeywords are the keywords of the schema subset, along with the
+// annotations that don't affect validation.
+var supportedKeywords = map[string]bool{
+	"type":        true,
+	"required":    true,
+	"properties":  true,
+	"items":       true,
+	"enum":        true,
+	"minLength":   true,
+	"minimum":     true,
+	"maximum":     true,
+	"$schema":     true,
+	"$comment":    true,
+	"title":       true,
+	"description": true,
+}
+
+// checkKeywords returns an error for the first keyword of the schema or of
+// its subschemas that isn't supported, so that constraints aren't silently
+// left unchecked.
+func checkKeywords(schema []byte, path string) error {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(schema, &keywords); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !supportedKeywords[name] {
+			return fmt.Errorf("%w: %s", ErrUnsupportedSchemaKeyword, joinPath(path, name))
+		}
+	}
+
+	if data, ok := keywords["properties"]; ok {
+		var properties map[string]json.RawMessage
+		if err := json.Unmarshal(data, &properties); err != nil {
+			return err
+		}
+
+		names = names[:0]
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if err := checkKeywords(properties[name], joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if data, ok := keywords["items"]; ok {
+		if err := checkKeywords(data, path+"[]"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate checks the decoded JSON value against the schema, returning the
+// first violation found.
+func (s *jsonSchema) validate(v interface{}, path string) *SchemaError {
+	if s.Type != "" && !hasJSONType(v, s.Type) {
+		return &SchemaError{Path: path, Reason: "must be of type " + s.Type}
+	}
+
+	if len(s.Enum) > 0 {
+		var found bool
+		for _, allowed := range s.Enum {
+			if reflect.DeepEqual(v, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &SchemaError{Path: path, Reason: fmt.Sprintf("must be one of %v", s.Enum)}
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return &SchemaError{Path: joinPath(path, name), Reason: "is required"}
+			}
+		}
+		for name, prop := range s.Properties {
+			if value, ok := v[name]; ok {
+				if err := prop.validate(value, joinPath(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		if s.MinLength != nil && len([]rune(v)) < *s.MinLength {
+			return &SchemaError{Path: path, Reason: fmt.Sprintf("must not be shorter than %d characters", *s.MinLength)}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return &SchemaError{Path: path, Reason: fmt.Sprintf("must be at least %v", *s.Minimum)}
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			return &SchemaError{Path: path, Reason: fmt.Sprintf("must be at most %v", *s.Maximum)}
+		}
+	}
+
+	return nil
+}
+
+// hasJSONType reports whether the decoded JSON value is of the named JSON
+// Schema type.
+func hasJSONType(v interface{}, typ string) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return typ == "object"
+	case []interface{}:
+		return typ == "array"
+	case string:
+		return typ == "string"
+	case float64:
+		return typ == "number" || typ == "integer" && v == math.Trunc(v)
+	case bool:
+		return typ == "boolean"
+	case nil:
+		return typ == "null"
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// Schemas holds the JSON Schemas of event types. The keywords type, required,
+// properties, items, enum, minLength, minimum and maximum are supported, as
+// are the annotations $schema, $comment, title and description. It is safe
+// for concurrent use by multiple goroutines.
+type Schemas struct {
+	mu      sync.RWMutex
+	schemas map[string]*jsonSchema
+}
+
+// NewSchemas returns a new, empty set of schemas.
+func NewSchemas() *Schemas {
+	return &Schemas{schemas: make(map[string]*jsonSchema)}
+}
+
+// Register sets the JSON Schema for payloads of the event type registered
+// under the given name, replacing any previous schema. Schemas using other
+// keywords than the supported ones are rejected with
+// ErrUnsupportedSchemaKeyword.
+func (s *Schemas) Register(eventType string, schema []byte) error {
+	var js jsonSchema
+	if err := json.Unmarshal(schema, &js); err != nil {
+		return fmt.Errorf("schema for %s: %w", eventType, err)
+	}
+
+	if err := checkKeywords(schema, ""); err != nil {
+		return fmt.Errorf("schema for %s: %w", eventType, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.schemas[eventType] = &js
+
+	return nil
+}
+
+// Validate checks the encoded payload of an event of the named type against
+// its schema. Payloads of types without a schema are valid.
+func (s *Schemas) Validate(eventType string, payload []byte) error {
+	s.mu.RLock()
+	js, ok := s.schemas[eventType]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return &SchemaError{EventType: eventType, Reason: err.Error()}
+	}
+
+	if err := js.validate(v, ""); err != nil {
+		err.EventType = eventType
+		return err
+	}
+
+	return nil
+}
+
+type schemaValidatingStore struct {
+	EventStore
+	schemas *Schemas
+}
+
+// validate checks the payloads of the events against their schemas.
+func (s *schemaValidatingStore) validate(events []PersistedEvent) error {
+	for _, e := range events {
+		payload, name, err := MarshalEvent(e.Event)
+		if err != nil {
+			return err
+		}
+
+		if err := s.schemas.Validate(name, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save validates the payloads of the events before saving them. If any event
+// is invalid, none are saved.
+func (s *schemaValidatingStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []PersistedEvent) error {
+	if err := s.validate(events); err != nil {
+		return err
+	}
+
+	return s.EventStore.Save(ctx, aggregateType, id, expectedVersion, events)
+}
+
+// SaveAll validates the payloads of the events of every aggregate before
+// saving them in a single transaction, if the underlying store supports it.
+// If any event is invalid, none are saved.
+func (s *schemaValidatingStore) SaveAll(ctx context.Context, changes []AggregateChanges) error {
+	store, ok := s.EventStore.(TransactionalStore)
+	if !ok {
+		return ErrTransactionsNotSupported
+	}
+
+	for _, c := range changes {
+		if err := s.validate(c.Events); err != nil {
+			return err
+		}
+	}
+
+	return store.SaveAll(ctx, changes)
+}
+
+// Subscribe subscribes the handler to the events of the underlying store, if
+// it supports subscriptions.
+func (s *schemaValidatingStore) Subscribe(fromPosition int64, handler func(PersistedEvent) error, opts ...SubscriptionOption) (*Subscription, error) {
+	store, ok := s.EventStore.(SubscribableStore)
+	if !ok {
+		return nil, ErrSubscriptionsNotSupported
+	}
+
+	return store.Subscribe(fromPosition, handler, opts...)
+}
+
+// Redact redacts the fields in the events of the aggregate in the underlying
+// store, if it supports redaction.
+func (s *schemaValidatingStore) Redact(ctx context.Context, aggregateType, id string, fields []string) error {
+	store, ok := s.EventStore.(RedactableEventStore)
+	if !ok {
+		return ErrRedactionNotSupported
+	}
+
+	return store.Redact(ctx, aggregateType, id, fields)
+}
+
+// Redactions returns the redactions made to the underlying store.
+func (s *schemaValidatingStore) Redactions(ctx context.Context) ([]Redaction, error) {
+	store, ok := s.EventStore.(RedactableEventStore)
+	if !ok {
+		return nil, ErrRedactionNotSupported
+	}
+
+	return store.Redactions(ctx)
+}
+
+// NewSchemaValidatingStore returns an event store rejecting events whose
+// payloads don't match the schema of their type with a *SchemaError, before
+// saving them to the given store. It should wrap any encrypting or
+// compressing store, so that the original payloads are validated.
+func NewSchemaValidatingStore(store EventStore, schemas *Schemas) EventStore {
+	return &schemaValidatingStore{EventStore: store, schemas: schemas}
+}
@@ -0,0 +1,126 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// rerouted is emitted by a buggy aggregate that forgets to set the order ID.
+type rerouted struct {
+	Carrier string
+}
+
+func (e rerouted) ID() string {
+	return ""
+}
+
+func init() {
+	order.RegisterEvent("test.Rerouted", rerouted{})
+}
+
+const orderIDSchema = `{
+	"type": "object",
+	"required": ["OrderID"],
+	"properties": {
+		"OrderID": {"type": "string", "minLength": 1},
+		"Lines": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {"Quantity": {"type": "integer", "minimum": 1}}
+			}
+		}
+	}
+}`
+
+func TestSchemaValidatingStore(t *testing.T) {
+	schemas := order.NewSchemas()
+	for _, name := range []string{"order.Placed", "test.Rerouted"} {
+		if err := schemas.Register(name, []byte(orderIDSchema)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	inner := order.NewEventStore()
+	store := order.NewSchemaValidatingStore(inner, schemas)
+
+	for _, tt := range []struct {
+		name  string
+		event order.Event
+		want  *order.SchemaError
+	}{
+		{
+			name:  "valid",
+			event: order.Placed{OrderID: "ABC123", Lines: testLines},
+		},
+		{
+			name:  "missing order ID",
+			event: rerouted{Carrier: "DHL"},
+			want:  &order.SchemaError{EventType: "test.Rerouted", Path: "OrderID", Reason: "is required"},
+		},
+		{
+			name:  "empty order ID",
+			event: order.Placed{Lines: testLines},
+			want:  &order.SchemaError{EventType: "order.Placed", Path: "OrderID", Reason: "must not be shorter than 1 characters"},
+		},
+		{
+			name:  "invalid line",
+			event: order.Placed{OrderID: "XYZ789", Lines: []order.Line{{ProductID: "P1"}}},
+			want:  &order.SchemaError{EventType: "order.Placed", Path: "Lines[0].Quantity", Reason: "must be at least 1"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := store.Save(context.Background(), order.AggregateTypeOrder, tt.name, 0, []order.PersistedEvent{{Event: tt.event}})
+
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var serr *order.SchemaError
+			if !errors.As(err, &serr) {
+				t.Fatalf("expected: %T, got: %v", serr, err)
+			}
+			if *serr != *tt.want {
+				t.Errorf("expected: %v, got: %v", tt.want, serr)
+			}
+			if !errors.Is(err, order.ErrSchemaViolation) {
+				t.Errorf("expected: %v, got: %v", order.ErrSchemaViolation, err)
+			}
+
+			if _, err := inner.Load(context.Background(), order.AggregateTypeOrder, tt.name); !errors.Is(err, order.ErrOrderNotFound) {
+				t.Errorf("expected invalid event not to be saved, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestSchemasUnsupportedKeyword(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		schema string
+	}{
+		{name: "additionalProperties", schema: `{"type": "object", "additionalProperties": false}`},
+		{name: "ref", schema: `{"$ref": "#/definitions/order"}`},
+		{name: "oneOf", schema: `{"oneOf": [{"type": "string"}, {"type": "null"}]}`},
+		{name: "nested pattern", schema: `{"properties": {"OrderID": {"type": "string", "pattern": "^[A-Z]+$"}}}`},
+		{name: "format in items", schema: `{"items": {"type": "string", "format": "uuid"}}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := order.NewSchemas().Register("order.Placed", []byte(tt.schema))
+			if !errors.Is(err, order.ErrUnsupportedSchemaKeyword) {
+				t.Errorf("expected: %v, got: %v", order.ErrUnsupportedSchemaKeyword, err)
+			}
+		})
+	}
+
+	annotated := `{"$schema": "https://json-schema.org/draft/2020-12/schema", "title": "Placed", "description": "An order was placed.", "type": "object"}`
+	if err := order.NewSchemas().Register("order.Placed", []byte(annotated)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
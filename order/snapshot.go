@@ -0,0 +1,152 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSnapshotNotFound is returned when no snapshot exists for an order.
+var ErrSnapshotNotFound = errors.New("snapshot was not found")
+
+// ErrSnapshotVersionMismatch is returned when restoring a snapshot saved with
+// a different snapshot version.
+var ErrSnapshotVersionMismatch = errors.New("snapshot version mismatch")
+
+// SnapshotVersion is the version of the serialized form of an order saved in
+// snapshots. It must be incremented whenever the serialized form changes, so
+// that the repository ignores the snapshots saved with the previous form and
+// replays the events of the order instead.
+const SnapshotVersion = 1
+
+// SnapshotStore defines the operations of a snapshot store.
+type SnapshotStore interface {
+	SaveSnapshot(id string, version int, state []byte) error
+	LoadSnapshot(id string) (version int, state []byte, err error)
+}
+
+// SnapshotPolicy decides when the repository snapshots an order.
+type SnapshotPolicy interface {
+	// ShouldSnapshot reports whether to snapshot an order saved at the
+	// given version, with eventsSinceSnapshot events saved since its latest
+	// snapshot.
+	ShouldSnapshot(version int, eventsSinceSnapshot int) bool
+}
+
+// SnapshotPolicyFunc is an adapter to allow the use of ordinary functions as
+// snapshot policies.
+type SnapshotPolicyFunc func(version int, eventsSinceSnapshot int) bool
+
+// ShouldSnapshot calls f(version, eventsSinceSnapshot).
+func (f SnapshotPolicyFunc) ShouldSnapshot(version int, eventsSinceSnapshot int) bool {
+	return f(version, eventsSinceSnapshot)
+}
+
+// EveryN returns a snapshot policy that snapshots an order once at least n
+// events have been saved since its latest snapshot.
+func EveryN(n int) SnapshotPolicy {
+	return SnapshotPolicyFunc(func(_ int, eventsSinceSnapshot int) bool {
+		return n > 0 && eventsSinceSnapshot >= n
+	})
+}
+
+type snapshot struct {
+	version int
+	state   []byte
+}
+
+type snapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[string]snapshot
+}
+
+// SaveSnapshot replaces the snapshot of the order.
+func (s *snapshotStore) SaveSnapshot(id string, version int, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.snapshots[id] = snapshot{version: version, state: state}
+
+	return nil
+}
+
+// LoadSnapshot returns the latest snapshot of the order.
+func (s *snapshotStore) LoadSnapshot(id string) (int, []byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap, ok := s.snapshots[id]
+	if !ok {
+		return 0, nil, ErrSnapshotNotFound
+	}
+
+	return snap.version, snap.state, nil
+}
+
+// NewSnapshotStore returns a new instance of the default in-memory snapshot
+// store. The store is safe for concurrent use by multiple goroutines.
+func NewSnapshotStore() SnapshotStore {
+	return &snapshotStore{
+		snapshots: make(map[string]snapshot),
+	}
+}
+
+// orderState is the serialized form of an order.
+type orderState struct {
+	SnapshotVersion int `json:"snapshot_version"`
+
+	ID         string           `json:"id"`
+	CustomerID string           `json:"customer_id,omitempty"`
+	Status     Status           `json:"status"`
+	Lines      []Line           `json:"lines"`
+	Currency   string           `json:"currency,omitempty"`
+	Discounts  map[string]int64 `json:"discounts,omitempty"`
+	Placed     bool             `json:"placed"`
+	PlacedAt   time.Time        `json:"placed_at"`
+	Version    int              `json:"version"`
+}
+
+// marshalSnapshot returns the JSON encoded state of the order.
+func marshalSnapshot(o Order) ([]byte, error) {
+	return json.Marshal(orderState{
+		SnapshotVersion: SnapshotVersion,
+
+		ID:         o.ID,
+		CustomerID: o.CustomerID,
+		Status:     o.Status,
+		Lines:      o.Lines,
+		Currency:   o.Currency,
+		Discounts:  o.Discounts,
+		Placed:     o.placed,
+		PlacedAt:   o.placedAt,
+		Version:    o.version,
+	})
+}
+
+// unmarshalSnapshot restores an order from its JSON encoded state. States
+// saved with a different snapshot version return ErrSnapshotVersionMismatch.
+func unmarshalSnapshot(data []byte) (Order, error) {
+	var s orderState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Order{}, err
+	}
+
+	if s.SnapshotVersion != SnapshotVersion {
+		return Order{}, fmt.Errorf("%w: expected %d, got %d", ErrSnapshotVersionMismatch, SnapshotVersion, s.SnapshotVersion)
+	}
+
+	o := NewOrder(OrderID(s.ID))
+	o.CustomerID = s.CustomerID
+	o.Status = s.Status
+	o.Lines = s.Lines
+	o.Currency = s.Currency
+	o.Discounts = s.Discounts
+	o.placed = s.Placed
+	o.placedAt = s.PlacedAt
+	o.version = s.Version
+	o.appliedSequence = s.Version
+
+	return o, nil
+}
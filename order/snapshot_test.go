@@ -0,0 +1,293 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// poisoned is an event that must never be applied to an order.
+type poisoned struct{}
+
+func (e poisoned) ID() string {
+	return "POISONED"
+}
+
+// poisonedStore replaces every stored event up to a sequence number with a
+// poisoned event, making it visible whether the events were applied.
+type poisonedStore struct {
+	order.EventStore
+	upTo int
+}
+
+func (s poisonedStore) Load(ctx context.Context, aggregateType, id string) ([]order.PersistedEvent, error) {
+	events, err := s.EventStore.Load(ctx, aggregateType, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.poison(events), nil
+}
+
+func (s poisonedStore) LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) ([]order.PersistedEvent, error) {
+	events, err := s.EventStore.LoadFrom(ctx, aggregateType, id, afterSequence)
+	if err != nil {
+		return nil, err
+	}
+	return s.poison(events), nil
+}
+
+func (s poisonedStore) poison(events []order.PersistedEvent) []order.PersistedEvent {
+	for i := range events {
+		if events[i].Sequence <= s.upTo {
+			events[i].Event = poisoned{}
+		}
+	}
+	return events
+}
+
+func TestLoadFromSnapshot(t *testing.T) {
+	store := order.NewEventStore()
+	snapshots := order.NewSnapshotStore()
+
+	repo := order.NewRepository(store,
+		order.WithSnapshotStore(snapshots),
+		order.WithSnapshotEvery(1),
+	)
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	version, _, err := snapshots.LoadSnapshot("ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if version != 1 {
+		t.Errorf("expected: %v, got: %v", 1, version)
+	}
+
+	repo = order.NewRepository(poisonedStore{EventStore: store, upTo: version},
+		order.WithSnapshotStore(snapshots),
+	)
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.ID != "ABC123" {
+		t.Errorf("expected: %v, got: %v", "ABC123", o.ID)
+	}
+
+	if o.Status != order.StatusPlaced {
+		t.Errorf("expected: %v, got: %v", order.StatusPlaced, o.Status)
+	}
+}
+
+func TestSnapshotEvery(t *testing.T) {
+	snapshots := order.NewSnapshotStore()
+
+	repo := order.NewRepository(order.NewEventStore(),
+		order.WithSnapshotStore(snapshots),
+		order.WithSnapshotEvery(2),
+	)
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, _, err := snapshots.LoadSnapshot("ABC123"); !errors.Is(err, order.ErrSnapshotNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrSnapshotNotFound, err)
+	}
+
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	version, _, err := snapshots.LoadSnapshot("ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if version != 2 {
+		t.Errorf("expected: %v, got: %v", 2, version)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+	}
+}
+
+// versionRecordingSnapshotStore records the versions of the saved snapshots.
+type versionRecordingSnapshotStore struct {
+	order.SnapshotStore
+	versions []int
+}
+
+func (s *versionRecordingSnapshotStore) SaveSnapshot(id string, version int, state []byte) error {
+	s.versions = append(s.versions, version)
+	return s.SnapshotStore.SaveSnapshot(id, version, state)
+}
+
+func TestSnapshotPolicyEveryN(t *testing.T) {
+	snapshots := &versionRecordingSnapshotStore{SnapshotStore: order.NewSnapshotStore()}
+
+	repo := order.NewRepository(order.NewEventStore(),
+		order.WithSnapshotStore(snapshots),
+		order.WithSnapshotPolicy(order.EveryN(5)),
+	)
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 2; i <= 11; i++ {
+		line := order.Line{ProductID: fmt.Sprintf("P%d", i), Quantity: 1, UnitPrice: 100}
+		if err := handler.Handle(context.Background(), order.AddLine{OrderID: "ABC123", Line: line}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if want := []int{5, 10}; !reflect.DeepEqual(snapshots.versions, want) {
+		t.Errorf("expected: %v, got: %v", want, snapshots.versions)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(o.Lines) != 11 {
+		t.Errorf("expected: %v, got: %v", 11, len(o.Lines))
+	}
+}
+
+func TestLoadUnhandledEvent(t *testing.T) {
+	store := order.NewEventStore()
+
+	events := []order.PersistedEvent{
+		{Event: order.Placed{OrderID: "ABC123", Lines: testLines}},
+		{Event: poisoned{}},
+	}
+
+	if err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := order.NewRepository(store).Load(context.Background(), "ABC123")
+	if !errors.Is(err, order.ErrUnhandledEvent) {
+		t.Errorf("expected: %v, got: %v", order.ErrUnhandledEvent, err)
+	}
+}
+
+func TestLoadIgnoresIncompatibleSnapshot(t *testing.T) {
+	store := order.NewEventStore()
+	snapshots := order.NewSnapshotStore()
+
+	repo := order.NewRepository(store, order.WithSnapshotStore(snapshots))
+
+	handler := order.NewCommandHandler(repo)
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.Activate{OrderID: "ABC123"},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// A snapshot saved by an older version, claiming the order was
+	// cancelled.
+	state := fmt.Sprintf(`{"snapshot_version":%d,"id":"ABC123","status":2,"version":2}`, order.SnapshotVersion-1)
+	if err := snapshots.SaveSnapshot("ABC123", 2, []byte(state)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+	}
+	if len(o.Lines) != 1 {
+		t.Errorf("expected: %v, got: %v", 1, len(o.Lines))
+	}
+	if o.Version() != 2 {
+		t.Errorf("expected: %v, got: %v", 2, o.Version())
+	}
+}
+
+func TestSnapshotOnLoad(t *testing.T) {
+	store := order.NewEventStore()
+	snapshots := order.NewSnapshotStore()
+
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.AddLine{OrderID: "ABC123", Line: order.Line{ProductID: "P2", Quantity: 1, UnitPrice: 100}},
+		order.Activate{OrderID: "ABC123"},
+		order.Place{OrderID: "XYZ789", Lines: testLines},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	repo := order.NewRepository(store, order.WithSnapshotStore(snapshots), order.SnapshotOnLoad(2))
+
+	want, err := order.NewRepository(store).Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected: %+v, got: %+v", want, got)
+	}
+
+	version, _, err := snapshots.LoadSnapshot("ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != 3 {
+		t.Errorf("expected: %v, got: %v", 3, version)
+	}
+
+	// Orders with no more events than the threshold aren't snapshotted.
+	if _, err := repo.Load(context.Background(), "XYZ789"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := snapshots.LoadSnapshot("XYZ789"); !errors.Is(err, order.ErrSnapshotNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrSnapshotNotFound, err)
+	}
+
+	// The order is restored from the snapshot from now on.
+	repo = order.NewRepository(poisonedStore{EventStore: store, upTo: 3}, order.WithSnapshotStore(snapshots))
+
+	restored, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restored.Status != order.StatusActivated || len(restored.Lines) != 2 {
+		t.Errorf("expected activated order with 2 lines, got: %+v", restored)
+	}
+}
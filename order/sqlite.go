@@ -0,0 +1,722 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	// Register the pure Go SQLite driver.
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the append-only events table. The unique constraint
+// on (aggregate_type, aggregate_id, sequence) guards against concurrent writers.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS events (
+	global_sequence INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_id        TEXT    NOT NULL DEFAULT '',
+	aggregate_type  TEXT    NOT NULL DEFAULT 'order',
+	aggregate_id    TEXT    NOT NULL,
+	sequence        INTEGER NOT NULL,
+	event_type      TEXT    NOT NULL,
+	payload         TEXT    NOT NULL,
+	occurred_at     TEXT    NOT NULL,
+	correlation_id  TEXT    NOT NULL DEFAULT '',
+	causation_id    TEXT    NOT NULL DEFAULT '',
+	metadata        TEXT    NOT NULL DEFAULT '{}',
+	schema_version  INTEGER NOT NULL DEFAULT 1,
+	UNIQUE (aggregate_type, aggregate_id, sequence)
+)`
+
+// sqliteEventIDIndex keeps an event ID from being saved twice. Events saved
+// without an ID are left out of the index.
+const sqliteEventIDIndex = `
+CREATE UNIQUE INDEX IF NOT EXISTS events_event_id ON events (event_id) WHERE event_id <> ''`
+
+// sqliteRedactionsSchema creates the table recording redactions of events.
+const sqliteRedactionsSchema = `
+CREATE TABLE IF NOT EXISTS redactions (
+	id             INTEGER PRIMARY KEY AUTOINCREMENT,
+	aggregate_type TEXT    NOT NULL,
+	aggregate_id   TEXT    NOT NULL,
+	fields         TEXT    NOT NULL,
+	redacted_at    TEXT    NOT NULL
+)`
+
+// sqliteOutboxSchema creates the outbox, which the events are enqueued to
+// within the save transaction if the store is created with WithSQLiteOutbox.
+const sqliteOutboxSchema = `
+CREATE TABLE IF NOT EXISTS outbox (
+	id             INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_id       TEXT    NOT NULL DEFAULT '',
+	aggregate_type TEXT    NOT NULL DEFAULT 'order',
+	aggregate_id   TEXT    NOT NULL,
+	sequence       INTEGER NOT NULL,
+	event_type     TEXT    NOT NULL,
+	payload        TEXT    NOT NULL,
+	occurred_at    TEXT    NOT NULL,
+	correlation_id TEXT    NOT NULL DEFAULT '',
+	causation_id   TEXT    NOT NULL DEFAULT '',
+	metadata       TEXT    NOT NULL DEFAULT '{}',
+	schema_version INTEGER NOT NULL DEFAULT 1,
+	published_at   TEXT
+)`
+
+// sqliteConstraintUnique is the extended result code reported by SQLite for a
+// violated unique constraint.
+const sqliteConstraintUnique = 2067
+
+// sqliteColumns lists the columns read by scanSQLiteEvents, in order.
+const sqliteColumns = `event_id, aggregate_type, aggregate_id, sequence, global_sequence, event_type, payload, occurred_at, correlation_id, causation_id, metadata, schema_version`
+
+// SQLiteEventStore is an event store backed by a SQLite database, which must
+// be closed when no longer used.
+type SQLiteEventStore interface {
+	EventStore
+	Close() error
+
+	// Outbox returns the outbox table of the database, which the saved
+	// events are enqueued to if the store was created with
+	// WithSQLiteOutbox.
+	Outbox() Outbox
+}
+
+type sqliteEventStore struct {
+	db     *sql.DB
+	outbox bool
+}
+
+// Save inserts the events within a single transaction. A concurrent writer
+// that has already stored an event with the same sequence causes the
+// transaction to be rolled back with a *ConcurrencyError.
+func (s *sqliteEventStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []PersistedEvent) (err error) {
+	ctx, span := startSpan(ctx, "EventStore.Save", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+	))
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.save(ctx, tx, aggregateType, id, expectedVersion, events); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SaveAll inserts the events of every aggregate within a single transaction,
+// so that a conflict on any aggregate rolls back all of them.
+func (s *sqliteEventStore) SaveAll(ctx context.Context, changes []AggregateChanges) (err error) {
+	ctx, span := startSpan(ctx, "EventStore.SaveAll", trace.WithAttributes(
+		attribute.Int("aggregate.count", len(changes)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, c := range changes {
+		if err := s.save(ctx, tx, c.AggregateType, c.AggregateID, c.ExpectedVersion, c.Events); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// save inserts the events of the aggregate within the transaction, checking
+// that the aggregate is at the expected version.
+func (s *sqliteEventStore) save(ctx context.Context, tx *sql.Tx, aggregateType, id string, expectedVersion int, events []PersistedEvent) error {
+	// Saving only events that have already been saved, e.g. when retrying a
+	// save, succeeds without changes.
+	if len(events) > 0 {
+		unsaved, skipped, err := s.unsaved(ctx, tx, events)
+		if err != nil {
+			return err
+		}
+		recordSkipped(ctx, skipped)
+
+		if events = unsaved; len(events) == 0 {
+			return nil
+		}
+	}
+
+	var version int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(sequence), 0) FROM events WHERE aggregate_type = ? AND aggregate_id = ?`, aggregateType, id,
+	).Scan(&version); err != nil {
+		return err
+	}
+
+	if version != expectedVersion {
+		return &ConcurrencyError{AggregateID: id, Expected: expectedVersion, Actual: version}
+	}
+
+	for _, e := range events {
+		payload, name, err := MarshalEvent(e.Event)
+		if err != nil {
+			return err
+		}
+
+		metadata, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return err
+		}
+
+		version++
+
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO events (event_id, aggregate_type, aggregate_id, sequence, event_type, payload, occurred_at, correlation_id, causation_id, metadata, schema_version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.EventID, aggregateType, id, version, name, string(payload), e.OccurredAt.UTC().Format(time.RFC3339Nano), e.Metadata.CorrelationID, e.Metadata.CausationID, string(metadata), schemaVersion(e),
+		); err != nil {
+			if isSQLiteUniqueViolation(err) {
+				return s.conflict(ctx, tx, aggregateType, id, expectedVersion, e)
+			}
+			return err
+		}
+
+		if s.outbox {
+			if err := insertSQLiteOutbox(ctx, tx, aggregateType, id, version, name, payload, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// unsaved splits the events into those that haven't been saved and those
+// that have, judging by their event IDs. Events without an ID are never
+// considered saved.
+func (s *sqliteEventStore) unsaved(ctx context.Context, tx *sql.Tx, events []PersistedEvent) (unsaved, saved []PersistedEvent, err error) {
+	for _, e := range events {
+		if e.EventID == "" {
+			unsaved = append(unsaved, e)
+			continue
+		}
+
+		var n int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM events WHERE event_id = ?`, e.EventID).Scan(&n); err != nil {
+			return nil, nil, err
+		}
+
+		if n == 0 {
+			unsaved = append(unsaved, e)
+		} else {
+			saved = append(saved, e)
+		}
+	}
+	return unsaved, saved, nil
+}
+
+// conflict returns the error for a save of the event against the expected
+// version that was rejected by a unique constraint: ErrDuplicateEventID if
+// its event ID has been saved, e.g. earlier in the same batch, and otherwise
+// a *ConcurrencyError with the version written by the concurrent writer. The
+// queries run within the transaction, since the store has a single
+// connection.
+func (s *sqliteEventStore) conflict(ctx context.Context, tx *sql.Tx, aggregateType, id string, expectedVersion int, e PersistedEvent) error {
+	if e.EventID != "" {
+		var n int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM events WHERE event_id = ?`, e.EventID).Scan(&n); err != nil {
+			return err
+		}
+
+		if n > 0 {
+			return fmt.Errorf("%w: %s", ErrDuplicateEventID, e.EventID)
+		}
+	}
+
+	var version int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(sequence), 0) FROM events WHERE aggregate_type = ? AND aggregate_id = ?`, aggregateType, id,
+	).Scan(&version); err != nil {
+		return err
+	}
+
+	return &ConcurrencyError{AggregateID: id, Expected: expectedVersion, Actual: version}
+}
+
+// Load returns the events for the aggregate in sequence order.
+func (s *sqliteEventStore) Load(ctx context.Context, aggregateType, id string) ([]PersistedEvent, error) {
+	result, err := s.LoadFrom(ctx, aggregateType, id, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, ErrOrderNotFound
+	}
+
+	return result, nil
+}
+
+// LoadFrom returns the events for the aggregate with a sequence number greater
+// than afterSequence, in sequence order.
+func (s *sqliteEventStore) LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.Load", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+		attribute.Int("sequence.after", afterSequence),
+	))
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+sqliteColumns+` FROM events WHERE aggregate_type = ? AND aggregate_id = ? AND sequence > ? ORDER BY sequence`, aggregateType, id, afterSequence,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSQLiteEvents(rows)
+}
+
+// LoadPage returns up to limit events for the aggregate with a sequence number
+// greater than afterSequence, in sequence order, along with the cursor to pass
+// as afterSequence for the next page. The cursor is zero on the last page.
+func (s *sqliteEventStore) LoadPage(ctx context.Context, aggregateType, id string, afterSequence, limit int) (_ []PersistedEvent, _ int, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadPage", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+		attribute.Int("sequence.after", afterSequence),
+		attribute.Int("page.limit", limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	// Read one more event than requested to tell whether more events follow.
+	// A negative limit makes SQLite return all rows.
+	n := -1
+	if limit > 0 {
+		n = limit + 1
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+sqliteColumns+` FROM events WHERE aggregate_type = ? AND aggregate_id = ? AND sequence > ? ORDER BY sequence LIMIT ?`, aggregateType, id, afterSequence, n,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, err := scanSQLiteEvents(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events, next := page(result, limit)
+
+	return events, next, nil
+}
+
+// LoadMany returns the events for each of the aggregates in sequence order,
+// keyed by aggregate ID, using a single query. Aggregates without events are
+// absent from the result.
+func (s *sqliteEventStore) LoadMany(ctx context.Context, aggregateType string, ids []string) (_ map[string][]PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadMany", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.Int("aggregate.count", len(ids)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	result := make(map[string][]PersistedEvent)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, aggregateType)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+sqliteColumns+` FROM events WHERE aggregate_type = ? AND aggregate_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) ORDER BY aggregate_id, sequence`, args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := scanSQLiteEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range events {
+		result[e.AggregateID] = append(result[e.AggregateID], e)
+	}
+
+	return result, nil
+}
+
+// LoadAll returns the events of all orders in the order they were saved.
+func (s *sqliteEventStore) LoadAll(ctx context.Context) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadAll")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+sqliteColumns+` FROM events ORDER BY global_sequence`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSQLiteEvents(rows)
+}
+
+// LoadAllFrom returns up to limit events of all orders with a global position
+// greater than the given position, in the order they were saved. A limit of
+// zero or less returns all of them.
+func (s *sqliteEventStore) LoadAllFrom(ctx context.Context, position int64, limit int) (_ []PersistedEvent, err error) {
+	ctx, span := startSpan(ctx, "EventStore.LoadAllFrom", trace.WithAttributes(
+		attribute.Int64("position.after", position),
+		attribute.Int("page.limit", limit),
+	))
+	defer func() { endSpan(span, err) }()
+
+	// A negative limit makes SQLite return all rows.
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+sqliteColumns+` FROM events WHERE global_sequence > ? ORDER BY global_sequence LIMIT ?`, position, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSQLiteEvents(rows)
+}
+
+// Stream sends the events for the aggregate in sequence order on the returned
+// channel, which is closed once all of them have been sent, the context is
+// cancelled or an error occurred. The error, if any, is sent on the error
+// channel.
+func (s *sqliteEventStore) Stream(ctx context.Context, aggregateType, id string) (<-chan PersistedEvent, <-chan error) {
+	return stream(ctx, s, aggregateType, id)
+}
+
+// Close closes the database.
+func (s *sqliteEventStore) Close() error {
+	return s.db.Close()
+}
+
+// scanSQLiteEvents reads and closes the rows of a query selecting
+// sqliteColumns.
+func scanSQLiteEvents(rows *sql.Rows) ([]PersistedEvent, error) {
+	defer rows.Close()
+
+	result := []PersistedEvent{}
+	for rows.Next() {
+		var (
+			e          PersistedEvent
+			name       string
+			payload    string
+			occurredAt string
+			metadata   string
+			err        error
+		)
+		if err := rows.Scan(&e.EventID, &e.AggregateType, &e.AggregateID, &e.Sequence, &e.GlobalPosition, &name, &payload, &occurredAt, &e.Metadata.CorrelationID, &e.Metadata.CausationID, &metadata, &e.SchemaVersion); err != nil {
+			return nil, err
+		}
+
+		if err := unmarshalMetadata([]byte(metadata), &e.Metadata); err != nil {
+			return nil, err
+		}
+
+		e.OccurredAt, err = time.Parse(time.RFC3339Nano, occurredAt)
+		if err != nil {
+			return nil, err
+		}
+
+		e.Event, e.SchemaVersion, err = decodeEvent(name, e.SchemaVersion, []byte(payload))
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// isSQLiteUniqueViolation reports whether the driver error is a unique
+// constraint violation.
+func isSQLiteUniqueViolation(err error) bool {
+	var e interface{ Code() int }
+	return errors.As(err, &e) && e.Code() == sqliteConstraintUnique
+}
+
+// Redact overwrites the fields in the stored payloads of the events of the
+// aggregate, recording the redaction in the same transaction.
+func (s *sqliteEventStore) Redact(ctx context.Context, aggregateType, id string, fields []string) (err error) {
+	ctx, span := startSpan(ctx, "EventStore.Redact", trace.WithAttributes(
+		attribute.String("aggregate.type", aggregateType),
+		attribute.String("aggregate.id", id),
+	))
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT global_sequence, payload FROM events WHERE aggregate_type = ? AND aggregate_id = ? ORDER BY sequence`, aggregateType, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	type row struct {
+		position int64
+		payload  string
+	}
+
+	var stored []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.position, &r.payload); err != nil {
+			rows.Close()
+			return err
+		}
+		stored = append(stored, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(stored) == 0 {
+		return ErrOrderNotFound
+	}
+
+	var changed bool
+	for _, r := range stored {
+		redacted, ok, err := redactPayload([]byte(r.payload), fields)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE events SET payload = ? WHERE global_sequence = ?`, string(redacted), r.position,
+		); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if changed {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO redactions (aggregate_type, aggregate_id, fields, redacted_at) VALUES (?, ?, ?, ?)`,
+			aggregateType, id, strings.Join(fields, ","), time.Now().UTC().Format(time.RFC3339Nano),
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Redactions returns the recorded redactions in the order they were made.
+func (s *sqliteEventStore) Redactions(ctx context.Context) ([]Redaction, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT aggregate_type, aggregate_id, fields, redacted_at FROM redactions ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Redaction
+	for rows.Next() {
+		var (
+			r                  Redaction
+			fields, redactedAt string
+		)
+		if err := rows.Scan(&r.AggregateType, &r.AggregateID, &fields, &redactedAt); err != nil {
+			return nil, err
+		}
+
+		r.Fields = strings.Split(fields, ",")
+		if r.RedactedAt, err = time.Parse(time.RFC3339Nano, redactedAt); err != nil {
+			return nil, err
+		}
+
+		result = append(result, r)
+	}
+
+	return result, rows.Err()
+}
+
+// Outbox returns the outbox table of the database.
+func (s *sqliteEventStore) Outbox() Outbox {
+	return &sqliteOutbox{db: s.db}
+}
+
+// SQLiteOption configures the SQLite event store.
+type SQLiteOption func(*sqliteEventStore)
+
+// WithSQLiteOutbox makes the event store enqueue the saved events to the
+// outbox table within the same transaction. Use the outbox returned by
+// Outbox to publish them.
+func WithSQLiteOutbox() SQLiteOption {
+	return func(s *sqliteEventStore) {
+		s.outbox = true
+	}
+}
+
+// NewSQLiteEventStore opens the SQLite database given by the data source name,
+// e.g. a file path, and creates the tables unless they already exist.
+// The database is limited to a single connection, since SQLite serializes
+// writers anyway.
+func NewSQLiteEventStore(dsn string, opts ...SQLiteOption) (SQLiteEventStore, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	for _, schema := range []string{sqliteSchema, sqliteEventIDIndex, sqliteRedactionsSchema, sqliteOutboxSchema} {
+		if _, err := db.Exec(schema); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	s := &sqliteEventStore{db: db}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// insertSQLiteOutbox adds an encoded event to the outbox table.
+func insertSQLiteOutbox(ctx context.Context, db execer, aggregateType, id string, sequence int, name string, payload []byte, e PersistedEvent) error {
+	metadata, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO outbox (event_id, aggregate_type, aggregate_id, sequence, event_type, payload, occurred_at, correlation_id, causation_id, metadata, schema_version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.EventID, aggregateType, id, sequence, name, string(payload), e.OccurredAt.UTC().Format(time.RFC3339Nano), e.Metadata.CorrelationID, e.Metadata.CausationID, string(metadata), schemaVersion(e),
+	)
+	return err
+}
+
+type sqliteOutbox struct {
+	db *sql.DB
+}
+
+// Enqueue inserts the events into the outbox table within a single
+// transaction.
+func (o *sqliteOutbox) Enqueue(ctx context.Context, events []PersistedEvent) error {
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, e := range events {
+		payload, name, err := MarshalEvent(e.Event)
+		if err != nil {
+			return err
+		}
+		if err := insertSQLiteOutbox(ctx, tx, e.AggregateType, e.AggregateID, e.Sequence, name, payload, e); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Pending returns up to limit unpublished messages in the order they were
+// enqueued. A limit of zero or less returns all of them.
+func (o *sqliteOutbox) Pending(ctx context.Context, limit int) ([]OutboxMessage, error) {
+	// A negative limit makes SQLite return all rows.
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := o.db.QueryContext(ctx,
+		`SELECT id, event_id, aggregate_type, aggregate_id, sequence, event_type, payload, occurred_at, correlation_id, causation_id, metadata, schema_version FROM outbox WHERE published_at IS NULL ORDER BY id LIMIT ?`, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []OutboxMessage
+	for rows.Next() {
+		var (
+			m          OutboxMessage
+			name       string
+			payload    string
+			occurredAt string
+			metadata   string
+		)
+		if err := rows.Scan(&m.ID, &m.Event.EventID, &m.Event.AggregateType, &m.Event.AggregateID, &m.Event.Sequence, &name, &payload, &occurredAt, &m.Event.Metadata.CorrelationID, &m.Event.Metadata.CausationID, &metadata, &m.Event.SchemaVersion); err != nil {
+			return nil, err
+		}
+
+		if m.Event.OccurredAt, err = time.Parse(time.RFC3339Nano, occurredAt); err != nil {
+			return nil, err
+		}
+
+		if err := unmarshalMetadata([]byte(metadata), &m.Event.Metadata); err != nil {
+			return nil, err
+		}
+
+		m.Event.Event, m.Event.SchemaVersion, err = decodeEvent(name, m.Event.SchemaVersion, []byte(payload))
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, m)
+	}
+
+	return result, rows.Err()
+}
+
+// MarkPublished sets the time the messages were published.
+func (o *sqliteOutbox) MarkPublished(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, time.Now().UTC().Format(time.RFC3339Nano))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	_, err := o.db.ExecContext(ctx,
+		`UPDATE outbox SET published_at = ? WHERE id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`, args...,
+	)
+	return err
+}
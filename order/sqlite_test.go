@@ -0,0 +1,207 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestSQLiteEventStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	now := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	store, err := order.NewSQLiteEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := order.NewCommandHandler(order.NewRepository(store), order.WithClock(stubClock{now: now}))
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "XYZ789", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store, err = order.NewSQLiteEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	repo := order.NewRepository(store)
+	handler = order.NewCommandHandler(repo, order.WithClock(stubClock{now: now}))
+
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, o.Status)
+	}
+
+	events, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(events))
+	}
+
+	if events[1].Sequence != 2 || events[1].GlobalPosition != 3 {
+		t.Errorf("unexpected sequence numbers: %+v", events[1])
+	}
+
+	if !events[0].OccurredAt.Equal(now) {
+		t.Errorf("expected: %v, got: %v", now, events[0].OccurredAt)
+	}
+
+	if err := store.Save(context.Background(), order.AggregateTypeOrder, "XYZ789", 0, nil); !errors.Is(err, order.ErrConcurrencyConflict) {
+		t.Errorf("expected: %v, got: %v", order.ErrConcurrencyConflict, err)
+	}
+}
+
+func TestSQLiteEventStoreLoadMany(t *testing.T) {
+	store, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	for _, id := range []string{"ABC123", "XYZ789"} {
+		if err := handler.Handle(context.Background(), order.Place{OrderID: order.OrderID(id), Lines: testLines}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	events, err := store.LoadMany(context.Background(), order.AggregateTypeOrder, []string{"ABC123", "XYZ789", "MISSING"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Errorf("expected: %v, got: %v", 2, len(events))
+	}
+
+	if _, ok := events["MISSING"]; ok {
+		t.Errorf("expected missing order to be absent")
+	}
+}
+
+func TestSQLiteEventStoreMetadata(t *testing.T) {
+	store, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	want := order.Metadata{
+		CorrelationID: "CMD1",
+		CausationID:   "CMD2",
+		TenantID:      "acme",
+		UserID:        "alice",
+		Values:        map[string]string{"source": "web"},
+	}
+
+	events := placedEvent("ABC123")
+	events[0].Metadata = want
+
+	if err := store.Save(context.Background(), order.AggregateTypeOrder, "ABC123", 0, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(loaded) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(loaded))
+	}
+
+	if !reflect.DeepEqual(loaded[0].Metadata, want) {
+		t.Errorf("expected: %+v, got: %+v", want, loaded[0].Metadata)
+	}
+}
+
+func TestSQLiteEventStoreDuplicateEventID(t *testing.T) {
+	store, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := append(placedEvent("ABC123"), placedEvent("ABC123")...)
+	for i := range events {
+		events[i].EventID = "0157b0c4-a400-7000-8000-000000000001"
+	}
+
+	if err := store.Save(ctx, order.AggregateTypeOrder, "ABC123", 0, events); !errors.Is(err, order.ErrDuplicateEventID) {
+		t.Fatalf("expected: %v, got: %v", order.ErrDuplicateEventID, err)
+	}
+
+	if _, err := store.Load(ctx, order.AggregateTypeOrder, "ABC123"); !errors.Is(err, order.ErrOrderNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrOrderNotFound, err)
+	}
+}
+
+func TestSQLiteOutbox(t *testing.T) {
+	store, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"), order.WithSQLiteOutbox())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	repo := order.NewRepository(store)
+
+	handler := order.NewCommandHandler(repo)
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bus := order.NewEventBus()
+
+	var received []order.PersistedEvent
+	bus.Subscribe(func(e order.PersistedEvent) {
+		received = append(received, e)
+	})
+
+	publisher := order.NewOutboxPublisher(store.Outbox(), bus)
+
+	for i, want := range []int{1, 0} {
+		n, err := publisher.Publish(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != want {
+			t.Errorf("poll %d: expected: %v, got: %v", i, want, n)
+		}
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(received))
+	}
+
+	if _, ok := received[0].Event.(order.Placed); !ok {
+		t.Errorf("expected: %T, got: %T", order.Placed{}, received[0].Event)
+	}
+}
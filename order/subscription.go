@@ -0,0 +1,283 @@
+package order
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrSubscriptionsNotSupported is returned when subscribing to an event store
+// that doesn't support catch-up subscriptions.
+var ErrSubscriptionsNotSupported = errors.New("event store does not support subscriptions")
+
+// SubscribableStore is implemented by event stores supporting catch-up
+// subscriptions.
+type SubscribableStore interface {
+	EventStore
+
+	// Subscribe delivers every stored event after the given global position
+	// to the handler, and then continues with each new event as it is saved.
+	// A negative position is treated as zero. An error returned while
+	// catching up is returned from Subscribe. Events saved by the handler itself are delivered once it returns.
+	Subscribe(fromPosition int64, handler func(PersistedEvent) error, opts ...SubscriptionOption) (*Subscription, error)
+}
+
+// SubscriptionOption configures a catch-up subscription.
+type SubscriptionOption func(*Subscription)
+
+// WithDeadLetters makes the subscription try each event up to maxAttempts
+// times, and then park it in the dead-letter store and move on to the next
+// event instead of stopping.
+func WithDeadLetters(store DeadLetterStore, maxAttempts int) SubscriptionOption {
+	return func(s *Subscription) {
+		s.deadLetters = store
+		s.maxAttempts = maxAttempts
+	}
+}
+
+// WithFilter makes the subscription only hand the events matching the filter
+// to the handler. Other events are skipped, but still advance the position.
+func WithFilter(filter EventFilter) SubscriptionOption {
+	return func(s *Subscription) {
+		s.filter = filter
+	}
+}
+
+// WithCheckpoints makes the subscription save the global position of the last
+// handled event to the checkpoint store under the projection name, after each
+// batch of events has been handled successfully. Resuming the projection
+// from the loaded checkpoint skips the events it has already processed.
+// Failing to save a checkpoint stops the subscription.
+func WithCheckpoints(store CheckpointStore, projectionName string) SubscriptionOption {
+	return func(s *Subscription) {
+		s.checkpoints = store
+		s.projectionName = projectionName
+	}
+}
+
+// Subscription is a subscription to the events of all orders. It keeps track
+// of the global position of the last successfully handled event, so that a
+// restarted subscriber can resume from where it left off.
+//
+// If the handler returns an error, the subscription stops and no further
+// events are delivered, unless a dead-letter store is configured.
+type Subscription struct {
+	mu          sync.Mutex
+	handler     func(PersistedEvent) error
+	position    int64
+	err         error
+	closed      bool
+	deadLetters DeadLetterStore
+	maxAttempts int
+	filter      EventFilter
+
+	checkpoints    CheckpointStore
+	projectionName string
+
+	// queue holds the saved events waiting to be delivered, and draining
+	// is set while a goroutine delivers them. Both are guarded by queueMu
+	// rather than mu, which is held while the handler runs.
+	queueMu     sync.Mutex
+	queue       []PersistedEvent
+	draining    bool
+	unsubscribe func()
+}
+
+// Position returns the global position of the last successfully handled
+// event.
+func (s *Subscription) Position() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.position
+}
+
+// Err returns the error that stopped the subscription, if any.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Close stops the delivery of events to the subscription and removes it from
+// the store.
+func (s *Subscription) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.queueMu.Lock()
+	s.queue = nil
+	s.queueMu.Unlock()
+
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+	}
+}
+
+// enqueue adds the events to those waiting to be delivered.
+func (s *Subscription) enqueue(events []PersistedEvent) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	s.queue = append(s.queue, events...)
+}
+
+// drain delivers the queued events, including events queued while doing so,
+// and returns the error that stopped the subscription, if any. If another
+// goroutine is already delivering events, such as a handler saving to the
+// store, drain returns at once and leaves the events to that goroutine.
+func (s *Subscription) drain() error {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	if s.draining {
+		return nil
+	}
+
+	s.draining = true
+	defer func() { s.draining = false }()
+
+	for len(s.queue) > 0 {
+		events := s.queue
+		s.queue = nil
+
+		s.queueMu.Unlock()
+		err := s.deliver(events)
+		s.queueMu.Lock()
+
+		if err != nil {
+			s.queue = nil
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliver hands the events to the handler, skipping events at or before the
+// current position, and saves a checkpoint once the batch has been handled.
+func (s *Subscription) deliver(events []PersistedEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := s.position
+	for _, e := range events {
+		if s.closed || s.err != nil {
+			return s.err
+		}
+
+		if e.GlobalPosition <= s.position {
+			continue
+		}
+
+		if s.filter != nil && !s.filter(e) {
+			s.position = e.GlobalPosition
+			continue
+		}
+
+		if err := s.handle(e); err != nil {
+			if s.deadLetters == nil {
+				s.err = err
+				return err
+			}
+			s.deadLetters.Park(e, err)
+		}
+
+		s.position = e.GlobalPosition
+	}
+
+	if s.checkpoints != nil && s.position != start {
+		if err := s.checkpoints.Save(s.projectionName, s.position); err != nil {
+			s.err = err
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handle hands the event to the handler, retrying up to the configured number
+// of attempts when a dead-letter store is configured. The error of the last
+// attempt is returned.
+func (s *Subscription) handle(e PersistedEvent) error {
+	attempts := 1
+	if s.deadLetters != nil && s.maxAttempts > 1 {
+		attempts = s.maxAttempts
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = s.handler(e); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// Subscribe delivers every stored event after the given global position to
+// the handler, and then continues with each new event as it is saved.
+// A negative position is treated as zero.
+func (s *eventStore) Subscribe(fromPosition int64, handler func(PersistedEvent) error, opts ...SubscriptionOption) (*Subscription, error) {
+	if fromPosition < 0 {
+		fromPosition = 0
+	}
+
+	sub := &Subscription{
+		handler:  handler,
+		position: fromPosition,
+	}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	sub.unsubscribe = func() { s.unsubscribe(sub) }
+
+	s.mu.Lock()
+
+	// Queue the backlog before any event saved in the meantime, so that the
+	// events are delivered in order.
+	if n := int(fromPosition); n < len(s.events) {
+		sub.enqueue(s.events[n:])
+	}
+
+	s.subscriptions = append(s.subscriptions, sub)
+
+	s.mu.Unlock()
+
+	if err := sub.drain(); err != nil {
+		return sub, err
+	}
+
+	return sub, nil
+}
+
+// subscribeDecoded subscribes the handler to the events of the store, decoding
+// each event before it is filtered and handed to the handler. It lets event
+// stores transforming the stored events, such as the encrypting and
+// compressing stores, support the subscriptions of the store they wrap.
+func subscribeDecoded(store EventStore, fromPosition int64, decode func(PersistedEvent) (PersistedEvent, error), handler func(PersistedEvent) error, opts []SubscriptionOption) (*Subscription, error) {
+	subscribable, ok := store.(SubscribableStore)
+	if !ok {
+		return nil, ErrSubscriptionsNotSupported
+	}
+
+	// The filter has to see the decoded events rather than the stored ones,
+	// so it is applied here instead of by the underlying subscription.
+	var config Subscription
+	for _, opt := range opts {
+		opt(&config)
+	}
+	filter := config.filter
+
+	return subscribable.Subscribe(fromPosition, func(e PersistedEvent) error {
+		e, err := decode(e)
+		if err != nil {
+			return err
+		}
+		if filter != nil && !filter(e) {
+			return nil
+		}
+		return handler(e)
+	}, append(opts[:len(opts):len(opts)], WithFilter(nil))...)
+}
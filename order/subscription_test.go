@@ -0,0 +1,337 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestCatchUpSubscription(t *testing.T) {
+	store := order.NewEventStore().(order.SubscribableStore)
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	for i := 0; i < 3; i++ {
+		if err := handler.Handle(context.Background(), order.Place{OrderID: order.OrderID(fmt.Sprintf("ORDER%d", i)), Lines: testLines}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var first []order.PersistedEvent
+	sub, err := store.Subscribe(0, func(e order.PersistedEvent) error {
+		first = append(first, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(first) != 3 {
+		t.Fatalf("expected: %v, got: %v", 3, len(first))
+	}
+
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ORDER0"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(first) != 4 {
+		t.Fatalf("expected: %v, got: %v", 4, len(first))
+	}
+
+	// Restart the subscriber from the last handled position.
+	sub.Close()
+	position := sub.Position()
+
+	if err := handler.Handle(context.Background(), order.Activate{OrderID: "ORDER1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var second []order.PersistedEvent
+	if _, err := store.Subscribe(position, func(e order.PersistedEvent) error {
+		second = append(second, e)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(first) != 4 {
+		t.Errorf("expected closed subscription to receive no events, got: %v", len(first)-4)
+	}
+
+	if len(second) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(second))
+	}
+
+	if second[0].GlobalPosition != position+1 {
+		t.Errorf("expected: %v, got: %v", position+1, second[0].GlobalPosition)
+	}
+
+	if second[0].AggregateID != "ORDER1" {
+		t.Errorf("expected: %v, got: %v", "ORDER1", second[0].AggregateID)
+	}
+}
+
+func TestCatchUpSubscriptionNegativePosition(t *testing.T) {
+	store := order.NewEventStore().(order.SubscribableStore)
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var received []order.PersistedEvent
+	sub, err := store.Subscribe(-1, func(e order.PersistedEvent) error {
+		received = append(received, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sub.Close()
+
+	if len(received) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(received))
+	}
+
+	if sub.Position() != 1 {
+		t.Errorf("expected: %v, got: %v", 1, sub.Position())
+	}
+}
+
+func TestCatchUpSubscriptionHandlerSaves(t *testing.T) {
+	store := order.NewEventStore().(order.SubscribableStore)
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	// Activate every order once it has been placed, saving to the store
+	// from within the handler.
+	var received []order.PersistedEvent
+	if _, err := store.Subscribe(0, func(e order.PersistedEvent) error {
+		received = append(received, e)
+		if _, ok := e.Event.(order.Placed); ok {
+			return handler.Handle(context.Background(), order.Activate{OrderID: order.OrderID(e.AggregateID)})
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out saving events")
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(received))
+	}
+	if _, ok := received[1].Event.(order.Activated); !ok {
+		t.Errorf("expected: %T, got: %T", order.Activated{}, received[1].Event)
+	}
+}
+
+func TestCatchUpSubscriptionHandlerError(t *testing.T) {
+	store := order.NewEventStore().(order.SubscribableStore)
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	for i := 0; i < 2; i++ {
+		if err := handler.Handle(context.Background(), order.Place{OrderID: order.OrderID(fmt.Sprintf("ORDER%d", i)), Lines: testLines}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	errHandle := fmt.Errorf("projection failed")
+
+	sub, err := store.Subscribe(0, func(e order.PersistedEvent) error {
+		if e.GlobalPosition == 2 {
+			return errHandle
+		}
+		return nil
+	})
+	if err != errHandle {
+		t.Fatalf("expected: %v, got: %v", errHandle, err)
+	}
+
+	if sub.Position() != 1 {
+		t.Errorf("expected: %v, got: %v", 1, sub.Position())
+	}
+}
+
+func TestCatchUpSubscriptionDeadLetters(t *testing.T) {
+	store := order.NewEventStore().(order.SubscribableStore)
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	for i := 0; i < 2; i++ {
+		if err := handler.Handle(context.Background(), order.Place{OrderID: order.OrderID(fmt.Sprintf("ORDER%d", i)), Lines: testLines}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	errHandle := fmt.Errorf("projection failed")
+
+	var (
+		attempts int
+		failing  = true
+	)
+	handle := func(e order.PersistedEvent) error {
+		if e.AggregateID != "ORDER0" {
+			return nil
+		}
+		attempts++
+		if failing {
+			return errHandle
+		}
+		return nil
+	}
+
+	deadLetters := order.NewDeadLetterStore(handle)
+
+	sub, err := store.Subscribe(0, handle, order.WithDeadLetters(deadLetters, 3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if attempts != 3 {
+		t.Errorf("expected: %v, got: %v", 3, attempts)
+	}
+
+	if sub.Position() != 2 {
+		t.Errorf("expected: %v, got: %v", 2, sub.Position())
+	}
+
+	letters := deadLetters.List()
+	if len(letters) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(letters))
+	}
+
+	if letters[0].Event.AggregateID != "ORDER0" {
+		t.Errorf("expected: %v, got: %v", "ORDER0", letters[0].Event.AggregateID)
+	}
+
+	if letters[0].Err != errHandle {
+		t.Errorf("expected: %v, got: %v", errHandle, letters[0].Err)
+	}
+
+	if err := deadLetters.Replay(letters[0].ID); err != errHandle {
+		t.Errorf("expected: %v, got: %v", errHandle, err)
+	}
+
+	failing = false
+
+	if err := deadLetters.Replay(letters[0].ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n := len(deadLetters.List()); n != 0 {
+		t.Errorf("expected: %v, got: %v", 0, n)
+	}
+
+	if err := deadLetters.Replay(letters[0].ID); !errors.Is(err, order.ErrDeadLetterNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrDeadLetterNotFound, err)
+	}
+}
+
+func TestCatchUpSubscriptionFilter(t *testing.T) {
+	store := order.NewEventStore().(order.SubscribableStore)
+	handler := order.NewCommandHandler(order.NewRepository(store))
+
+	var received []order.PersistedEvent
+	sub, err := store.Subscribe(0, func(e order.PersistedEvent) error {
+		received = append(received, e)
+		return nil
+	}, order.WithFilter(order.ByType(order.Activated{})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.Activate{OrderID: "ABC123"},
+		order.Place{OrderID: "XYZ789", Lines: testLines},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, len(received))
+	}
+	if _, ok := received[0].Event.(order.Activated); !ok {
+		t.Errorf("expected: %T, got: %T", order.Activated{}, received[0].Event)
+	}
+
+	if sub.Position() != 3 {
+		t.Errorf("expected: %v, got: %v", 3, sub.Position())
+	}
+}
+
+func TestCatchUpSubscriptionCheckpoints(t *testing.T) {
+	store := order.NewEventStore().(order.SubscribableStore)
+	handler := order.NewCommandHandler(order.NewRepository(store))
+	checkpoints := order.NewCheckpointStore()
+
+	place := func(ids ...string) {
+		for _, id := range ids {
+			if err := handler.Handle(context.Background(), order.Place{OrderID: order.OrderID(id), Lines: testLines}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	}
+
+	place("ORDER0", "ORDER1", "ORDER2")
+
+	var first []string
+	sub, err := store.Subscribe(0, func(e order.PersistedEvent) error {
+		first = append(first, e.AggregateID)
+		return nil
+	}, order.WithCheckpoints(checkpoints, "summary"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sub.Close()
+
+	position, err := checkpoints.Load("summary")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if position != 3 {
+		t.Fatalf("expected: %v, got: %v", 3, position)
+	}
+
+	place("ORDER3", "ORDER4")
+
+	// Resume the projection from the checkpoint.
+	var second []string
+	if _, err := store.Subscribe(position, func(e order.PersistedEvent) error {
+		second = append(second, e.AggregateID)
+		return nil
+	}, order.WithCheckpoints(checkpoints, "summary")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(first) != 3 {
+		t.Errorf("expected: %v, got: %v", 3, len(first))
+	}
+	if len(second) != 2 || second[0] != "ORDER3" || second[1] != "ORDER4" {
+		t.Errorf("expected: %v, got: %v", []string{"ORDER3", "ORDER4"}, second)
+	}
+
+	if position, _ := checkpoints.Load("summary"); position != 5 {
+		t.Errorf("expected: %v, got: %v", 5, position)
+	}
+	if position, _ := checkpoints.Load("unknown"); position != 0 {
+		t.Errorf("expected: %v, got: %v", 0, position)
+	}
+}
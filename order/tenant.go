@@ -0,0 +1,266 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMissingTenant is returned by a tenant event store when the context
+// doesn't carry a tenant.
+var ErrMissingTenant = errors.New("missing tenant")
+
+// ErrInvalidTenant is returned by a tenant event store for a tenant ID that
+// contains the separator used to scope aggregate IDs.
+var ErrInvalidTenant = errors.New("invalid tenant")
+
+// tenantSeparator separates the tenant ID from the aggregate ID in the
+// underlying store.
+const tenantSeparator = "/"
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of the context carrying the tenant, so that tenant
+// event stores only read and write the events of that tenant.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant carried by the context, if any.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+type tenantEventStore struct {
+	store EventStore
+}
+
+// prefix returns the prefix of the aggregate IDs of the tenant carried by the
+// context.
+func (s *tenantEventStore) prefix(ctx context.Context) (string, error) {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return "", ErrMissingTenant
+	}
+
+	if strings.Contains(tenantID, tenantSeparator) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTenant, tenantID)
+	}
+
+	return tenantID + tenantSeparator, nil
+}
+
+// Save saves the events of the aggregate of the tenant.
+func (s *tenantEventStore) Save(ctx context.Context, aggregateType, id string, expectedVersion int, events []PersistedEvent) error {
+	prefix, err := s.prefix(ctx)
+	if err != nil {
+		return err
+	}
+
+	scoped := make([]PersistedEvent, len(events))
+	for i, e := range events {
+		e.AggregateID = prefix + id
+		scoped[i] = e
+	}
+
+	err = s.store.Save(ctx, aggregateType, prefix+id, expectedVersion, scoped)
+
+	var cerr *ConcurrencyError
+	if errors.As(err, &cerr) {
+		return &ConcurrencyError{AggregateID: id, Expected: cerr.Expected, Actual: cerr.Actual}
+	}
+
+	return err
+}
+
+// SaveAll saves the events of the aggregates of the tenant in a single
+// transaction, if the underlying store supports it.
+func (s *tenantEventStore) SaveAll(ctx context.Context, changes []AggregateChanges) error {
+	store, ok := s.store.(TransactionalStore)
+	if !ok {
+		return ErrTransactionsNotSupported
+	}
+
+	prefix, err := s.prefix(ctx)
+	if err != nil {
+		return err
+	}
+
+	scoped := make([]AggregateChanges, len(changes))
+	for i, c := range changes {
+		events := make([]PersistedEvent, len(c.Events))
+		for j, e := range c.Events {
+			e.AggregateID = prefix + c.AggregateID
+			events[j] = e
+		}
+
+		c.AggregateID = prefix + c.AggregateID
+		c.Events = events
+		scoped[i] = c
+	}
+
+	err = store.SaveAll(ctx, scoped)
+
+	var cerr *ConcurrencyError
+	if errors.As(err, &cerr) {
+		return &ConcurrencyError{AggregateID: strings.TrimPrefix(cerr.AggregateID, prefix), Expected: cerr.Expected, Actual: cerr.Actual}
+	}
+
+	return err
+}
+
+// Load returns the events of the aggregate of the tenant in sequence order.
+func (s *tenantEventStore) Load(ctx context.Context, aggregateType, id string) ([]PersistedEvent, error) {
+	prefix, err := s.prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.store.Load(ctx, aggregateType, prefix+id)
+	if err != nil {
+		return nil, err
+	}
+
+	return unscope(prefix, events), nil
+}
+
+// LoadFrom returns the events of the aggregate of the tenant with a sequence
+// number greater than afterSequence, in sequence order.
+func (s *tenantEventStore) LoadFrom(ctx context.Context, aggregateType, id string, afterSequence int) ([]PersistedEvent, error) {
+	prefix, err := s.prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.store.LoadFrom(ctx, aggregateType, prefix+id, afterSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	return unscope(prefix, events), nil
+}
+
+// LoadPage returns a page of the events of the aggregate of the tenant.
+func (s *tenantEventStore) LoadPage(ctx context.Context, aggregateType, id string, afterSequence, limit int) ([]PersistedEvent, int, error) {
+	prefix, err := s.prefix(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events, next, err := s.store.LoadPage(ctx, aggregateType, prefix+id, afterSequence, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return unscope(prefix, events), next, nil
+}
+
+// LoadMany returns the events of each of the aggregates of the tenant, keyed
+// by aggregate ID.
+func (s *tenantEventStore) LoadMany(ctx context.Context, aggregateType string, ids []string) (map[string][]PersistedEvent, error) {
+	prefix, err := s.prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scoped := make([]string, len(ids))
+	for i, id := range ids {
+		scoped[i] = prefix + id
+	}
+
+	histories, err := s.store.LoadMany(ctx, aggregateType, scoped)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]PersistedEvent, len(histories))
+	for id, events := range histories {
+		result[strings.TrimPrefix(id, prefix)] = unscope(prefix, events)
+	}
+
+	return result, nil
+}
+
+// LoadAll returns the events of all orders of the tenant in the order they
+// were saved. The events of all tenants are read from the underlying store.
+func (s *tenantEventStore) LoadAll(ctx context.Context) ([]PersistedEvent, error) {
+	prefix, err := s.prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.store.LoadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []PersistedEvent{}
+	for _, e := range events {
+		if strings.HasPrefix(e.AggregateID, prefix) {
+			result = append(result, e)
+		}
+	}
+
+	return unscope(prefix, result), nil
+}
+
+// LoadAllFrom returns up to limit events of all orders of the tenant with a
+// global position greater than the given position, in the order they were
+// saved. The events of other tenants are skipped, reading pages of the
+// underlying store until enough events have been found.
+func (s *tenantEventStore) LoadAllFrom(ctx context.Context, position int64, limit int) ([]PersistedEvent, error) {
+	prefix, err := s.prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []PersistedEvent{}
+	for limit <= 0 || len(result) < limit {
+		events, err := s.store.LoadAllFrom(ctx, position, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range events {
+			if strings.HasPrefix(e.AggregateID, prefix) && (limit <= 0 || len(result) < limit) {
+				result = append(result, e)
+			}
+		}
+
+		if limit <= 0 || len(events) < limit {
+			break
+		}
+
+		position = events[len(events)-1].GlobalPosition
+	}
+
+	return unscope(prefix, result), nil
+}
+
+// Stream sends the events of the aggregate of the tenant in sequence order on
+// the returned channel.
+func (s *tenantEventStore) Stream(ctx context.Context, aggregateType, id string) (<-chan PersistedEvent, <-chan error) {
+	return stream(ctx, s, aggregateType, id)
+}
+
+// unscope removes the tenant prefix from the aggregate IDs of the events.
+func unscope(prefix string, events []PersistedEvent) []PersistedEvent {
+	for i := range events {
+		events[i].AggregateID = strings.TrimPrefix(events[i].AggregateID, prefix)
+	}
+	return events
+}
+
+// NewTenantEventStore returns an event store isolating the events of each
+// tenant within the given store. The tenant is taken from the context of each
+// call, see WithTenant, and is prepended to the aggregate IDs in the
+// underlying store, so that orders of different tenants may share IDs. Calls
+// without a tenant fail with ErrMissingTenant.
+//
+// Snapshot stores are keyed by order ID only, so a repository used by several
+// tenants must not take snapshots.
+func NewTenantEventStore(store EventStore) EventStore {
+	return &tenantEventStore{store: store}
+}
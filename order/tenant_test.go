@@ -0,0 +1,162 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestTenantEventStore(t *testing.T) {
+	store := order.NewTenantEventStore(order.NewEventStore())
+	repo := order.NewRepository(store)
+	handler := order.NewCommandHandler(repo)
+
+	acme := order.WithTenant(context.Background(), "acme")
+	globex := order.WithTenant(context.Background(), "globex")
+
+	if err := handler.Handle(acme, order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(globex, order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler.Handle(acme, order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		ctx  context.Context
+		want []order.Event
+	}{
+		{
+			ctx: acme,
+			want: []order.Event{
+				order.Placed{OrderID: "ABC123", Lines: testLines},
+				order.Activated{OrderID: "ABC123"},
+			},
+		},
+		{
+			ctx: globex,
+			want: []order.Event{
+				order.Placed{OrderID: "ABC123", Lines: testLines},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tenantID, _ := order.TenantFromContext(tt.ctx)
+
+		t.Run(tenantID, func(t *testing.T) {
+			events, err := store.Load(tt.ctx, order.AggregateTypeOrder, "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []order.Event
+			for _, e := range events {
+				if e.AggregateID != "ABC123" {
+					t.Errorf("expected: %v, got: %v", "ABC123", e.AggregateID)
+				}
+				got = append(got, e.Event)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+
+			all, err := store.LoadAll(tt.ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(all) != len(tt.want) {
+				t.Errorf("expected: %v, got: %v", len(tt.want), len(all))
+			}
+
+			histories, err := store.LoadMany(tt.ctx, order.AggregateTypeOrder, []string{"ABC123"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(histories["ABC123"]) != len(tt.want) {
+				t.Errorf("expected: %v, got: %v", len(tt.want), len(histories["ABC123"]))
+			}
+		})
+	}
+
+	if _, err := store.Load(order.WithTenant(context.Background(), "initech"), order.AggregateTypeOrder, "ABC123"); !errors.Is(err, order.ErrOrderNotFound) {
+		t.Errorf("expected: %v, got: %v", order.ErrOrderNotFound, err)
+	}
+}
+
+func TestTenantEventStoreMissingTenant(t *testing.T) {
+	store := order.NewTenantEventStore(order.NewEventStore())
+
+	if _, err := store.Load(context.Background(), order.AggregateTypeOrder, "ABC123"); !errors.Is(err, order.ErrMissingTenant) {
+		t.Errorf("expected: %v, got: %v", order.ErrMissingTenant, err)
+	}
+
+	ctx := order.WithTenant(context.Background(), "acme/other")
+	if _, err := store.Load(ctx, order.AggregateTypeOrder, "ABC123"); !errors.Is(err, order.ErrInvalidTenant) {
+		t.Errorf("expected: %v, got: %v", order.ErrInvalidTenant, err)
+	}
+}
+
+func TestTenantEventStoreConflict(t *testing.T) {
+	store := order.NewTenantEventStore(order.NewEventStore())
+	ctx := order.WithTenant(context.Background(), "acme")
+
+	events := []order.PersistedEvent{{Event: order.Placed{OrderID: "ABC123", Lines: testLines}}}
+	if err := store.Save(ctx, order.AggregateTypeOrder, "ABC123", 0, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := store.Save(ctx, order.AggregateTypeOrder, "ABC123", 0, events)
+
+	var cerr *order.ConcurrencyError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected: %T, got: %T", cerr, err)
+	}
+
+	want := order.ConcurrencyError{AggregateID: "ABC123", Expected: 0, Actual: 1}
+	if *cerr != want {
+		t.Errorf("expected: %+v, got: %+v", want, *cerr)
+	}
+}
+
+func TestTenantEventStoreLoadAllFrom(t *testing.T) {
+	store := order.NewTenantEventStore(order.NewEventStore())
+
+	acme := order.WithTenant(context.Background(), "acme")
+	globex := order.WithTenant(context.Background(), "globex")
+
+	for i, ctx := range []context.Context{acme, globex, globex, acme, globex, acme} {
+		id := fmt.Sprintf("order-%d", i)
+		if err := store.Save(ctx, order.AggregateTypeOrder, id, 0, []order.PersistedEvent{{Event: order.Placed{OrderID: order.OrderID(id), Lines: testLines}}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var got []string
+	var position int64
+	for {
+		events, err := store.LoadAllFrom(acme, position, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, e := range events {
+			got = append(got, e.AggregateID)
+		}
+		position = events[len(events)-1].GlobalPosition
+	}
+
+	want := []string{"order-0", "order-3", "order-5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected: %v, got: %v", want, got)
+	}
+}
@@ -0,0 +1,31 @@
+package order
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by this package.
+const instrumentationName = "github.com/marcusolsson/cqrs-example/order"
+
+// startSpan starts a child span of the span carried by the context, using the
+// tracer provider of the parent. Without a parent span, no span is started.
+func startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return ctx, parent
+	}
+
+	return parent.TracerProvider().Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// endSpan records the error, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
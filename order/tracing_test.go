@@ -0,0 +1,114 @@
+package order_test
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func newTracedHandler() (order.CommandHandler, *tracetest.SpanRecorder) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+
+	repo := order.NewRepository(order.NewEventStore())
+	h := order.NewCommandHandler(repo, order.WithTracer(tp.Tracer("test")))
+
+	return h, rec
+}
+
+func spanNamed(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, s := range spans {
+		if s.Name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func hasAttribute(s sdktrace.ReadOnlySpan, kv attribute.KeyValue) bool {
+	for _, a := range s.Attributes() {
+		if a == kv {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTracingSpans(t *testing.T) {
+	h, rec := newTracedHandler()
+
+	if err := h.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := rec.Ended()
+
+	parent := spanNamed(spans, "order.Place")
+	if parent == nil {
+		t.Fatalf("expected span %q", "order.Place")
+	}
+	for _, kv := range []attribute.KeyValue{
+		attribute.String("aggregate.id", "ABC123"),
+		attribute.String("command.type", "order.Place"),
+	} {
+		if !hasAttribute(parent, kv) {
+			t.Errorf("expected attribute: %v, got: %v", kv, parent.Attributes())
+		}
+	}
+
+	child := spanNamed(spans, "EventStore.Save")
+	if child == nil {
+		t.Fatalf("expected span %q", "EventStore.Save")
+	}
+	if want, got := parent.SpanContext().SpanID(), child.Parent().SpanID(); want != got {
+		t.Errorf("expected: %v, got: %v", want, got)
+	}
+}
+
+func TestTracingLoadSpan(t *testing.T) {
+	h, rec := newTracedHandler()
+
+	ctx := context.Background()
+	if err := h.Handle(ctx, order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := h.Handle(ctx, order.Activate{OrderID: "ABC123"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := rec.Ended()
+
+	parent := spanNamed(spans, "order.Activate")
+	if parent == nil {
+		t.Fatalf("expected span %q", "order.Activate")
+	}
+	child := spanNamed(spans, "EventStore.Load")
+	if child == nil {
+		t.Fatalf("expected span %q", "EventStore.Load")
+	}
+	if want, got := parent.SpanContext().SpanID(), child.Parent().SpanID(); want != got {
+		t.Errorf("expected: %v, got: %v", want, got)
+	}
+}
+
+func TestTracingRecordsError(t *testing.T) {
+	h, rec := newTracedHandler()
+
+	if err := h.Handle(context.Background(), order.Activate{OrderID: "ABC123"}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	span := spanNamed(rec.Ended(), "order.Activate")
+	if span == nil {
+		t.Fatalf("expected span %q", "order.Activate")
+	}
+	if want, got := codes.Error, span.Status().Code; want != got {
+		t.Errorf("expected: %v, got: %v", want, got)
+	}
+}
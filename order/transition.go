@@ -0,0 +1,51 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidTransition is returned when an order cannot move from its current
+// status to the requested one. The returned error is a *TransitionError.
+var ErrInvalidTransition = errors.New("invalid transition")
+
+// TransitionError describes a status transition that is not allowed.
+type TransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%v from %v to %v", ErrInvalidTransition, e.From, e.To)
+}
+
+// Is reports whether the target is ErrInvalidTransition.
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// transitions lists the statuses an order may move to from each status.
+var transitions = map[Status][]Status{
+	StatusPlaced:    {StatusActivated, StatusCancelled, StatusExpired},
+	StatusActivated: {StatusShipped, StatusCancelled, StatusHeld},
+	StatusHeld:      {StatusActivated, StatusCancelled},
+	StatusShipped:   {StatusDelivered},
+}
+
+// CanTransition reports whether an order may move from one status to another.
+func CanTransition(from, to Status) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition returns an error unless the order may move to the given status.
+func transition(o *Order, to Status) error {
+	if !CanTransition(o.Status, to) {
+		return &TransitionError{From: o.Status, To: to}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to order.Status
+		want     bool
+	}{
+		{from: order.StatusPlaced, to: order.StatusActivated, want: true},
+		{from: order.StatusPlaced, to: order.StatusShipped, want: false},
+		{from: order.StatusActivated, to: order.StatusShipped, want: true},
+		{from: order.StatusActivated, to: order.StatusDelivered, want: false},
+		{from: order.StatusShipped, to: order.StatusDelivered, want: true},
+		{from: order.StatusShipped, to: order.StatusCancelled, want: false},
+		{from: order.StatusDelivered, to: order.StatusShipped, want: false},
+		{from: order.StatusCancelled, to: order.StatusActivated, want: false},
+		{from: order.StatusActivated, to: order.StatusHeld, want: true},
+		{from: order.StatusPlaced, to: order.StatusHeld, want: false},
+		{from: order.StatusHeld, to: order.StatusActivated, want: true},
+		{from: order.StatusHeld, to: order.StatusShipped, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := order.CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("%v -> %v: expected: %v, got: %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+func TestShipAndDeliverOrder(t *testing.T) {
+	repo := order.NewRepository(
+		order.NewEventStore(),
+	)
+
+	handler := order.NewCommandHandler(repo)
+
+	for _, cmd := range []interface{}{
+		order.Place{OrderID: "ABC123", Lines: testLines},
+		order.Activate{OrderID: "ABC123"},
+		order.Ship{OrderID: "ABC123"},
+		order.Deliver{OrderID: "ABC123"},
+	} {
+		if err := handler.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error for %T: %v", cmd, err)
+		}
+	}
+
+	o, err := repo.Load(context.Background(), "ABC123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Status != order.StatusDelivered {
+		t.Errorf("expected: %v, got: %v", order.StatusDelivered, o.Status)
+	}
+}
+
+func TestHoldAndReactivateOrder(t *testing.T) {
+	store := order.NewEventStore()
+	repo := order.NewRepository(store)
+
+	handler := order.NewCommandHandler(repo)
+
+	for _, tt := range []struct {
+		cmd  interface{}
+		want order.Status
+	}{
+		{cmd: order.Place{OrderID: "ABC123", Lines: testLines}, want: order.StatusPlaced},
+		{cmd: order.Activate{OrderID: "ABC123"}, want: order.StatusActivated},
+		{cmd: order.Hold{OrderID: "ABC123"}, want: order.StatusHeld},
+		{cmd: order.Reactivate{OrderID: "ABC123"}, want: order.StatusActivated},
+		{cmd: order.Hold{OrderID: "ABC123"}, want: order.StatusHeld},
+	} {
+		if err := handler.Handle(context.Background(), tt.cmd); err != nil {
+			t.Fatalf("unexpected error for %T: %v", tt.cmd, err)
+		}
+
+		o, err := repo.Load(context.Background(), "ABC123")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if o.Status != tt.want {
+			t.Errorf("%T: expected: %v, got: %v", tt.cmd, tt.want, o.Status)
+		}
+	}
+
+	projection := order.NewSummaryProjection()
+
+	replayer := order.NewReplayer(store)
+	replayer.Register(projection)
+
+	if err := replayer.Replay(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	summary, ok := projection.Get("ABC123")
+	if !ok {
+		t.Fatal("expected order to be projected")
+	}
+	if summary.Status != order.StatusHeld {
+		t.Errorf("expected: %v, got: %v", order.StatusHeld, summary.Status)
+	}
+}
+
+func TestIllegalTransitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []interface{}
+		from, to order.Status
+	}{
+		{
+			name:     "ship placed order",
+			commands: []interface{}{order.Ship{OrderID: "ABC123"}},
+			from:     order.StatusPlaced,
+			to:       order.StatusShipped,
+		},
+		{
+			name:     "deliver activated order",
+			commands: []interface{}{order.Activate{OrderID: "ABC123"}, order.Deliver{OrderID: "ABC123"}},
+			from:     order.StatusActivated,
+			to:       order.StatusDelivered,
+		},
+		{
+			name:     "ship cancelled order",
+			commands: []interface{}{order.Cancel{OrderID: "ABC123"}, order.Ship{OrderID: "ABC123"}},
+			from:     order.StatusCancelled,
+			to:       order.StatusShipped,
+		},
+		{
+			name:     "hold placed order",
+			commands: []interface{}{order.Hold{OrderID: "ABC123"}},
+			from:     order.StatusPlaced,
+			to:       order.StatusHeld,
+		},
+		{
+			name:     "reactivate placed order",
+			commands: []interface{}{order.Reactivate{OrderID: "ABC123"}},
+			from:     order.StatusPlaced,
+			to:       order.StatusActivated,
+		},
+		{
+			name:     "reactivate activated order",
+			commands: []interface{}{order.Activate{OrderID: "ABC123"}, order.Reactivate{OrderID: "ABC123"}},
+			from:     order.StatusActivated,
+			to:       order.StatusActivated,
+		},
+		{
+			name: "ship held order",
+			commands: []interface{}{
+				order.Activate{OrderID: "ABC123"},
+				order.Hold{OrderID: "ABC123"},
+				order.Ship{OrderID: "ABC123"},
+			},
+			from: order.StatusHeld,
+			to:   order.StatusShipped,
+		},
+		{
+			name:     "activate cancelled order",
+			commands: []interface{}{order.Cancel{OrderID: "ABC123"}, order.Activate{OrderID: "ABC123"}},
+			from:     order.StatusCancelled,
+			to:       order.StatusActivated,
+		},
+		{
+			name: "activate held order",
+			commands: []interface{}{
+				order.Activate{OrderID: "ABC123"},
+				order.Hold{OrderID: "ABC123"},
+				order.Activate{OrderID: "ABC123"},
+			},
+			from: order.StatusHeld,
+			to:   order.StatusActivated,
+		},
+		{
+			name: "activate shipped order",
+			commands: []interface{}{
+				order.Activate{OrderID: "ABC123"},
+				order.Ship{OrderID: "ABC123"},
+				order.Activate{OrderID: "ABC123"},
+			},
+			from: order.StatusShipped,
+			to:   order.StatusActivated,
+		},
+		{
+			name: "activate delivered order",
+			commands: []interface{}{
+				order.Activate{OrderID: "ABC123"},
+				order.Ship{OrderID: "ABC123"},
+				order.Deliver{OrderID: "ABC123"},
+				order.Activate{OrderID: "ABC123"},
+			},
+			from: order.StatusDelivered,
+			to:   order.StatusActivated,
+		},
+		{
+			name: "cancel shipped order",
+			commands: []interface{}{
+				order.Activate{OrderID: "ABC123"},
+				order.Ship{OrderID: "ABC123"},
+				order.Cancel{OrderID: "ABC123"},
+			},
+			from: order.StatusShipped,
+			to:   order.StatusCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := order.NewCommandHandler(order.NewRepository(order.NewEventStore()))
+
+			if err := handler.Handle(context.Background(), order.Place{OrderID: "ABC123", Lines: testLines}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var err error
+			for _, cmd := range tt.commands {
+				if err = handler.Handle(context.Background(), cmd); err != nil {
+					break
+				}
+			}
+
+			if !errors.Is(err, order.ErrInvalidTransition) {
+				t.Fatalf("expected: %v, got: %v", order.ErrInvalidTransition, err)
+			}
+
+			var terr *order.TransitionError
+			if !errors.As(err, &terr) {
+				t.Fatalf("expected: %T, got: %T", terr, err)
+			}
+
+			if terr.From != tt.from || terr.To != tt.to {
+				t.Errorf("expected: %v -> %v, got: %v -> %v", tt.from, tt.to, terr.From, terr.To)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package order
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTransactionsNotSupported is returned when committing a unit of work
+// against an event store that can't save several aggregates atomically.
+var ErrTransactionsNotSupported = errors.New("event store does not support transactions")
+
+// AggregateChanges are the events to append to an aggregate, expected to be
+// at the given version.
+type AggregateChanges struct {
+	AggregateType   string
+	AggregateID     string
+	ExpectedVersion int
+	Events          []PersistedEvent
+}
+
+// TransactionalStore is implemented by event stores that can save the events
+// of several aggregates in a single transaction.
+type TransactionalStore interface {
+	EventStore
+
+	// SaveAll appends the events of every aggregate, or none of them if any
+	// aggregate isn't at its expected version.
+	SaveAll(ctx context.Context, changes []AggregateChanges) error
+}
+
+// trackedAggregate is an aggregate added to a unit of work.
+type trackedAggregate struct {
+	aggregateType string
+	aggregate     EventSourced
+}
+
+// UnitOfWork collects modified aggregates and saves them together, so that
+// either all or none of their uncommitted events are saved.
+//
+// Unlike a repository, a unit of work only saves events to the store. It
+// doesn't publish them or take snapshots.
+type UnitOfWork struct {
+	store      EventStore
+	aggregates []trackedAggregate
+}
+
+// NewUnitOfWork returns a new, empty unit of work saving to the store.
+func NewUnitOfWork(store EventStore) *UnitOfWork {
+	return &UnitOfWork{store: store}
+}
+
+// Add adds the aggregate, stored under the given aggregate type, to the unit
+// of work. Its uncommitted events are saved when the unit of work commits.
+func (u *UnitOfWork) Add(aggregateType string, aggregate EventSourced) {
+	u.aggregates = append(u.aggregates, trackedAggregate{aggregateType: aggregateType, aggregate: aggregate})
+}
+
+// Commit saves the uncommitted events of every aggregate in a single
+// transaction. If any aggregate has been modified concurrently, nothing is
+// saved and a *ConcurrencyError is returned. After a successful commit the
+// unit of work is empty.
+func (u *UnitOfWork) Commit(ctx context.Context) error {
+	store, ok := u.store.(TransactionalStore)
+	if !ok {
+		return ErrTransactionsNotSupported
+	}
+
+	var (
+		changes []AggregateChanges
+		roots   []*AggregateRoot
+	)
+	for _, t := range u.aggregates {
+		root := t.aggregate.Root()
+		if len(root.uncommitted) == 0 {
+			continue
+		}
+
+		changes = append(changes, AggregateChanges{
+			AggregateType:   t.aggregateType,
+			AggregateID:     root.ID,
+			ExpectedVersion: root.version - len(root.uncommitted),
+			Events:          root.uncommitted,
+		})
+		roots = append(roots, root)
+	}
+
+	saveCtx, skipped := withSkippedEvents(ctx)
+	if len(changes) > 0 {
+		if err := store.SaveAll(saveCtx, changes); err != nil {
+			return err
+		}
+	}
+
+	for i, c := range changes {
+		recordCommitted(ctx, skipped.appended(committed(c.AggregateType, c.AggregateID, c.ExpectedVersion, c.Events)))
+		roots[i].MarkCommitted()
+	}
+
+	u.aggregates = nil
+
+	return nil
+}
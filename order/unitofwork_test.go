@@ -0,0 +1,144 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	sqliteStore, err := order.NewSQLiteEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	stores := map[string]order.EventStore{
+		"memory":      order.NewEventStore(),
+		"sqlite":      sqliteStore,
+		"tenant":      order.NewTenantEventStore(order.NewEventStore()),
+		"schema":      order.NewSchemaValidatingStore(order.NewEventStore(), order.NewSchemas()),
+		"encrypting":  order.NewEncryptingEventStore(order.NewEventStore(), order.NewStaticKeyProvider("k1", testKeys)),
+		"compressing": order.NewCompressingEventStore(order.NewEventStore(), order.WithCompressionThreshold(0)),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := order.WithTenant(context.Background(), "acme")
+
+			repo := order.NewRepository(store)
+			handler := order.NewCommandHandler(repo)
+
+			for _, id := range []string{"ABC123", "XYZ789"} {
+				if err := handler.Handle(ctx, order.Place{OrderID: order.OrderID(id), Lines: testLines}); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			first, err := repo.Load(ctx, "ABC123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			second, err := repo.Load(ctx, "XYZ789")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if err := first.Activate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := second.Cancel(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// Modify the second order behind the back of the unit of work.
+			if err := handler.Handle(ctx, order.Activate{OrderID: "XYZ789"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			uow := order.NewUnitOfWork(store)
+			uow.Add(order.AggregateTypeOrder, &first)
+			uow.Add(order.AggregateTypeOrder, &second)
+
+			err = uow.Commit(ctx)
+
+			var cerr *order.ConcurrencyError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("expected: %T, got: %v", cerr, err)
+			}
+			if cerr.AggregateID != "XYZ789" {
+				t.Errorf("expected: %v, got: %v", "XYZ789", cerr.AggregateID)
+			}
+
+			for id, want := range map[string]order.Status{
+				"ABC123": order.StatusPlaced,
+				"XYZ789": order.StatusActivated,
+			} {
+				o, err := repo.Load(ctx, id)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if o.Status != want {
+					t.Errorf("%s: expected: %v, got: %v", id, want, o.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestUnitOfWorkCommit(t *testing.T) {
+	ctx := context.Background()
+	store := order.NewEventStore()
+
+	first := order.NewOrder("ABC123")
+	second := order.NewOrder("XYZ789")
+
+	for _, o := range []*order.Order{&first, &second} {
+		if err := o.Place(testLines); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	uow := order.NewUnitOfWork(store)
+	uow.Add(order.AggregateTypeOrder, &first)
+	uow.Add(order.AggregateTypeOrder, &second)
+
+	if err := uow.Commit(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := store.LoadAll(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Errorf("expected: %v, got: %v", 2, len(events))
+	}
+
+	if len(first.UncommittedEvents()) != 0 || len(second.UncommittedEvents()) != 0 {
+		t.Errorf("expected committed aggregates to have no uncommitted events")
+	}
+}
+
+func TestUnitOfWorkNotSupported(t *testing.T) {
+	o := order.NewOrder("ABC123")
+	if err := o.Place(testLines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fileStore, err := order.NewFileEventStore(filepath.Join(t.TempDir(), "events.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	uow := order.NewUnitOfWork(fileStore)
+	uow.Add(order.AggregateTypeOrder, &o)
+
+	if err := uow.Commit(context.Background()); !errors.Is(err, order.ErrTransactionsNotSupported) {
+		t.Errorf("expected: %v, got: %v", order.ErrTransactionsNotSupported, err)
+	}
+}
@@ -0,0 +1,69 @@
+package order
+
+import (
+	"encoding/json"
+)
+
+// Upcaster transforms the payload of an event stored with an older schema
+// version into the encoding of a newer version. Upcasters that don't apply to
+// the type and version must return the data and version unchanged.
+type Upcaster interface {
+	Upcast(typeName string, version int, data []byte) (newData []byte, newVersion int, err error)
+}
+
+// UpcasterFunc is an adapter allowing the use of ordinary functions as
+// upcasters.
+type UpcasterFunc func(typeName string, version int, data []byte) ([]byte, int, error)
+
+// Upcast calls f(typeName, version, data).
+func (f UpcasterFunc) Upcast(typeName string, version int, data []byte) ([]byte, int, error) {
+	return f(typeName, version, data)
+}
+
+// NopUpcaster returns every payload unchanged.
+var NopUpcaster Upcaster = UpcasterFunc(func(typeName string, version int, data []byte) ([]byte, int, error) {
+	return data, version, nil
+})
+
+// UpcasterChain applies each of the upcasters in order, so that a payload may
+// be upcast through several versions at once.
+type UpcasterChain []Upcaster
+
+// Upcast passes the result of each upcaster on to the next.
+func (c UpcasterChain) Upcast(typeName string, version int, data []byte) ([]byte, int, error) {
+	for _, u := range c {
+		var err error
+		data, version, err = u.Upcast(typeName, version, data)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return data, version, nil
+}
+
+// RenameField returns an upcaster renaming a top-level field of the events of
+// the given type from one version to the next.
+func RenameField(typeName string, fromVersion int, oldName, newName string) Upcaster {
+	return UpcasterFunc(func(name string, version int, data []byte) ([]byte, int, error) {
+		if name != typeName || version != fromVersion {
+			return data, version, nil
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, 0, err
+		}
+
+		if value, ok := fields[oldName]; ok {
+			fields[newName] = value
+			delete(fields, oldName)
+		}
+
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return data, version + 1, nil
+	})
+}
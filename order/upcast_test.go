@@ -0,0 +1,116 @@
+package order_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+// restocked is at schema version 2. Version 1 named the quantity Qty.
+type restocked struct {
+	ProductID string
+	Quantity  int
+}
+
+func (e restocked) ID() string {
+	return e.ProductID
+}
+
+func init() {
+	order.DefaultRegistry.RegisterVersion("test.Restocked", 2, func() order.Event { return restocked{} })
+	order.DefaultRegistry.RegisterUpcaster(order.RenameField("test.Restocked", 1, "Qty", "Quantity"))
+}
+
+// stock is an aggregate counting the restocked quantity of a product.
+type stock struct {
+	order.AggregateRoot
+	Quantity int
+}
+
+func (s *stock) Apply(e order.Event) error {
+	s.ID = e.ID()
+	s.Quantity += e.(restocked).Quantity
+	return nil
+}
+
+func TestUpcastStoredEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	lines := `{"aggregate_type":"stock","aggregate_id":"P1","sequence":1,"global_sequence":1,"type":"test.Restocked","payload":{"ProductID":"P1","Qty":3},"schema_version":1}
+{"aggregate_type":"stock","aggregate_id":"P1","sequence":2,"global_sequence":2,"type":"test.Restocked","payload":{"ProductID":"P1","Quantity":4},"schema_version":2}
+`
+	if err := os.WriteFile(path, []byte(lines), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store, err := order.NewFileEventStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events, err := store.Load(context.Background(), "stock", "P1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []order.Event{
+		restocked{ProductID: "P1", Quantity: 3},
+		restocked{ProductID: "P1", Quantity: 4},
+	}
+
+	for i, e := range events {
+		if !reflect.DeepEqual(e.Event, want[i]) {
+			t.Errorf("expected: %#v, got: %#v", want[i], e.Event)
+		}
+		if e.SchemaVersion != 2 {
+			t.Errorf("expected: %v, got: %v", 2, e.SchemaVersion)
+		}
+	}
+
+	repo := order.NewAggregateRepository(store, "stock", func() *stock { return &stock{} })
+
+	s, err := repo.Load(context.Background(), "P1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s.Quantity != 7 {
+		t.Errorf("expected: %v, got: %v", 7, s.Quantity)
+	}
+}
+
+func TestUpcasterChain(t *testing.T) {
+	chain := order.UpcasterChain{
+		order.NopUpcaster,
+		order.RenameField("test.Dispatched", 1, "Order", "OrderRef"),
+		order.RenameField("test.Dispatched", 2, "OrderRef", "OrderID"),
+		order.RenameField("test.Other", 1, "OrderID", "Order"),
+	}
+
+	data, version, err := chain.Upcast("test.Dispatched", 1, []byte(`{"Order":"ABC123"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if version != 3 {
+		t.Errorf("expected: %v, got: %v", 3, version)
+	}
+	if string(data) != `{"OrderID":"ABC123"}` {
+		t.Errorf("expected: %s, got: %s", `{"OrderID":"ABC123"}`, data)
+	}
+}
+
+func TestNopUpcaster(t *testing.T) {
+	data, version, err := order.NopUpcaster.Upcast("order.Placed", 1, []byte(`{}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if version != 1 || string(data) != `{}` {
+		t.Errorf("expected payload to be unchanged, got: %s at version %d", data, version)
+	}
+}
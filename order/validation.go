@@ -0,0 +1,105 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidCommand is returned when a command fails validation.
+var ErrInvalidCommand = errors.New("invalid command")
+
+// Validator is implemented by commands that can check their input before
+// they are dispatched.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes a problem with a field of a command.
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+// ValidationError lists the problems found when validating a command.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		problems[i] = f.Field + " " + f.Reason
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidCommand, strings.Join(problems, ", "))
+}
+
+// Is reports whether the target is ErrInvalidCommand.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidCommand
+}
+
+// validation collects the problems found while validating a command.
+type validation []FieldError
+
+// check adds a problem with the field unless ok holds.
+func (v *validation) check(ok bool, field, reason string) {
+	if !ok {
+		*v = append(*v, FieldError{Field: field, Reason: reason})
+	}
+}
+
+// err returns a *ValidationError listing the problems, if any.
+func (v validation) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v}
+}
+
+// Validate checks that the command names an order and has valid lines.
+func (c Place) Validate() error {
+	var v validation
+	v.check(c.OrderID != "", "OrderID", "must not be empty")
+	v.check(len(c.Lines) > 0, "Lines", "must not be empty")
+
+	for i, l := range c.Lines {
+		var lerr *LineError
+		if errors.As(l.validate(), &lerr) {
+			v.check(false, fmt.Sprintf("Lines[%d].%s", i, lerr.Field), lerr.Reason)
+		}
+	}
+
+	return v.err()
+}
+
+// Validate checks that the command names an order.
+func (c Activate) Validate() error {
+	var v validation
+	v.check(c.OrderID != "", "OrderID", "must not be empty")
+	return v.err()
+}
+
+// ValidationMiddleware rejects commands implementing Validator that fail
+// validation, without passing them on to the next handler. Commands wrapped
+// in a CommandEnvelope are validated too.
+func ValidationMiddleware() Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+			cmd := c
+			if env, ok := c.(CommandEnvelope); ok {
+				ctx = envelopeContext(ctx, env)
+				cmd = env.Command
+			}
+
+			if v, ok := cmd.(Validator); ok {
+				if err := v.Validate(); err != nil {
+					return err
+				}
+			}
+
+			return next.Handle(ctx, c)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestValidationMiddleware(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cmd  interface{}
+		want []order.FieldError
+	}{
+		{
+			name: "valid place",
+			cmd:  order.Place{OrderID: "ABC123", Lines: testLines},
+		},
+		{
+			name: "invalid place",
+			cmd:  order.Place{Lines: []order.Line{{ProductID: "P1"}}},
+			want: []order.FieldError{
+				{Field: "OrderID", Reason: "must not be empty"},
+				{Field: "Lines[0].Quantity", Reason: "must be positive"},
+			},
+		},
+		{
+			name: "place without lines",
+			cmd:  order.Place{OrderID: "ABC123"},
+			want: []order.FieldError{
+				{Field: "Lines", Reason: "must not be empty"},
+			},
+		},
+		{
+			name: "valid activate",
+			cmd:  order.Activate{OrderID: "ABC123"},
+		},
+		{
+			name: "invalid activate",
+			cmd:  order.Activate{},
+			want: []order.FieldError{
+				{Field: "OrderID", Reason: "must not be empty"},
+			},
+		},
+		{
+			name: "invalid activate in envelope",
+			cmd:  order.CommandEnvelope{CommandID: "CMD1", Command: order.Activate{}},
+			want: []order.FieldError{
+				{Field: "OrderID", Reason: "must not be empty"},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled bool
+			next := order.CommandHandlerFunc(func(ctx context.Context, c interface{}) error {
+				handled = true
+				return nil
+			})
+
+			err := order.Chain(next, order.ValidationMiddleware()).Handle(context.Background(), tt.cmd)
+
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !handled {
+					t.Errorf("expected valid command to be handled")
+				}
+				return
+			}
+
+			if !errors.Is(err, order.ErrInvalidCommand) {
+				t.Fatalf("expected: %v, got: %v", order.ErrInvalidCommand, err)
+			}
+
+			var verr *order.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected: %T, got: %T", verr, err)
+			}
+
+			if !reflect.DeepEqual(verr.Fields, tt.want) {
+				t.Errorf("expected: %v, got: %v", tt.want, verr.Fields)
+			}
+
+			if handled {
+				t.Errorf("expected invalid command not to be handled")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package order
+
+import "sync"
+
+// ViewStore is an in-memory store for the views of a read model, keyed by
+// K. It is safe for concurrent use by multiple goroutines.
+type ViewStore[K comparable, V any] struct {
+	mu    sync.RWMutex
+	views map[K]V
+}
+
+// NewViewStore returns a new, empty view store.
+func NewViewStore[K comparable, V any]() *ViewStore[K, V] {
+	return &ViewStore[K, V]{
+		views: make(map[K]V),
+	}
+}
+
+// Get returns the view stored under the key.
+func (s *ViewStore[K, V]) Get(key K) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.views[key]
+	return v, ok
+}
+
+// Set stores the view under the key, replacing any previous view.
+func (s *ViewStore[K, V]) Set(key K, v V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.views[key] = v
+}
+
+// Delete removes the view stored under the key, if any.
+func (s *ViewStore[K, V]) Delete(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.views, key)
+}
+
+// Replace replaces all stored views with a copy of the given views at once,
+// so that readers see either the previous views or the new ones.
+func (s *ViewStore[K, V]) Replace(views map[K]V) {
+	replaced := make(map[K]V, len(views))
+	for k, v := range views {
+		replaced[k] = v
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.views = replaced
+}
+
+// All returns a copy of all views, keyed by their key.
+func (s *ViewStore[K, V]) All() map[K]V {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[K]V, len(s.views))
+	for k, v := range s.views {
+		result[k] = v
+	}
+	return result
+}
+
+// Len returns the number of stored views.
+func (s *ViewStore[K, V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.views)
+}
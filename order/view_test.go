@@ -0,0 +1,88 @@
+package order_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/marcusolsson/cqrs-example/order"
+)
+
+func TestViewStore(t *testing.T) {
+	s := order.NewViewStore[string, order.OrderSummary]()
+
+	if _, ok := s.Get("ABC123"); ok {
+		t.Fatalf("expected no view in an empty store")
+	}
+
+	s.Set("ABC123", order.OrderSummary{ID: "ABC123", Status: order.StatusPlaced})
+	s.Set("XYZ789", order.OrderSummary{ID: "XYZ789", Status: order.StatusPlaced})
+	s.Set("ABC123", order.OrderSummary{ID: "ABC123", Status: order.StatusActivated})
+
+	if v, ok := s.Get("ABC123"); !ok || v.Status != order.StatusActivated {
+		t.Errorf("expected: %v, got: %v", order.StatusActivated, v.Status)
+	}
+	if s.Len() != 2 {
+		t.Errorf("expected: %v, got: %v", 2, s.Len())
+	}
+
+	s.Delete("XYZ789")
+	s.Delete("unknown")
+
+	all := s.All()
+	if len(all) != 1 || all["ABC123"].ID != "ABC123" {
+		t.Errorf("expected: %v, got: %v", []string{"ABC123"}, all)
+	}
+
+	// The returned views are a copy.
+	delete(all, "ABC123")
+	if s.Len() != 1 {
+		t.Errorf("expected: %v, got: %v", 1, s.Len())
+	}
+}
+
+func TestViewStoreConcurrentAccess(t *testing.T) {
+	s := order.NewViewStore[int, string]()
+
+	const n = 100
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			s.Set(i, fmt.Sprintf("view %d", i))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			s.Get(i)
+			s.All()
+		}(i)
+	}
+	wg.Wait()
+
+	if s.Len() != n {
+		t.Errorf("expected: %v, got: %v", n, s.Len())
+	}
+}
+
+func TestViewStoreReplace(t *testing.T) {
+	s := order.NewViewStore[string, int]()
+	s.Set("ABC123", 1)
+
+	views := map[string]int{"XYZ789": 2}
+	s.Replace(views)
+
+	if _, ok := s.Get("ABC123"); ok {
+		t.Errorf("expected replaced view to be removed")
+	}
+	if v, ok := s.Get("XYZ789"); !ok || v != 2 {
+		t.Errorf("expected: %v, got: %v", 2, v)
+	}
+
+	// The store keeps a copy of the views.
+	views["DEF456"] = 3
+	if s.Len() != 1 {
+		t.Errorf("expected: %v, got: %v", 1, s.Len())
+	}
+}